@@ -23,6 +23,19 @@ var (
 	logNotAvailableErrorCode  = "log_not_available"
 	logInvalidNameCode        = "log_invalid_name"
 	missingLengthErrorCode    = "missing_content_length"
+	groupNotFoundErrorCode    = "group_not_found"
+	groupInvalidNameCode      = "group_invalid_name"
+	invalidPositionErrorCode  = "invalid_position"
+	readOnlyErrorCode         = "read_only"
+	unauthorizedErrorCode     = "unauthorized"
+	forbiddenErrorCode        = "forbidden"
+	positionMismatchErrorCode = "position_mismatch"
+	pipelineExistErrorCode    = "pipeline_exist"
+	pipelineNotFoundErrorCode = "pipeline_not_found"
+	pipelineStaticErrorCode   = "pipeline_static"
+	pipelineInvalidNameCode   = "pipeline_invalid_name"
+	notReadyErrorCode         = "not_ready"
+	logNotRepairableErrorCode = "log_not_repairable"
 
 	defaultErrorMessage          = "api: unknown error"
 	methodNotAllowedErrorMessage = "api: method not allowed"
@@ -32,6 +45,19 @@ var (
 	logNotAvailableErrorMessage  = "api: log not available"
 	logInvalidNameMessage        = "api: log name invalid"
 	missingLengthErrorMessage    = "api: missing content-length"
+	groupNotFoundErrorMessage    = "api: group not found"
+	groupInvalidNameMessage      = "api: group name invalid"
+	invalidPositionErrorMessage  = "api: invalid position"
+	readOnlyErrorMessage         = "api: logs are read only on replication followers"
+	unauthorizedErrorMessage     = "api: missing or invalid token"
+	forbiddenErrorMessage        = "api: token not allowed"
+	positionMismatchErrorMessage = "api: log doesn't end at expected position"
+	pipelineExistErrorMessage    = "api: pipeline already exists"
+	pipelineNotFoundErrorMessage = "api: pipeline not found"
+	pipelineStaticErrorMessage   = "api: configured pipelines can't be deleted"
+	pipelineInvalidNameMessage   = "api: pipeline name invalid"
+	notReadyErrorMessage         = "api: server not ready"
+	logNotRepairableErrorMessage = "api: log can't be repaired"
 
 	ErrUnknownError         = NewError(defaultErrorCode, defaultErrorMessage)
 	ErrMethodNotAllowed     = NewError(methodNotAllowedErrorCode, methodNotAllowedErrorMessage)
@@ -41,6 +67,19 @@ var (
 	ErrLogNotAvailable      = NewError(logNotAvailableErrorCode, logNotAvailableErrorMessage)
 	ErrLogInvalidName       = NewError(logInvalidNameCode, logInvalidNameMessage)
 	ErrMissingContentLength = NewError(missingLengthErrorCode, missingLengthErrorMessage)
+	ErrGroupNotFound        = NewError(groupNotFoundErrorCode, groupNotFoundErrorMessage)
+	ErrGroupInvalidName     = NewError(groupInvalidNameCode, groupInvalidNameMessage)
+	ErrInvalidPosition      = NewError(invalidPositionErrorCode, invalidPositionErrorMessage)
+	ErrReadOnly             = NewError(readOnlyErrorCode, readOnlyErrorMessage)
+	ErrUnauthorized         = NewError(unauthorizedErrorCode, unauthorizedErrorMessage)
+	ErrForbidden            = NewError(forbiddenErrorCode, forbiddenErrorMessage)
+	ErrPositionMismatch     = NewError(positionMismatchErrorCode, positionMismatchErrorMessage)
+	ErrPipelineExist        = NewError(pipelineExistErrorCode, pipelineExistErrorMessage)
+	ErrPipelineNotFound     = NewError(pipelineNotFoundErrorCode, pipelineNotFoundErrorMessage)
+	ErrPipelineStatic       = NewError(pipelineStaticErrorCode, pipelineStaticErrorMessage)
+	ErrPipelineInvalidName  = NewError(pipelineInvalidNameCode, pipelineInvalidNameMessage)
+	ErrNotReady             = NewError(notReadyErrorCode, notReadyErrorMessage)
+	ErrLogNotRepairable     = NewError(logNotRepairableErrorCode, logNotRepairableErrorMessage)
 )
 
 type Error struct {
@@ -14,17 +14,24 @@ import (
 )
 
 var (
-	ErrUnknownError = errors.New("tcp: unknown error")
+	ErrUnknownError     = errors.New("tcp: unknown error")
+	ErrInvalidPosition  = errors.New("tcp: invalid position")
+	ErrGroupInvalidName = errors.New("tcp: group name invalid")
+	ErrLogNotAvailable  = errors.New("tcp: log not available")
 
 	defaultErrorCode    = 0
 	defaultErrorMessage = ErrUnknownError
 
 	errorsCodes = map[error]int{
-		// TODO: add tcp error codes.
+		ErrInvalidPosition:  1,
+		ErrGroupInvalidName: 2,
+		ErrLogNotAvailable:  3,
 	}
 
 	errorsMessages = map[int]error{
-		// TODO: add tcp error messages.
+		1: ErrInvalidPosition,
+		2: ErrGroupInvalidName,
+		3: ErrLogNotAvailable,
 	}
 )
 
@@ -23,6 +23,8 @@ const (
 	TypeAckMessage
 	TypeHeartbeatMessage
 	TypeErrorMessage
+	TypeCommitMessage
+	TypePositionMessage
 )
 
 var (
@@ -130,6 +132,62 @@ func (em *ErrorMessage) Decode(p []byte) (n int, err error) {
 	return n, nil
 }
 
+type CommitMessage struct {
+	Position int64
+}
+
+func (cm *CommitMessage) Encode(p []byte) (n int, err error) {
+
+	if len(p) < 8 {
+		return 0, recio.ErrShortBuffer
+	}
+
+	binary.BigEndian.PutUint64(p, uint64(cm.Position))
+	n = 8
+
+	return n, nil
+}
+
+func (cm *CommitMessage) Decode(p []byte) (n int, err error) {
+
+	if len(p) < 8 {
+		return 0, recio.ErrShortBuffer
+	}
+
+	cm.Position = int64(binary.BigEndian.Uint64(p[:8]))
+	n = 8
+
+	return n, nil
+}
+
+type PositionMessage struct {
+	Position int64
+}
+
+func (pm *PositionMessage) Encode(p []byte) (n int, err error) {
+
+	if len(p) < 8 {
+		return 0, recio.ErrShortBuffer
+	}
+
+	binary.BigEndian.PutUint64(p, uint64(pm.Position))
+	n = 8
+
+	return n, nil
+}
+
+func (pm *PositionMessage) Decode(p []byte) (n int, err error) {
+
+	if len(p) < 8 {
+		return 0, recio.ErrShortBuffer
+	}
+
+	pm.Position = int64(binary.BigEndian.Uint64(p[:8]))
+	n = 8
+
+	return n, nil
+}
+
 type Message struct {
 	Type    int
 	Payload recio.EncodeDecoder
@@ -138,6 +196,8 @@ type Message struct {
 	ackMessage       AckMessage
 	heartbeatMessage HeartbeatMessage
 	errorMessage     ErrorMessage
+	commitMessage    CommitMessage
+	positionMessage  PositionMessage
 }
 
 func (m *Message) Encode(p []byte) (n int, err error) {
@@ -177,6 +237,10 @@ func (m *Message) Decode(p []byte) (n int, err error) {
 		m.Payload = &m.heartbeatMessage
 	case TypeErrorMessage:
 		m.Payload = &m.errorMessage
+	case TypeCommitMessage:
+		m.Payload = &m.commitMessage
+	case TypePositionMessage:
+		m.Payload = &m.positionMessage
 	default:
 		return 0, ErrUnkownMessageType
 	}
@@ -20,12 +20,19 @@ import (
 
 type ErrorHandler func(err error)
 
+// Conn is a connection the Styx protocol runs on, either a *net.TCPConn or
+// a *tls.Conn.
+type Conn interface {
+	net.Conn
+	CloseWrite() (err error)
+}
+
 var (
 	ErrClosed = errors.New("peer: closed")
 )
 
 type TCPPeer struct {
-	conn              *net.TCPConn
+	conn              Conn
 	messageWriter     *MessageWriter
 	messageReader     *MessageReader
 	heartbeaterClose  chan struct{}
@@ -45,7 +52,7 @@ type TCPPeer struct {
 	errorHandler      ErrorHandler
 }
 
-func NewTCPPeer(conn *net.TCPConn, writeBufferSize int, readBufferSize int, localTimeout int, remoteTimeout int, ioMode recio.IOMode) (tp *TCPPeer) {
+func NewTCPPeer(conn Conn, writeBufferSize int, readBufferSize int, localTimeout int, remoteTimeout int, ioMode recio.IOMode) (tp *TCPPeer) {
 
 	messageWriter := NewMessageWriter(conn, writeBufferSize, recio.ModeManual)
 	messageReader := NewMessageReader(conn, readBufferSize, recio.ModeManual)
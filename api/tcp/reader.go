@@ -10,36 +10,42 @@
 package tcp
 
 import (
-	"net"
-
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/recio"
 )
 
 type TCPReader struct {
-	conn         *net.TCPConn
-	ioMode       recio.IOMode
-	tcpPeer      *TCPPeer
-	ackMessage   *AckMessage
-	errorMessage *ErrorMessage
-	messageIn    *Message
-	messageOut   *Message
-	mustFill     bool
+	conn            Conn
+	ioMode          recio.IOMode
+	tcpPeer         *TCPPeer
+	ackMessage      *AckMessage
+	errorMessage    *ErrorMessage
+	commitMessage   *CommitMessage
+	messageIn       *Message
+	messageOut      *Message
+	mustFill        bool
+	positionHandler PositionHandler
 }
 
-func NewTCPReader(conn *net.TCPConn, writeBufferSize int, readBufferSize int, localTimeout int, remoteTimeout int, ioMode recio.IOMode) (tr *TCPReader) {
+// PositionHandler is called with the position of the next record to be read,
+// when the remote peer skipped records.
+type PositionHandler func(position int64)
+
+func NewTCPReader(conn Conn, writeBufferSize int, readBufferSize int, localTimeout int, remoteTimeout int, ioMode recio.IOMode) (tr *TCPReader) {
 
 	tcpPeer := NewTCPPeer(conn, writeBufferSize, readBufferSize, localTimeout, remoteTimeout, ioMode)
 
 	tr = &TCPReader{
-		conn:         conn,
-		ioMode:       ioMode,
-		tcpPeer:      tcpPeer,
-		ackMessage:   &AckMessage{},
-		errorMessage: &ErrorMessage{},
-		messageIn:    &Message{},
-		messageOut:   &Message{},
-		mustFill:     false,
+		conn:            conn,
+		ioMode:          ioMode,
+		tcpPeer:         tcpPeer,
+		ackMessage:      &AckMessage{},
+		errorMessage:    &ErrorMessage{},
+		commitMessage:   &CommitMessage{},
+		messageIn:       &Message{},
+		messageOut:      &Message{},
+		mustFill:        false,
+		positionHandler: nil,
 	}
 
 	return tr
@@ -47,14 +53,17 @@ func NewTCPReader(conn *net.TCPConn, writeBufferSize int, readBufferSize int, lo
 
 func (tr *TCPReader) Close() (err error) {
 
+	// Close the connection first so that a pending fill, which holds the
+	// peer fill lock, returns and lets the peer close.
+	connErr := tr.conn.Close()
+
 	err = tr.tcpPeer.Close()
 	if err != nil {
 		return err
 	}
 
-	err = tr.conn.Close()
-	if err != nil {
-		return err
+	if connErr != nil {
+		return connErr
 	}
 
 	return nil
@@ -76,9 +85,26 @@ func (tr *TCPReader) WriteAck(progress *log.SyncProgress) (n int, err error) {
 	return n, nil
 }
 
+// WriteCommit asks the remote peer to commit position for the consumer group
+// the connection was opened with.
+func (tr *TCPReader) WriteCommit(position int64) (n int, err error) {
+
+	tr.commitMessage.Position = position
+
+	tr.messageOut.Type = TypeCommitMessage
+	tr.messageOut.Payload = tr.commitMessage
+
+	n, err = tr.tcpPeer.WriteMessage(tr.messageOut)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
 func (tr *TCPReader) WriteError(er error) (n int, err error) {
 
-	tr.errorMessage.Code = GetErrorCode(err)
+	tr.errorMessage.Code = GetErrorCode(er)
 
 	tr.messageOut.Type = TypeErrorMessage
 	tr.messageOut.Payload = tr.errorMessage
@@ -156,6 +182,13 @@ Retry:
 		autoFill = true
 		goto Retry
 
+	case *PositionMessage:
+		if tr.positionHandler != nil {
+			tr.positionHandler(v.Position)
+		}
+
+		goto Retry
+
 	default:
 		return 0, ErrUnexpectedMessageType
 	}
@@ -163,6 +196,11 @@ Retry:
 	return n, nil
 }
 
+func (tr *TCPReader) HandlePosition(h PositionHandler) {
+
+	tr.positionHandler = h
+}
+
 func (tr *TCPReader) HandleError(h ErrorHandler) {
 
 	tr.tcpPeer.errorHandler = h
@@ -11,40 +11,47 @@ package tcp
 
 import (
 	"io"
-	"net"
 
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/recio"
 )
 
 type TCPWriter struct {
-	conn          *net.TCPConn
-	ioMode        recio.IOMode
-	tcpPeer       *TCPPeer
-	recordMessage *RecordMessage
-	errorMessage  *ErrorMessage
-	messageIn     *Message
-	messageOut    *Message
-	readerDone    chan struct{}
-	syncHandler   log.SyncHandler
-	errorHandler  ErrorHandler
+	conn            Conn
+	ioMode          recio.IOMode
+	tcpPeer         *TCPPeer
+	recordMessage   *RecordMessage
+	errorMessage    *ErrorMessage
+	positionMessage *PositionMessage
+	messageIn       *Message
+	messageOut      *Message
+	readerDone      chan struct{}
+	syncHandler     log.SyncHandler
+	commitHandler   CommitHandler
+	errorHandler    ErrorHandler
+	producerID      string
+	sequence        int64
 }
 
-func NewTCPWriter(conn *net.TCPConn, writeBufferSize int, readBufferSize int, localTimeout int, remoteTimeout int, ioMode recio.IOMode) (tw *TCPWriter) {
+type CommitHandler func(position int64) (err error)
+
+func NewTCPWriter(conn Conn, writeBufferSize int, readBufferSize int, localTimeout int, remoteTimeout int, ioMode recio.IOMode) (tw *TCPWriter) {
 
 	tcpPeer := NewTCPPeer(conn, writeBufferSize, readBufferSize, localTimeout, remoteTimeout, ioMode)
 
 	tw = &TCPWriter{
-		conn:          conn,
-		ioMode:        ioMode,
-		tcpPeer:       tcpPeer,
-		recordMessage: &RecordMessage{},
-		errorMessage:  &ErrorMessage{},
-		messageIn:     &Message{},
-		messageOut:    &Message{},
-		readerDone:    make(chan struct{}),
-		syncHandler:   nil,
-		errorHandler:  nil,
+		conn:            conn,
+		ioMode:          ioMode,
+		tcpPeer:         tcpPeer,
+		recordMessage:   &RecordMessage{},
+		errorMessage:    &ErrorMessage{},
+		positionMessage: &PositionMessage{},
+		messageIn:       &Message{},
+		messageOut:      &Message{},
+		readerDone:      make(chan struct{}),
+		syncHandler:     nil,
+		commitHandler:   nil,
+		errorHandler:    nil,
 	}
 
 	go tw.reader()
@@ -74,10 +81,29 @@ func (tw *TCPWriter) Close() (err error) {
 	return nil
 }
 
+// SetProducer stamps records written from now on with the producer id and
+// consecutive sequences starting at sequence, so that the log drops records
+// it already holds when they are written again.
+func (tw *TCPWriter) SetProducer(id string, sequence int64) {
+
+	tw.producerID = id
+	tw.sequence = sequence
+}
+
+// Sequence returns the sequence of the next written record.
+func (tw *TCPWriter) Sequence() (sequence int64) {
+
+	return tw.sequence
+}
+
 func (tw *TCPWriter) Write(r *log.Record) (n int, err error) {
 
 	tw.recordMessage.Record = *r
 
+	if tw.producerID != "" {
+		tw.recordMessage.Record.SetProducer(tw.producerID, tw.sequence)
+	}
+
 	tw.messageOut.Type = TypeRecordMessage
 	tw.messageOut.Payload = tw.recordMessage
 
@@ -86,6 +112,27 @@ func (tw *TCPWriter) Write(r *log.Record) (n int, err error) {
 		return 0, err
 	}
 
+	if tw.producerID != "" {
+		tw.sequence++
+	}
+
+	return n, nil
+}
+
+// WritePosition tells the remote peer the position of the next record it
+// will receive, when records were skipped.
+func (tw *TCPWriter) WritePosition(position int64) (n int, err error) {
+
+	tw.positionMessage.Position = position
+
+	tw.messageOut.Type = TypePositionMessage
+	tw.messageOut.Payload = tw.positionMessage
+
+	n, err = tw.tcpPeer.WriteMessage(tw.messageOut)
+	if err != nil {
+		return 0, err
+	}
+
 	return n, nil
 }
 
@@ -104,6 +151,30 @@ func (tw *TCPWriter) WriteError(er error) (n int, err error) {
 	return n, nil
 }
 
+// writeCommitError sends an error message for a failed commit. It runs in
+// the reader goroutine and thus uses its own message.
+func (tw *TCPWriter) writeCommitError(er error) (err error) {
+
+	m := &Message{
+		Type: TypeErrorMessage,
+		Payload: &ErrorMessage{
+			Code: GetErrorCode(er),
+		},
+	}
+
+	_, err = tw.tcpPeer.WriteMessage(m)
+	if err != nil && err != recio.ErrMustFlush {
+		return err
+	}
+
+	err = tw.tcpPeer.Flush()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (tw *TCPWriter) Flush() (err error) {
 
 	err = tw.tcpPeer.Flush()
@@ -119,6 +190,11 @@ func (tw *TCPWriter) HandleSync(h log.SyncHandler) {
 	tw.syncHandler = h
 }
 
+func (tw *TCPWriter) HandleCommit(h CommitHandler) {
+
+	tw.commitHandler = h
+}
+
 func (tw *TCPWriter) HandleError(h ErrorHandler) {
 
 	tw.errorHandler = h
@@ -165,6 +241,30 @@ func (tw *TCPWriter) reader() {
 
 			continue
 
+		case *CommitMessage:
+
+			if tw.commitHandler == nil {
+				if tw.errorHandler != nil {
+					tw.errorHandler(ErrUnexpectedMessageType)
+				}
+
+				continue
+			}
+
+			err = tw.commitHandler(v.Position)
+			if err != nil {
+				// A failed commit doesn't end the stream, tell
+				// the remote peer why it failed.
+				err = tw.writeCommitError(err)
+				if err != nil {
+					if tw.errorHandler != nil {
+						tw.errorHandler(err)
+					}
+				}
+			}
+
+			continue
+
 		case *ErrorMessage:
 			err = GetErrorMessage(v.Code)
 
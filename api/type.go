@@ -12,19 +12,43 @@ package api
 import (
 	"errors"
 
+	"gitlab.com/dataptive/styx/filter"
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/pipeline"
 )
 
 const (
-	TimeoutHeaderName     = "X-Styx-Timeout"
-	RecordLinesMediaType  = "application/vnd.styx.line-delimited"
-	RecordBinaryMediaType = "application/vnd.styx.binary-records"
-	StyxProtocolString    = "styx/0"
+	TimeoutHeaderName          = "X-Styx-Timeout"
+	PositionHeaderName         = "X-Styx-Position"
+	NextPositionHeaderName     = "X-Styx-Next-Position"
+	RecordKeyHeaderName        = "X-Styx-Record-Key"
+	RecordTimestampHeaderName  = "X-Styx-Record-Timestamp"
+	RecordHeaderPrefix         = "X-Styx-Record-Header-"
+	RecordLinesMediaType       = "application/vnd.styx.line-delimited"
+	RecordBinaryMediaType      = "application/vnd.styx.binary-records"
+	RecordEventsMediaType      = "text/event-stream"
+	LastEventIDHeaderName      = "Last-Event-ID"
+	ProducerIDHeaderName       = "X-Styx-Producer-Id"
+	ProducerSequenceHeaderName = "X-Styx-Producer-Sequence"
+	AtomicLogHeaderName        = "Styx-Log"
+	RecordsWSSubprotocol       = "styx.binary-records"
+	StyxProtocolString         = "styx/0"
+	CapabilitiesHeaderName     = "X-Styx-Capabilities"
+	PositionCapability         = "position"
+)
+
+const (
+	// Seek from the position committed by a consumer group.
+	SeekCommitted log.Whence = "committed"
 )
 
 var (
-	ErrInvalidWhence = errors.New("invalid whence")
+	ErrInvalidWhence    = errors.New("invalid whence")
+	ErrInvalidTimestamp = errors.New("invalid record timestamp")
+	ErrMissingGroup     = errors.New("missing group")
+	ErrMissingLog       = errors.New("missing record log")
+	ErrTruncateBoundary = errors.New("only one of before_position and before_timestamp can be set")
 )
 
 type LogInfo struct {
@@ -37,14 +61,17 @@ type LogInfo struct {
 }
 
 type LogConfig struct {
-	MaxRecordSize   int   `schema:"max_record_size"`
-	IndexAfterSize  int64 `schema:"index_after_size"`
-	SegmentMaxCount int64 `schema:"segment_max_count"`
-	SegmentMaxSize  int64 `schema:"segment_max_size"`
-	SegmentMaxAge   int64 `schema:"segment_max_age"`
-	LogMaxCount     int64 `schema:"log_max_count"`
-	LogMaxSize      int64 `schema:"log_max_size"`
-	LogMaxAge       int64 `schema:"log_max_age"`
+	MaxRecordSize   int             `schema:"max_record_size"`
+	IndexAfterSize  int64           `schema:"index_after_size"`
+	SegmentMaxCount int64           `schema:"segment_max_count"`
+	SegmentMaxSize  int64           `schema:"segment_max_size"`
+	SegmentMaxAge   int64           `schema:"segment_max_age"`
+	LogMaxCount     int64           `schema:"log_max_count"`
+	LogMaxSize      int64           `schema:"log_max_size"`
+	LogMaxAge       int64           `schema:"log_max_age"`
+	LogCompaction   bool            `schema:"log_compaction"`
+	TombstoneMaxAge int64           `schema:"tombstone_max_age"`
+	Compression     log.Compression `schema:"compression"`
 }
 
 type ListLogsResponse []LogInfo
@@ -55,24 +82,202 @@ type CreateLogForm struct {
 }
 type CreateLogResponse LogInfo
 
+// UpdateLogForm holds the settings to change, nil settings are left
+// untouched.
+type UpdateLogForm struct {
+	SegmentMaxCount *int64           `schema:"segment_max_count,omitempty"`
+	SegmentMaxSize  *int64           `schema:"segment_max_size,omitempty"`
+	SegmentMaxAge   *int64           `schema:"segment_max_age,omitempty"`
+	LogMaxCount     *int64           `schema:"log_max_count,omitempty"`
+	LogMaxSize      *int64           `schema:"log_max_size,omitempty"`
+	LogMaxAge       *int64           `schema:"log_max_age,omitempty"`
+	LogCompaction   *bool            `schema:"log_compaction,omitempty"`
+	TombstoneMaxAge *int64           `schema:"tombstone_max_age,omitempty"`
+	Compression     *log.Compression `schema:"compression,omitempty"`
+}
+type UpdateLogResponse LogInfo
+
 type GetLogResponse LogInfo
 
 type RestoreLogParams struct {
 	Name string `schema:"name,required"`
 }
 
+type RepairLogParams struct {
+	DryRun bool `schema:"dry_run"`
+}
+
+type RepairLogResponse struct {
+	Torn                bool  `json:"torn"`
+	Repaired            bool  `json:"repaired"`
+	Position            int64 `json:"position"`
+	Offset              int64 `json:"offset"`
+	TruncatedBytes      int64 `json:"truncated_bytes"`
+	TruncatedIndexBytes int64 `json:"truncated_index_bytes"`
+}
+
+// TruncateLogParams holds the boundary before which whole segments are
+// deleted. Logs are emptied when no boundary is set. BeforeTimestamp is a Unix
+// timestamp in seconds, compared with write times.
+type TruncateLogParams struct {
+	BeforePosition  *int64 `schema:"before_position"`
+	BeforeTimestamp *int64 `schema:"before_timestamp"`
+}
+
+func (p TruncateLogParams) Validate() (err error) {
+
+	if p.BeforePosition != nil && p.BeforeTimestamp != nil {
+		return ErrTruncateBoundary
+	}
+
+	return nil
+}
+
+type TruncateLogResponse LogInfo
+
+type RenameLogForm struct {
+	Name string `schema:"name,required"`
+}
+type RenameLogResponse LogInfo
+
+type CopyLogForm struct {
+	Name string `schema:"name,required"`
+}
+type CopyLogResponse LogInfo
+
+type GroupInfo struct {
+	Name     string `json:"name"`
+	Position int64  `json:"position"`
+}
+
+type ListGroupsResponse []GroupInfo
+
+type GetGroupResponse GroupInfo
+
+type CommitGroupForm struct {
+	Position int64 `schema:"position,required"`
+}
+
+type CommitGroupResponse GroupInfo
+
+type ReaderInfo struct {
+	Protocol      string `json:"protocol"`
+	RemoteAddress string `json:"remote_address"`
+	Position      int64  `json:"position"`
+	Lag           int64  `json:"lag"`
+	LagBytes      int64  `json:"lag_bytes"`
+}
+
+type ListReadersResponse []ReaderInfo
+
+type ReplicaInfo struct {
+	Name           string `json:"name"`
+	State          string `json:"state"`
+	LeaderPosition int64  `json:"leader_position"`
+	Position       int64  `json:"position"`
+	Lag            int64  `json:"lag"`
+}
+
+type ReplicationStatusResponse struct {
+	LeaderAddress string        `json:"leader_address"`
+	Logs          []ReplicaInfo `json:"logs"`
+}
+
+type PipelineInfo struct {
+	Name        string             `json:"name"`
+	Source      string             `json:"source"`
+	Destination string             `json:"destination"`
+	Transform   pipeline.Transform `json:"transform"`
+	Path        string             `json:"path,omitempty"`
+	Value       string             `json:"value,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Rate        float64            `json:"rate,omitempty"`
+	Static      bool               `json:"static"`
+	State       pipeline.State     `json:"state"`
+	Position    int64              `json:"position"`
+	Lag         int64              `json:"lag"`
+}
+
+type ListPipelinesResponse []PipelineInfo
+
+type CreatePipelineForm struct {
+	Name        string             `schema:"name,required"`
+	Source      string             `schema:"source,required"`
+	Destination string             `schema:"destination,required"`
+	Transform   pipeline.Transform `schema:"transform,required"`
+	Path        string             `schema:"path"`
+	Value       string             `schema:"value"`
+	Pattern     string             `schema:"pattern"`
+	Rate        float64            `schema:"rate"`
+}
+type CreatePipelineResponse PipelineInfo
+
+type GetPipelineResponse PipelineInfo
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+type InfoResponse struct {
+	Version       string           `json:"version"`
+	Uptime        int64            `json:"uptime"`
+	Ready         bool             `json:"ready"`
+	DataDirectory string           `json:"data_directory"`
+	DiskFree      int64            `json:"disk_free"`
+	LogStatuses   map[string]int64 `json:"log_statuses"`
+}
+
+// WriteRecordParams holds the write condition, records are only written
+// when the log ends at ExpectedPosition if set.
+type WriteRecordParams struct {
+	ExpectedPosition *int64 `schema:"expected_position,omitempty"`
+}
+
 type WriteRecordResponse struct {
 	Position int64 `json:"position"`
 	Count    int64 `json:"count"`
 }
 
+// RecordFilterParams holds the conditions read records must all match. Other
+// records are skipped, but still advance the read position.
+type RecordFilterParams struct {
+	Contains    string `schema:"filter_contains,omitempty"`
+	Pattern     string `schema:"filter_pattern,omitempty"`
+	Field       string `schema:"filter_field,omitempty"`
+	Value       string `schema:"filter_value,omitempty"`
+	Header      string `schema:"filter_header,omitempty"`
+	HeaderValue string `schema:"filter_header_value,omitempty"`
+}
+
+// Filter returns the filter described by the params, which is nil when no
+// condition is set.
+func (p RecordFilterParams) Filter() (f *filter.Filter, err error) {
+
+	config := filter.Config{
+		Contains:    p.Contains,
+		Pattern:     p.Pattern,
+		Path:        p.Field,
+		Value:       p.Value,
+		Header:      p.Header,
+		HeaderValue: p.HeaderValue,
+	}
+
+	f, err = filter.New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
 type ReadRecordParams struct {
 	Whence   log.Whence `schema:"whence"`
 	Position int64      `schema:"position"`
+	Group    string     `schema:"group"`
 }
 
 func (p ReadRecordParams) Validate() (err error) {
-	err = validateWhence(p.Whence)
+	err = validateWhence(p.Whence, p.Group)
 
 	if err != nil {
 		return err
@@ -81,6 +286,8 @@ func (p ReadRecordParams) Validate() (err error) {
 	return nil
 }
 
+type WriteRecordsBatchParams WriteRecordParams
+
 type WriteRecordsBatchResponse WriteRecordResponse
 
 type ReadRecordsBatchParams struct {
@@ -88,10 +295,12 @@ type ReadRecordsBatchParams struct {
 	Position int64      `schema:"position"`
 	Count    int64      `schema:"count"`
 	Follow   bool       `schema:"follow"`
+	Group    string     `schema:"group"`
+	RecordFilterParams
 }
 
 func (p ReadRecordsBatchParams) Validate() (err error) {
-	err = validateWhence(p.Whence)
+	err = validateWhence(p.Whence, p.Group)
 
 	if err != nil {
 		return err
@@ -102,10 +311,26 @@ func (p ReadRecordsBatchParams) Validate() (err error) {
 
 type WriteRecordsLinesResponse WriteRecordResponse
 
+// WriteAtomicResponse holds the position following the written records of
+// each log.
+type WriteAtomicResponse map[string]int64
+
 type ReadRecordsLinesParams ReadRecordsBatchParams
 
 func (p ReadRecordsLinesParams) Validate() (err error) {
-	err = validateWhence(p.Whence)
+	err = validateWhence(p.Whence, p.Group)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type ReadRecordsEventsParams ReadRecordsBatchParams
+
+func (p ReadRecordsEventsParams) Validate() (err error) {
+	err = validateWhence(p.Whence, p.Group)
 
 	if err != nil {
 		return err
@@ -119,10 +344,12 @@ type ReadRecordsTCPParams struct {
 	Position int64      `schema:"position"`
 	Count    int64      `schema:"count"`
 	Follow   bool       `schema:"follow"`
+	Group    string     `schema:"group"`
+	RecordFilterParams
 }
 
 func (p ReadRecordsTCPParams) Validate() (err error) {
-	err = validateWhence(p.Whence)
+	err = validateWhence(p.Whence, p.Group)
 
 	if err != nil {
 		return err
@@ -134,7 +361,7 @@ func (p ReadRecordsTCPParams) Validate() (err error) {
 type ReadRecordsWSParams ReadRecordsTCPParams
 
 func (p ReadRecordsWSParams) Validate() (err error) {
-	err = validateWhence(p.Whence)
+	err = validateWhence(p.Whence, p.Group)
 
 	if err != nil {
 		return err
@@ -143,13 +370,15 @@ func (p ReadRecordsWSParams) Validate() (err error) {
 	return nil
 }
 
-func validateWhence(whence log.Whence) (err error) {
+func validateWhence(whence log.Whence, group string) (err error) {
 
 	validWhences := []log.Whence{
 		log.SeekOrigin,
 		log.SeekStart,
 		log.SeekCurrent,
 		log.SeekEnd,
+		log.SeekTimestamp,
+		SeekCommitted,
 	}
 
 	found := false
@@ -165,5 +394,9 @@ func validateWhence(whence log.Whence) (err error) {
 		return ErrInvalidWhence
 	}
 
+	if whence == SeekCommitted && group == "" {
+		return ErrMissingGroup
+	}
+
 	return nil
 }
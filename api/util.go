@@ -13,6 +13,11 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gitlab.com/dataptive/styx/log"
 )
 
 func WriteResponse(w http.ResponseWriter, statusCode int, v interface{}) {
@@ -74,3 +79,91 @@ func MarshalJson(v interface{}) (bytes []byte, err error) {
 
 	return bytes, nil
 }
+
+// WriteRecordHeaders sets HTTP headers carrying the record metadata, used
+// along with the application/octet-stream media type.
+func WriteRecordHeaders(h http.Header, r *log.Record) {
+
+	if len(r.Key) != 0 {
+		h.Set(RecordKeyHeaderName, string(r.Key))
+	}
+
+	if r.Timestamp != 0 {
+		h.Set(RecordTimestampHeaderName, strconv.FormatInt(r.Timestamp, 10))
+	}
+
+	for _, header := range r.Headers {
+		h.Add(RecordHeaderPrefix+header.Name, header.Value)
+	}
+}
+
+// ReadRecordHeaders sets the record metadata from HTTP headers. Record header
+// names are returned in their canonical HTTP form, as CanonicalRecordHeaders
+// does on other write routes. The timestamp is the one supplied by the client,
+// records without the timestamp header have none.
+func ReadRecordHeaders(h http.Header, r *log.Record) (err error) {
+
+	r.Key = nil
+	r.Timestamp = 0
+	r.Headers = nil
+
+	key := h.Get(RecordKeyHeaderName)
+	if key != "" {
+		r.Key = []byte(key)
+	}
+
+	timestamp := h.Get(RecordTimestampHeaderName)
+	if timestamp != "" {
+		r.Timestamp, err = strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return ErrInvalidTimestamp
+		}
+	}
+
+	// Sort header names, since map iteration order is random.
+	names := []string{}
+	for name := range h {
+		if strings.HasPrefix(name, RecordHeaderPrefix) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range h[name] {
+			r.Headers = append(r.Headers, log.Header{
+				Name:  strings.TrimPrefix(name, RecordHeaderPrefix),
+				Value: value,
+			})
+		}
+	}
+
+	return nil
+}
+
+// CanonicalRecordHeaders converts record header names to their canonical HTTP
+// form, so that names are stored the same way whatever the write route.
+func CanonicalRecordHeaders(r *log.Record) {
+
+	for i := range r.Headers {
+		r.Headers[i].Name = http.CanonicalHeaderKey(r.Headers[i].Name)
+	}
+}
+
+// ReadProducerHeaders returns the producer id and the sequence of the first
+// record written by a request, id is empty when the request has no producer.
+func ReadProducerHeaders(h http.Header) (id string, sequence int64, err error) {
+
+	id = h.Get(ProducerIDHeaderName)
+	if id == "" {
+		return "", 0, nil
+	}
+
+	sequence, err = strconv.ParseInt(h.Get(ProducerSequenceHeaderName), 10, 64)
+	if err != nil {
+		return "", 0, log.ErrInvalidSequence
+	}
+
+	return id, sequence, nil
+}
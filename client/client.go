@@ -11,12 +11,17 @@ package client
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 
 	"gitlab.com/dataptive/styx/api"
 	"gitlab.com/dataptive/styx/api/tcp"
@@ -31,24 +36,195 @@ const (
 	readBufferSize  = 1 << 20 // 1MB
 )
 
+var (
+	ErrInvalidCAFile = errors.New("client: no certificate found in CA file")
+)
+
 type RecordsWriterHandler func(w recio.Writer) (err error)
 type RecordsReaderHandler func(w recio.Reader) (err error)
 
 type Client struct {
 	baseURL    string
+	token      string
+	tlsConfig  *tls.Config
 	httpClient *http.Client
 }
 
+// tokenTransport sets the bearer token on requests before passing them
+// to the underlying transport.
+type tokenTransport struct {
+	token     string
+	transport http.RoundTripper
+}
+
 func NewClient(baseURL string) (c *Client) {
 
 	c = &Client{
 		baseURL:    baseURL,
+		token:      "",
+		tlsConfig:  nil,
 		httpClient: &http.Client{},
 	}
 
 	return c
 }
 
+// NewTLSConfig returns a TLS config trusting the PEM certificates of caFile
+// and presenting the certFile and keyFile client certificate. Empty file
+// names are ignored.
+func NewTLSConfig(caFile string, certFile string, keyFile string) (config *tls.Config, err error) {
+
+	config = &tls.Config{}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, ErrInvalidCAFile
+		}
+
+		config.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// SetToken sets the token used to authenticate requests.
+func (c *Client) SetToken(token string) {
+
+	c.token = token
+	c.httpClient.Transport = c.transport(c.httpTransport())
+}
+
+// SetTLSConfig sets the TLS config used to connect to https servers.
+func (c *Client) SetTLSConfig(config *tls.Config) {
+
+	c.tlsConfig = config
+	c.httpClient.Transport = c.transport(c.httpTransport())
+}
+
+func (c *Client) httpTransport() (t http.RoundTripper) {
+
+	if c.tlsConfig == nil {
+		return http.DefaultTransport
+	}
+
+	ht := http.DefaultTransport.(*http.Transport).Clone()
+	ht.TLSClientConfig = c.tlsConfig
+
+	return ht
+}
+
+// upgradeTransport returns a transport keeping the connection it dials, for
+// the Styx protocol to run on once the connection is upgraded.
+func (c *Client) upgradeTransport(conn *tcp.Conn) (t http.RoundTripper) {
+
+	dial := func(network string, address string) (nc net.Conn, err error) {
+
+		nc, err = net.Dial(network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		*conn = nc.(*net.TCPConn)
+
+		return nc, nil
+	}
+
+	dialTLS := func(network string, address string) (nc net.Conn, err error) {
+
+		config := &tls.Config{}
+		if c.tlsConfig != nil {
+			config = c.tlsConfig.Clone()
+		}
+
+		if config.ServerName == "" {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, err
+			}
+
+			config.ServerName = host
+		}
+
+		tlsConn, err := tls.Dial(network, address, config)
+		if err != nil {
+			return nil, err
+		}
+
+		*conn = tlsConn
+
+		return tlsConn, nil
+	}
+
+	ht := &http.Transport{
+		Dial:    dial,
+		DialTLS: dialTLS,
+	}
+
+	return c.transport(ht)
+}
+
+// upgradedConn is a connection upgraded to the Styx protocol. Bytes sent by
+// the server right after the upgrade response may have been buffered by the
+// HTTP transport, and are read from the response body first.
+type upgradedConn struct {
+	tcp.Conn
+	body io.Reader
+}
+
+func newUpgradedConn(conn tcp.Conn, resp *http.Response) (uc *upgradedConn) {
+
+	uc = &upgradedConn{
+		Conn: conn,
+		body: resp.Body,
+	}
+
+	return uc
+}
+
+func (uc *upgradedConn) Read(p []byte) (n int, err error) {
+
+	return uc.body.Read(p)
+}
+
+func (c *Client) transport(t http.RoundTripper) (rt http.RoundTripper) {
+
+	if c.token == "" {
+		return t
+	}
+
+	rt = &tokenTransport{
+		token:     c.token,
+		transport: t,
+	}
+
+	return rt
+}
+
+func (tt *tokenTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+
+	// Round trippers must not modify the original request.
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+tt.token)
+
+	return tt.transport.RoundTrip(req)
+}
+
 func (c *Client) ListLogs() (r api.ListLogsResponse, err error) {
 
 	endpoint := c.baseURL + "/logs"
@@ -122,6 +298,42 @@ func (c *Client) GetLog(name string) (r api.GetLogResponse, err error) {
 	return r, nil
 }
 
+func (c *Client) UpdateLog(name string, logForm api.UpdateLogForm) (r api.UpdateLogResponse, err error) {
+
+	endpoint := c.baseURL + "/logs/" + name
+
+	encoder := schema.NewEncoder()
+
+	form := url.Values{}
+
+	err = encoder.Encode(logForm, form)
+	if err != nil {
+		return r, err
+	}
+
+	req, err := http.NewRequest(http.MethodPatch, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return r, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
 func (c *Client) DeleteLog(name string) (err error) {
 
 	endpoint := c.baseURL + "/logs/" + name
@@ -145,27 +357,125 @@ func (c *Client) DeleteLog(name string) (err error) {
 	return nil
 }
 
-func (c *Client) TruncateLog(name string) (err error) {
+// TruncateLog empties a log, or only deletes its segments preceding the
+// boundary set in params, and returns the log info after truncation.
+func (c *Client) TruncateLog(name string, params api.TruncateLogParams) (r api.TruncateLogResponse, err error) {
+
+	query := url.Values{}
+
+	if params.BeforePosition != nil {
+		query.Set("before_position", strconv.FormatInt(*params.BeforePosition, 10))
+	}
+
+	if params.BeforeTimestamp != nil {
+		query.Set("before_timestamp", strconv.FormatInt(*params.BeforeTimestamp, 10))
+	}
 
-	endpoint := c.baseURL + "/logs/" + name + "/truncate"
+	endpoint := c.baseURL + "/logs/" + name + "/truncate?" + query.Encode()
 
 	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
 	if err != nil {
-		return err
+		return r, err
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return r, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		err = api.ReadError(resp.Body)
-		return err
+		return r, err
 	}
 
-	return nil
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
+func (c *Client) RepairLog(name string, dryRun bool) (r api.RepairLogResponse, err error) {
+
+	endpoint := c.baseURL + "/logs/" + name + "/repair?dry_run=" + strconv.FormatBool(dryRun)
+
+	resp, err := c.httpClient.Post(endpoint, "", nil)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
+func (c *Client) RenameLog(name string, newName string) (r api.RenameLogResponse, err error) {
+
+	endpoint := c.baseURL + "/logs/" + name + "/rename"
+
+	encoder := schema.NewEncoder()
+
+	logForm := api.RenameLogForm{
+		Name: newName,
+	}
+	form := url.Values{}
+
+	err = encoder.Encode(logForm, form)
+	if err != nil {
+		return r, err
+	}
+
+	resp, err := c.httpClient.PostForm(endpoint, form)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
+func (c *Client) CopyLog(name string, newName string) (r api.CopyLogResponse, err error) {
+
+	endpoint := c.baseURL + "/logs/" + name + "/copy"
+
+	encoder := schema.NewEncoder()
+
+	logForm := api.CopyLogForm{
+		Name: newName,
+	}
+	form := url.Values{}
+
+	err = encoder.Encode(logForm, form)
+	if err != nil {
+		return r, err
+	}
+
+	resp, err := c.httpClient.PostForm(endpoint, form)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
 }
 
 func (c *Client) BackupLog(name string, w io.Writer) (err error) {
@@ -214,14 +524,238 @@ func (c *Client) RestoreLog(name string, r io.Reader) (err error) {
 	return nil
 }
 
-func (c *Client) WriteRecord(logName string, record log.Record) (r api.WriteRecordResponse, err error) {
+func (c *Client) ListGroups(logName string) (r api.ListGroupsResponse, err error) {
 
-	endpoint := c.baseURL + "/logs/" + logName + "/records"
+	endpoint := c.baseURL + "/logs/" + logName + "/groups"
 
-	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(record))
+	resp, err := c.httpClient.Get(endpoint)
 	if err != nil {
 		return r, err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
+func (c *Client) ListReaders(logName string) (r api.ListReadersResponse, err error) {
+
+	endpoint := c.baseURL + "/logs/" + logName + "/readers"
+
+	resp, err := c.httpClient.Get(endpoint)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
+func (c *Client) GetGroup(logName string, group string) (r api.GetGroupResponse, err error) {
+
+	endpoint := c.baseURL + "/logs/" + logName + "/groups/" + group
+
+	resp, err := c.httpClient.Get(endpoint)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
+func (c *Client) CommitGroup(logName string, group string, position int64) (r api.CommitGroupResponse, err error) {
+
+	endpoint := c.baseURL + "/logs/" + logName + "/groups/" + group
+
+	encoder := schema.NewEncoder()
+
+	commitForm := api.CommitGroupForm{
+		Position: position,
+	}
+	form := url.Values{}
+
+	err = encoder.Encode(commitForm, form)
+	if err != nil {
+		return r, err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return r, err
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
+func (c *Client) DeleteGroup(logName string, group string) (err error) {
+
+	endpoint := c.baseURL + "/logs/" + logName + "/groups/" + group
+
+	req, err := http.NewRequest(http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return err
+	}
+
+	return nil
+}
+
+func (c *Client) ListPipelines() (r api.ListPipelinesResponse, err error) {
+
+	endpoint := c.baseURL + "/pipelines"
+
+	resp, err := c.httpClient.Get(endpoint)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
+func (c *Client) CreatePipeline(pipelineForm api.CreatePipelineForm) (r api.CreatePipelineResponse, err error) {
+
+	endpoint := c.baseURL + "/pipelines"
+
+	encoder := schema.NewEncoder()
+
+	form := url.Values{}
+
+	err = encoder.Encode(pipelineForm, form)
+	if err != nil {
+		return r, err
+	}
+
+	resp, err := c.httpClient.PostForm(endpoint, form)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
+func (c *Client) GetPipeline(name string) (r api.GetPipelineResponse, err error) {
+
+	endpoint := c.baseURL + "/pipelines/" + name
+
+	resp, err := c.httpClient.Get(endpoint)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
+func (c *Client) DeletePipeline(name string) (err error) {
+
+	endpoint := c.baseURL + "/pipelines/" + name
+
+	req, err := http.NewRequest(http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return err
+	}
+
+	return nil
+}
+
+func (c *Client) WriteRecord(logName string, record log.Record, params api.WriteRecordParams) (r api.WriteRecordResponse, err error) {
+
+	encoder := schema.NewEncoder()
+	queryParams := url.Values{}
+
+	err = encoder.Encode(params, queryParams)
+	if err != nil {
+		return r, err
+	}
+
+	endpoint := c.baseURL + "/logs/" + logName + "/records?" + queryParams.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(record.Payload))
+	if err != nil {
+		return r, err
+	}
+
+	api.WriteRecordHeaders(req.Header, &record)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -267,23 +801,36 @@ func (c *Client) ReadRecord(logName string, params api.ReadRecordParams) (r log.
 		return r, err
 	}
 
-	test, err := ioutil.ReadAll(resp.Body)
+	payload, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return r, err
+	}
+
+	err = api.ReadRecordHeaders(resp.Header, &r)
 	if err != nil {
 		return r, err
 	}
 
-	r = log.Record(test)
+	r.Payload = payload
 
 	return r, nil
 }
 
-func (c *Client) WriteRecordsBatch(logName string, bufferSize int, fn RecordsWriterHandler) (r api.WriteRecordsBatchResponse, err error) {
+func (c *Client) WriteRecordsBatch(logName string, params api.WriteRecordsBatchParams, bufferSize int, fn RecordsWriterHandler) (r api.WriteRecordsBatchResponse, err error) {
+
+	encoder := schema.NewEncoder()
+	queryParams := url.Values{}
+
+	err = encoder.Encode(params, queryParams)
+	if err != nil {
+		return r, err
+	}
 
 	pipeReader, pipeWriter := io.Pipe()
 
 	bufferedWriter := recio.NewBufferedWriter(pipeWriter, bufferSize, recio.ModeAuto)
 
-	endpoint := c.baseURL + "/logs/" + logName + "/records"
+	endpoint := c.baseURL + "/logs/" + logName + "/records?" + queryParams.Encode()
 
 	req, err := http.NewRequest(http.MethodPost, endpoint, pipeReader)
 	if err != nil {
@@ -325,6 +872,67 @@ func (c *Client) WriteRecordsBatch(logName string, bufferSize int, fn RecordsWri
 	return r, err
 }
 
+// WriteAtomic writes records to several logs, all or none.
+func (c *Client) WriteAtomic(records map[string][]log.Record) (r api.WriteAtomicResponse, err error) {
+
+	names := []string{}
+	for name := range records {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	buffer := &bytes.Buffer{}
+	bufferedWriter := recio.NewBufferedWriter(buffer, writeBufferSize, recio.ModeAuto)
+
+	for _, name := range names {
+		for _, record := range records[name] {
+
+			// Copy headers to leave the caller records untouched.
+			headers := make([]log.Header, 0, len(record.Headers)+1)
+			headers = append(headers, record.Headers...)
+			headers = append(headers, log.Header{Name: api.AtomicLogHeaderName, Value: name})
+
+			record.Headers = headers
+
+			_, err = bufferedWriter.Write(&record)
+			if err != nil {
+				return r, err
+			}
+		}
+	}
+
+	err = bufferedWriter.Flush()
+	if err != nil {
+		return r, err
+	}
+
+	endpoint := c.baseURL + "/logs/batch"
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, buffer)
+	if err != nil {
+		return r, err
+	}
+
+	req.Header.Add("Content-Type", api.RecordBinaryMediaType)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return r, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = api.ReadError(resp.Body)
+		return r, err
+	}
+
+	api.ReadResponse(resp.Body, &r)
+
+	return r, nil
+}
+
 func (c *Client) ReadRecordsBatch(logName string, params api.ReadRecordsBatchParams, bufferSize int, timeout int, fn RecordsReaderHandler) (err error) {
 
 	encoder := schema.NewEncoder()
@@ -380,26 +988,10 @@ func (c *Client) WriteRecordsTCP(logName string, flag recio.IOMode, writeBufferS
 	req.Header.Add("Upgrade", api.StyxProtocolString)
 	req.Header.Add(api.TimeoutHeaderName, strconv.Itoa(timeout))
 
-	var tcpConn *net.TCPConn
-
-	dial := func(network string, address string) (conn net.Conn, err error) {
-
-		conn, err = net.Dial(network, address)
-		if err != nil {
-			return nil, err
-		}
-
-		tcpConn = conn.(*net.TCPConn)
-
-		return conn, nil
-	}
-
-	t := &http.Transport{
-		Dial: dial,
-	}
+	var conn tcp.Conn
 
 	client := &http.Client{
-		Transport: t,
+		Transport: c.upgradeTransport(&conn),
 	}
 
 	resp, err := client.Do(req)
@@ -422,7 +1014,7 @@ func (c *Client) WriteRecordsTCP(logName string, flag recio.IOMode, writeBufferS
 		}
 	}
 
-	tw = tcp.NewTCPWriter(tcpConn, writeBufferSize, readBufferSize, timeout, remoteTimeout, flag)
+	tw = tcp.NewTCPWriter(newUpgradedConn(conn, resp), writeBufferSize, readBufferSize, timeout, remoteTimeout, flag)
 
 	return tw, nil
 }
@@ -448,26 +1040,10 @@ func (c *Client) ReadRecordsTCP(name string, params api.ReadRecordsTCPParams, fl
 	req.Header.Add("Upgrade", api.StyxProtocolString)
 	req.Header.Add(api.TimeoutHeaderName, strconv.Itoa(timeout))
 
-	var tcpConn *net.TCPConn
-
-	dial := func(network string, address string) (conn net.Conn, err error) {
-
-		conn, err = net.Dial(network, address)
-		if err != nil {
-			return nil, err
-		}
-
-		tcpConn = conn.(*net.TCPConn)
-
-		return conn, nil
-	}
-
-	t := &http.Transport{
-		Dial: dial,
-	}
+	var conn tcp.Conn
 
 	client := &http.Client{
-		Transport: t,
+		Transport: c.upgradeTransport(&conn),
 	}
 
 	resp, err := client.Do(req)
@@ -490,7 +1066,7 @@ func (c *Client) ReadRecordsTCP(name string, params api.ReadRecordsTCPParams, fl
 		}
 	}
 
-	tr = tcp.NewTCPReader(tcpConn, writeBufferSize, readBufferSize, timeout, remoteTimeout, flag)
+	tr = tcp.NewTCPReader(newUpgradedConn(conn, resp), writeBufferSize, readBufferSize, timeout, remoteTimeout, flag)
 
 	return tr, nil
 }
@@ -10,7 +10,6 @@
 package client
 
 import (
-	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -42,14 +41,17 @@ var (
 type Whence string
 
 const (
-	SeekOrigin  Whence = "origin"  // Seek from the log origin (position 0).
-	SeekStart   Whence = "start"   // Seek from the first available record.
-	SeekCurrent Whence = "current" // Seek from the current position.
-	SeekEnd     Whence = "end"     // Seek from the end of the log.
+	SeekOrigin    Whence = "origin"    // Seek from the log origin (position 0).
+	SeekStart     Whence = "start"     // Seek from the first available record.
+	SeekCurrent   Whence = "current"   // Seek from the current position.
+	SeekEnd       Whence = "end"       // Seek from the end of the log.
+	SeekTimestamp Whence = "timestamp" // Seek to the first record written at or after a Unix timestamp in seconds.
+	SeekCommitted Whence = "committed" // Seek from the position committed by the consumer group.
 )
 
 type Consumer struct {
-	reader *tcp.TCPReader
+	reader   *tcp.TCPReader
+	position int64
 }
 
 type ConsumerParams struct {
@@ -57,6 +59,8 @@ type ConsumerParams struct {
 	Position int64  `schema:"position"`
 	Count    int64  `schema:"count"`
 	Follow   bool   `schema:"follow"`
+	Group    string `schema:"group"`
+	api.RecordFilterParams
 }
 
 type ConsumerOptions struct {
@@ -86,27 +90,12 @@ func (c *Client) NewConsumer(name string, params ConsumerParams, options Consume
 	req.Header.Add("Connection", "upgrade")
 	req.Header.Add("Upgrade", api.StyxProtocolString)
 	req.Header.Add(api.TimeoutHeaderName, strconv.Itoa(options.ReadTimeout))
+	req.Header.Add(api.CapabilitiesHeaderName, api.PositionCapability)
 
-	var tcpConn *net.TCPConn
-
-	dial := func(network string, address string) (conn net.Conn, err error) {
-
-		conn, err = net.Dial(network, address)
-		if err != nil {
-			return nil, err
-		}
-
-		tcpConn = conn.(*net.TCPConn)
-
-		return conn, nil
-	}
-
-	t := &http.Transport{
-		Dial: dial,
-	}
+	var conn tcp.Conn
 
 	client := &http.Client{
-		Transport: t,
+		Transport: c.upgradeTransport(&conn),
 	}
 
 	resp, err := client.Do(req)
@@ -129,12 +118,29 @@ func (c *Client) NewConsumer(name string, params ConsumerParams, options Consume
 		}
 	}
 
-	reader := tcp.NewTCPReader(tcpConn, options.WriteBufferSize, options.ReadBufferSize, options.ReadTimeout, remoteTimeout, options.IOMode)
+	var position int64
+
+	rawPosition := resp.Header.Get(api.PositionHeaderName)
+	if rawPosition != "" {
+		position, err = strconv.ParseInt(rawPosition, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reader := tcp.NewTCPReader(newUpgradedConn(conn, resp), options.WriteBufferSize, options.ReadBufferSize, options.ReadTimeout, remoteTimeout, options.IOMode)
 
 	co = &Consumer{
-		reader: reader,
+		reader:   reader,
+		position: position,
 	}
 
+	// Records skipped by a filter are not sent, the server sends the next
+	// read position instead.
+	reader.HandlePosition(func(position int64) {
+		co.position = position
+	})
+
 	return co, nil
 }
 
@@ -145,9 +151,47 @@ func (co *Consumer) Read(r *log.Record) (n int, err error) {
 		return n, err
 	}
 
+	co.position += 1
+
 	return n, nil
 }
 
+// Fill waits for records to be received when Read fails with
+// recio.ErrMustFill, which only happens in recio.ModeManual.
+func (co *Consumer) Fill() (err error) {
+
+	err = co.reader.Fill()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Tell returns the position of the next record to be read.
+func (co *Consumer) Tell() (position int64) {
+
+	return co.position
+}
+
+// Commit stores position as the committed position of the consumer group the
+// consumer was created with. Committing Tell() acknowledges all records read
+// so far.
+func (co *Consumer) Commit(position int64) (err error) {
+
+	_, err = co.reader.WriteCommit(position)
+	if err != nil {
+		return err
+	}
+
+	err = co.reader.Flush()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (co *Consumer) Close() (err error) {
 
 	err = co.reader.Close()
@@ -10,7 +10,6 @@
 package client
 
 import (
-	"net"
 	"net/http"
 	"strconv"
 
@@ -41,6 +40,12 @@ type ProducerOptions struct {
 	ReadBufferSize  int
 	WriteBufferSize int
 	IOMode          recio.IOMode
+
+	// When set, records are written once per producer id and sequence,
+	// starting at Sequence. Producers resuming after a failure reuse
+	// their id and the sequence of the first unacknowledged record.
+	ProducerID string
+	Sequence   int64
 }
 
 func (c *Client) NewProducer(name string, options ProducerOptions) (p *Producer, err error) {
@@ -56,26 +61,10 @@ func (c *Client) NewProducer(name string, options ProducerOptions) (p *Producer,
 	req.Header.Add("Upgrade", api.StyxProtocolString)
 	req.Header.Add(api.TimeoutHeaderName, strconv.Itoa(options.ReadTimeout))
 
-	var tcpConn *net.TCPConn
-
-	dial := func(network string, address string) (conn net.Conn, err error) {
-
-		conn, err = net.Dial(network, address)
-		if err != nil {
-			return nil, err
-		}
-
-		tcpConn = conn.(*net.TCPConn)
-
-		return conn, nil
-	}
-
-	t := &http.Transport{
-		Dial: dial,
-	}
+	var conn tcp.Conn
 
 	client := &http.Client{
-		Transport: t,
+		Transport: c.upgradeTransport(&conn),
 	}
 
 	resp, err := client.Do(req)
@@ -98,7 +87,11 @@ func (c *Client) NewProducer(name string, options ProducerOptions) (p *Producer,
 		}
 	}
 
-	writer := tcp.NewTCPWriter(tcpConn, options.WriteBufferSize, options.ReadBufferSize, options.ReadTimeout, remoteTimeout, options.IOMode)
+	writer := tcp.NewTCPWriter(newUpgradedConn(conn, resp), options.WriteBufferSize, options.ReadBufferSize, options.ReadTimeout, remoteTimeout, options.IOMode)
+
+	if options.ProducerID != "" {
+		writer.SetProducer(options.ProducerID, options.Sequence)
+	}
 
 	p = &Producer{
 		writer: writer,
@@ -117,6 +110,13 @@ func (p *Producer) Write(r *log.Record) (n int, err error) {
 	return n, nil
 }
 
+// Sequence returns the sequence of the next record written by a producer
+// with an id.
+func (p *Producer) Sequence() (sequence int64) {
+
+	return p.writer.Sequence()
+}
+
 func (p *Producer) Flush() (err error) {
 
 	err = p.writer.Flush()
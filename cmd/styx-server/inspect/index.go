@@ -0,0 +1,75 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package inspect
+
+import (
+	"gitlab.com/dataptive/styx/cmd"
+	"gitlab.com/dataptive/styx/log"
+
+	"github.com/spf13/pflag"
+)
+
+const inspectIndexUsage = `
+Usage: styx-server inspect index NAME SEGMENT [OPTIONS]
+
+Dump the index entries of a log segment, up to the first invalid one
+
+Global Options:
+	    --config string		Server config file path (default "config.toml")
+	-d, --data-directory string	Data directory, overriding the one of the config file
+	-f, --format string		Output format [text|json] (default "text")
+	-h, --help 			Display help
+`
+
+const inspectIndexTmpl = `POSITION	OFFSET	TIMESTAMP
+{{range .}}{{.Position}}	{{.Offset}}	{{.Timestamp}}
+{{end}}`
+
+func InspectIndex(args []string) {
+
+	indexOpts := pflag.NewFlagSet("inspect index", pflag.ContinueOnError)
+	opts := newInspectOptions(indexOpts)
+	indexOpts.Usage = func() {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectIndexUsage)
+	}
+
+	err := indexOpts.Parse(args)
+	if err != nil {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectIndexUsage)
+	}
+
+	if *opts.isHelp {
+		cmd.DisplayUsage(cmd.SuccessCode, inspectIndexUsage)
+	}
+
+	if indexOpts.NArg() != 2 {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectIndexUsage)
+	}
+
+	path := opts.logPath(indexOpts.Args()[0])
+
+	// Display valid entries before reporting the invalid one.
+	entries, indexErr := log.ReadIndex(path, indexOpts.Args()[1])
+
+	descriptions := []indexEntry{}
+	for _, ie := range entries {
+		descriptions = append(descriptions, indexEntry(ie))
+	}
+
+	if *opts.format == "json" {
+		cmd.DisplayAsJSON(descriptions)
+	} else {
+		cmd.DisplayAsDefault(inspectIndexTmpl, descriptions)
+	}
+
+	if indexErr != nil {
+		cmd.DisplayError(indexErr)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package inspect
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gitlab.com/dataptive/styx/cmd"
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/server/config"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultConfigPath = "config.toml"
+)
+
+// inspectOptions are shared by all inspect commands.
+type inspectOptions struct {
+	configPath    *string
+	dataDirectory *string
+	format        *string
+	isHelp        *bool
+}
+
+func newInspectOptions(flags *pflag.FlagSet) (opts inspectOptions) {
+
+	opts = inspectOptions{
+		configPath:    flags.String("config", defaultConfigPath, ""),
+		dataDirectory: flags.StringP("data-directory", "d", "", ""),
+		format:        flags.StringP("format", "f", "text", ""),
+		isHelp:        flags.BoolP("help", "h", false, ""),
+	}
+
+	return opts
+}
+
+// path returns the data directory given with --data-directory, or the one set
+// in the server config file.
+func (opts inspectOptions) path() (path string) {
+
+	if *opts.dataDirectory != "" {
+		return *opts.dataDirectory
+	}
+
+	serverConfig, err := config.Load(*opts.configPath)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	return serverConfig.LogManager.DataDirectory
+}
+
+// logPath returns the path of a log, warning when it is opened by a running
+// server. Logs are only read, but may change while being inspected.
+func (opts inspectOptions) logPath(name string) (path string) {
+
+	path = filepath.Join(opts.path(), name)
+
+	opened, err := log.IsOpened(path)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	if opened {
+		fmt.Fprintf(os.Stderr, "Warning: log %s is opened by a running server, its files may change while being inspected\n\n", name)
+	}
+
+	return path
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package inspect
+
+import (
+	"path/filepath"
+
+	"gitlab.com/dataptive/styx/cmd"
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/logman"
+
+	"github.com/spf13/pflag"
+)
+
+const inspectLogsUsage = `
+Usage: styx-server inspect logs [OPTIONS]
+
+List logs stored in the data directory, along with their configuration
+
+Global Options:
+	    --config string		Server config file path (default "config.toml")
+	-d, --data-directory string	Data directory, overriding the one of the config file
+	-f, --format string		Output format [text|json] (default "text")
+	-h, --help 			Display help
+`
+
+const inspectLogsTmpl = `NAME	OPENED	SEGMENTS	MAX RECORD SIZE	INDEX AFTER SIZE	SEGMENT MAX COUNT	SEGMENT MAX SIZE	SEGMENT MAX AGE	LOG MAX COUNT	LOG MAX SIZE	LOG MAX AGE	COMPACTION	COMPRESSION	ERROR
+{{range .}}{{.Name}}	{{.Opened}}	{{.Segments}}	{{.Config.MaxRecordSize}}	{{.Config.IndexAfterSize}}	{{.Config.SegmentMaxCount}}	{{.Config.SegmentMaxSize}}	{{.Config.SegmentMaxAge}}	{{.Config.LogMaxCount}}	{{.Config.LogMaxSize}}	{{.Config.LogMaxAge}}	{{.Config.LogCompaction}}	{{.Config.Compression}}	{{.Error}}
+{{end}}`
+
+func InspectLogs(args []string) {
+
+	logsOpts := pflag.NewFlagSet("inspect logs", pflag.ContinueOnError)
+	opts := newInspectOptions(logsOpts)
+	logsOpts.Usage = func() {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectLogsUsage)
+	}
+
+	err := logsOpts.Parse(args)
+	if err != nil {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectLogsUsage)
+	}
+
+	if *opts.isHelp {
+		cmd.DisplayUsage(cmd.SuccessCode, inspectLogsUsage)
+	}
+
+	if logsOpts.NArg() != 0 {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectLogsUsage)
+	}
+
+	dataDirectory := opts.path()
+
+	names, err := logman.ListLogs(dataDirectory)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	descriptions := []logDescription{}
+
+	for _, name := range names {
+
+		path := filepath.Join(dataDirectory, name)
+
+		description := logDescription{
+			Name: name,
+		}
+
+		// Report errors for each log, so that damaged logs are
+		// listed along with the others.
+		description.Opened, err = log.IsOpened(path)
+		if err != nil {
+			description.Error = err.Error()
+		}
+
+		config, err := log.ReadConfig(path)
+		if err != nil {
+			description.Error = err.Error()
+		}
+
+		description.Config = logConfig(config)
+
+		segments, err := log.ListSegments(path)
+		if err != nil {
+			description.Error = err.Error()
+		}
+
+		description.Segments = len(segments)
+
+		descriptions = append(descriptions, description)
+	}
+
+	if *opts.format == "json" {
+		cmd.DisplayAsJSON(descriptions)
+		return
+	}
+
+	cmd.DisplayAsDefault(inspectLogsTmpl, descriptions)
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package inspect
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"gitlab.com/dataptive/styx/cmd"
+	"gitlab.com/dataptive/styx/log"
+
+	"github.com/spf13/pflag"
+)
+
+const inspectRecordsUsage = `
+Usage: styx-server inspect records NAME SEGMENT [OPTIONS]
+
+Dump the records of a log segment, up to the first invalid one
+
+Options:
+	-P, --position int 		Position to start dumping from (default to the segment base position)
+	-n, --count int			Maximum count of records to dump
+
+Global Options:
+	    --config string		Server config file path (default "config.toml")
+	-d, --data-directory string	Data directory, overriding the one of the config file
+	-f, --format string		Output format [text|hex|json] (default "text")
+	-h, --help 			Display help
+`
+
+var (
+	errDumpDone = errors.New("dump done")
+)
+
+func InspectRecords(args []string) {
+
+	recordsOpts := pflag.NewFlagSet("inspect records", pflag.ContinueOnError)
+	opts := newInspectOptions(recordsOpts)
+	position := recordsOpts.Int64P("position", "P", -1, "")
+	count := recordsOpts.Int64P("count", "n", -1, "")
+	recordsOpts.Usage = func() {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectRecordsUsage)
+	}
+
+	err := recordsOpts.Parse(args)
+	if err != nil {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectRecordsUsage)
+	}
+
+	if *opts.isHelp {
+		cmd.DisplayUsage(cmd.SuccessCode, inspectRecordsUsage)
+	}
+
+	if recordsOpts.NArg() != 2 {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectRecordsUsage)
+	}
+
+	var dump func(w *bufio.Writer, position int64, offset int64, timestamp int64, r *log.Record) (err error)
+
+	switch *opts.format {
+	case "text":
+		dump = dumpText
+	case "hex":
+		dump = dumpHex
+	case "json":
+		dump = dumpJSON
+	default:
+		cmd.DisplayUsage(cmd.MisuseCode, inspectRecordsUsage)
+	}
+
+	path := opts.logPath(recordsOpts.Args()[0])
+
+	w := bufio.NewWriter(os.Stdout)
+	dumped := int64(0)
+
+	err = log.InspectRecords(path, recordsOpts.Args()[1], func(p int64, offset int64, timestamp int64, r *log.Record) (err error) {
+
+		if p < *position {
+			return nil
+		}
+
+		if *count >= 0 && dumped >= *count {
+			return errDumpDone
+		}
+
+		dumped += 1
+
+		return dump(w, p, offset, timestamp, r)
+	})
+
+	flushErr := w.Flush()
+	if flushErr != nil {
+		cmd.DisplayError(flushErr)
+	}
+
+	if err != nil && err != errDumpDone {
+		cmd.DisplayError(err)
+	}
+}
+
+func dumpText(w *bufio.Writer, position int64, offset int64, timestamp int64, r *log.Record) (err error) {
+
+	_, err = fmt.Fprintf(w, "%d\t%d\t%s\n", position, offset, r.Payload)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// dumpHex dumps records as encoded in the records file, without their CRC.
+func dumpHex(w *bufio.Writer, position int64, offset int64, timestamp int64, r *log.Record) (err error) {
+
+	buffer := make([]byte, r.Size())
+
+	n, err := r.Encode(buffer)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "position %d, offset %d, timestamp %d, size %d\n%s\n", position, offset, timestamp, n, hex.Dump(buffer[:n]))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func dumpJSON(w *bufio.Writer, position int64, offset int64, timestamp int64, r *log.Record) (err error) {
+
+	description := recordDescription{
+		Position:        position,
+		Offset:          offset,
+		Timestamp:       timestamp,
+		Key:             string(r.Key),
+		RecordTimestamp: r.Timestamp,
+		Headers:         []recordHeader{},
+		Payload:         string(r.Payload),
+	}
+
+	for _, h := range r.Headers {
+		description.Headers = append(description.Headers, recordHeader(h))
+	}
+
+	buf, err := json.Marshal(description)
+	if err != nil {
+		return err
+	}
+
+	buf = append(buf, '\n')
+
+	_, err = w.Write(buf)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package inspect
+
+import (
+	"gitlab.com/dataptive/styx/cmd"
+	"gitlab.com/dataptive/styx/log"
+
+	"github.com/spf13/pflag"
+)
+
+const inspectSegmentsUsage = `
+Usage: styx-server inspect segments NAME [OPTIONS]
+
+List the segments of a log
+
+Global Options:
+	    --config string		Server config file path (default "config.toml")
+	-d, --data-directory string	Data directory, overriding the one of the config file
+	-f, --format string		Output format [text|json] (default "text")
+	-h, --help 			Display help
+`
+
+const inspectSegmentsTmpl = `SEGMENT	BASE POSITION	BASE OFFSET	BASE TIMESTAMP	GENERATION	COMPRESSED	RECORDS SIZE	INDEX SIZE
+{{range .}}{{.Name}}	{{.BasePosition}}	{{.BaseOffset}}	{{.BaseTimestamp}}	{{.Generation}}	{{.Compressed}}	{{.RecordsSize}}	{{.IndexSize}}
+{{end}}`
+
+func InspectSegments(args []string) {
+
+	segmentsOpts := pflag.NewFlagSet("inspect segments", pflag.ContinueOnError)
+	opts := newInspectOptions(segmentsOpts)
+	segmentsOpts.Usage = func() {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectSegmentsUsage)
+	}
+
+	err := segmentsOpts.Parse(args)
+	if err != nil {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectSegmentsUsage)
+	}
+
+	if *opts.isHelp {
+		cmd.DisplayUsage(cmd.SuccessCode, inspectSegmentsUsage)
+	}
+
+	if segmentsOpts.NArg() != 1 {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectSegmentsUsage)
+	}
+
+	path := opts.logPath(segmentsOpts.Args()[0])
+
+	segments, err := log.ListSegments(path)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	descriptions := []segmentDescription{}
+	for _, si := range segments {
+		descriptions = append(descriptions, segmentDescription(si))
+	}
+
+	if *opts.format == "json" {
+		cmd.DisplayAsJSON(descriptions)
+		return
+	}
+
+	cmd.DisplayAsDefault(inspectSegmentsTmpl, descriptions)
+}
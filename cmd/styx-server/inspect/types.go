@@ -0,0 +1,79 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package inspect
+
+import (
+	"gitlab.com/dataptive/styx/log"
+)
+
+type logDescription struct {
+	Name     string    `json:"name"`
+	Opened   bool      `json:"opened"`
+	Segments int       `json:"segments"`
+	Config   logConfig `json:"config"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type logConfig struct {
+	MaxRecordSize   int             `json:"max_record_size"`
+	IndexAfterSize  int64           `json:"index_after_size"`
+	SegmentMaxCount int64           `json:"segment_max_count"`
+	SegmentMaxSize  int64           `json:"segment_max_size"`
+	SegmentMaxAge   int64           `json:"segment_max_age"`
+	LogMaxCount     int64           `json:"log_max_count"`
+	LogMaxSize      int64           `json:"log_max_size"`
+	LogMaxAge       int64           `json:"log_max_age"`
+	LogCompaction   bool            `json:"log_compaction"`
+	TombstoneMaxAge int64           `json:"tombstone_max_age"`
+	Compression     log.Compression `json:"compression"`
+}
+
+type segmentDescription struct {
+	Name          string `json:"name"`
+	BasePosition  int64  `json:"base_position"`
+	BaseOffset    int64  `json:"base_offset"`
+	BaseTimestamp int64  `json:"base_timestamp"`
+	Generation    int64  `json:"generation"`
+	Compressed    bool   `json:"compressed"`
+	RecordsSize   int64  `json:"records_size"`
+	IndexSize     int64  `json:"index_size"`
+}
+
+type indexEntry struct {
+	Position  int64 `json:"position"`
+	Offset    int64 `json:"offset"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+type recordDescription struct {
+	Position        int64          `json:"position"`
+	Offset          int64          `json:"offset"`
+	Timestamp       int64          `json:"timestamp"`
+	Key             string         `json:"key,omitempty"`
+	RecordTimestamp int64          `json:"record_timestamp,omitempty"`
+	Headers         []recordHeader `json:"headers,omitempty"`
+	Payload         string         `json:"payload"`
+}
+
+type recordHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type segmentReport struct {
+	Segment      string `json:"segment"`
+	Records      int64  `json:"records"`
+	EndPosition  int64  `json:"end_position"`
+	EndOffset    int64  `json:"end_offset"`
+	IndexEntries int64  `json:"index_entries"`
+	RecordsError string `json:"records_error,omitempty"`
+	IndexError   string `json:"index_error,omitempty"`
+	Status       string `json:"status"`
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package inspect
+
+import (
+	"os"
+
+	"gitlab.com/dataptive/styx/cmd"
+	"gitlab.com/dataptive/styx/log"
+
+	"github.com/spf13/pflag"
+)
+
+const inspectVerifyUsage = `
+Usage: styx-server inspect verify NAME [OPTIONS]
+
+Check the CRCs of the records and index files of each segment of a log. Exits with status 1 when a segment is corrupt.
+
+Global Options:
+	    --config string		Server config file path (default "config.toml")
+	-d, --data-directory string	Data directory, overriding the one of the config file
+	-f, --format string		Output format [text|json] (default "text")
+	-h, --help 			Display help
+`
+
+const inspectVerifyTmpl = `SEGMENT	RECORDS	END POSITION	END OFFSET	INDEX ENTRIES	STATUS	ERROR
+{{range .}}{{.Segment}}	{{.Records}}	{{.EndPosition}}	{{.EndOffset}}	{{.IndexEntries}}	{{.Status}}	{{if .RecordsError}}records: {{.RecordsError}} {{end}}{{if .IndexError}}index: {{.IndexError}}{{end}}
+{{end}}`
+
+const (
+	statusOK      = "ok"
+	statusCorrupt = "corrupt"
+)
+
+func InspectVerify(args []string) {
+
+	verifyOpts := pflag.NewFlagSet("inspect verify", pflag.ContinueOnError)
+	opts := newInspectOptions(verifyOpts)
+	verifyOpts.Usage = func() {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectVerifyUsage)
+	}
+
+	err := verifyOpts.Parse(args)
+	if err != nil {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectVerifyUsage)
+	}
+
+	if *opts.isHelp {
+		cmd.DisplayUsage(cmd.SuccessCode, inspectVerifyUsage)
+	}
+
+	if verifyOpts.NArg() != 1 {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectVerifyUsage)
+	}
+
+	path := opts.logPath(verifyOpts.Args()[0])
+
+	reports, err := log.VerifySegments(path)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	descriptions := []segmentReport{}
+	corrupt := false
+
+	for _, sr := range reports {
+
+		description := segmentReport{
+			Segment:      sr.Segment,
+			Records:      sr.Records,
+			EndPosition:  sr.EndPosition,
+			EndOffset:    sr.EndOffset,
+			IndexEntries: sr.IndexEntries,
+			Status:       statusOK,
+		}
+
+		if sr.RecordsError != nil {
+			description.RecordsError = sr.RecordsError.Error()
+		}
+
+		if sr.IndexError != nil {
+			description.IndexError = sr.IndexError.Error()
+		}
+
+		if !sr.OK() {
+			description.Status = statusCorrupt
+			corrupt = true
+		}
+
+		descriptions = append(descriptions, description)
+	}
+
+	if *opts.format == "json" {
+		cmd.DisplayAsJSON(descriptions)
+	} else {
+		cmd.DisplayAsDefault(inspectVerifyTmpl, descriptions)
+	}
+
+	if corrupt {
+		os.Exit(int(cmd.ErrorCode))
+	}
+}
@@ -10,11 +10,14 @@
 package main
 
 import (
-	// "fmt"
+	"fmt"
 	"os"
+	"path/filepath"
 	// "strings"
 
 	"gitlab.com/dataptive/styx/cmd"
+	"gitlab.com/dataptive/styx/cmd/styx-server/inspect"
+	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/server"
 	"gitlab.com/dataptive/styx/server/config"
@@ -28,17 +31,51 @@ const (
 
 const usage = `
 Usage: styx-server [OPTIONS]
+       styx-server COMMAND
 
 Run Styx server
 
+Commands:
+	inspect			Inspect log files without a running server
+	rebuild-index		Rebuild segment indexes of stopped logs
+
 Options:
 	--config string 	Config file path
 	--log-level string 	Set the logging level [TRACE|DEBUG|INFO|WARN|ERROR|FATAL] (default "INFO")
 	--help			Display help
 `
 
+const inspectUsage = `
+Usage: styx-server inspect COMMAND
+
+Inspect log files of a data directory without a running server. Files are only read, logs opened by a running server are inspected with a warning.
+
+Commands:
+	logs			List logs and their configuration
+	segments		List the segments of a log
+	records			Dump the records of a segment
+	index			Dump the index entries of a segment
+	verify			Check the CRCs of each segment of a log
+
+Global Options:
+	    --config string		Server config file path (default "config.toml")
+	-d, --data-directory string	Data directory, overriding the one of the config file
+	-f, --format string		Output format [text|json] (default "text")
+	-h, --help 			Display help
+`
+
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "rebuild-index" {
+		rebuildIndex(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "inspect" {
+		runInspect(os.Args[2:])
+		return
+	}
+
 	options := pflag.NewFlagSet("", pflag.ContinueOnError)
 	configPath := options.String("config", defaultConfigPath, "")
 	level := options.String("log-level", "INFO", "")
@@ -88,3 +125,82 @@ func main() {
 		logger.Fatal(err)
 	}
 }
+
+func runInspect(args []string) {
+
+	if len(args) < 1 {
+		cmd.DisplayUsage(cmd.MisuseCode, inspectUsage)
+	}
+
+	switch args[0] {
+	case "logs":
+		inspect.InspectLogs(args[1:])
+	case "segments":
+		inspect.InspectSegments(args[1:])
+	case "records":
+		inspect.InspectRecords(args[1:])
+	case "index":
+		inspect.InspectIndex(args[1:])
+	case "verify":
+		inspect.InspectVerify(args[1:])
+	case "--help":
+		cmd.DisplayUsage(cmd.SuccessCode, inspectUsage)
+	case "-h":
+		cmd.DisplayUsage(cmd.SuccessCode, inspectUsage)
+	default:
+		cmd.DisplayUsage(cmd.MisuseCode, inspectUsage)
+	}
+}
+
+const rebuildIndexUsage = `
+Usage: styx-server rebuild-index NAME... [OPTIONS]
+
+Rebuild missing or damaged segment indexes of logs from their records, while the server is stopped
+
+Options:
+	--config string 	Config file path
+	--all			Rebuild all segment indexes
+	--help			Display help
+`
+
+func rebuildIndex(args []string) {
+
+	options := pflag.NewFlagSet("rebuild-index", pflag.ContinueOnError)
+	configPath := options.String("config", defaultConfigPath, "")
+	all := options.Bool("all", false, "")
+	help := options.Bool("help", false, "")
+
+	err := options.Parse(args)
+	if err != nil {
+		cmd.DisplayUsage(cmd.MisuseCode, rebuildIndexUsage)
+	}
+
+	if *help {
+		cmd.DisplayUsage(cmd.SuccessCode, rebuildIndexUsage)
+	}
+
+	if options.NArg() == 0 {
+		cmd.DisplayUsage(cmd.MisuseCode, rebuildIndexUsage)
+	}
+
+	serverConfig, err := config.Load(*configPath)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	for _, name := range options.Args() {
+
+		path := filepath.Join(serverConfig.LogManager.DataDirectory, name)
+
+		count, err := log.RebuildIndexes(path, *all)
+		if err == log.ErrLocked {
+			cmd.DisplayError(fmt.Errorf("log %s is in use, stop the server first", name))
+		}
+
+		if err != nil {
+			cmd.DisplayError(fmt.Errorf("log %s: %s", name, err))
+		}
+
+		fmt.Printf("%s: rebuilt %d indexes\n", name, count)
+	}
+}
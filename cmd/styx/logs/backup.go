@@ -25,6 +25,10 @@ Backup log
 
 Global Options:
 	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
 	-h, --help 		Display help
 `
 
@@ -32,6 +36,10 @@ func BackupLog(args []string) {
 
 	backupOpts := pflag.NewFlagSet("logs backup", pflag.ContinueOnError)
 	host := backupOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := backupOpts.String("token", "", "")
+	caFile := backupOpts.String("ca-file", "", "")
+	certFile := backupOpts.String("cert-file", "", "")
+	keyFile := backupOpts.String("key-file", "", "")
 	isHelp := backupOpts.BoolP("help", "h", false, "")
 	backupOpts.Usage = func() {
 		cmd.DisplayUsage(cmd.MisuseCode, logsBackupUsage)
@@ -47,6 +55,14 @@ func BackupLog(args []string) {
 	}
 
 	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
 
 	if backupOpts.NArg() != 1 {
 		cmd.DisplayUsage(cmd.MisuseCode, logsBackupUsage)
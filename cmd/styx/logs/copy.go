@@ -0,0 +1,90 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logs
+
+import (
+	"gitlab.com/dataptive/styx/client"
+	"gitlab.com/dataptive/styx/cmd"
+
+	"github.com/spf13/pflag"
+)
+
+const logsCopyUsage = `
+Usage: styx logs copy NAME NEW_NAME [OPTIONS]
+
+Copy a log, closed segments are shared with the copied log when possible
+
+Global Options:
+	-f, --format string	Output format [text|json] (default "text")
+	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
+	-h, --help 		Display help
+`
+
+const logsCopyTmpl = `name:	{{.Name}}
+status:	{{.Status}}
+record_count:	{{.RecordCount}}
+file_size:	{{.FileSize}}
+start_position:	{{.StartPosition}}
+end_position:	{{.EndPosition}}
+`
+
+func CopyLog(args []string) {
+
+	copyOpts := pflag.NewFlagSet("logs copy", pflag.ContinueOnError)
+	host := copyOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := copyOpts.String("token", "", "")
+	caFile := copyOpts.String("ca-file", "", "")
+	certFile := copyOpts.String("cert-file", "", "")
+	keyFile := copyOpts.String("key-file", "", "")
+	format := copyOpts.StringP("format", "f", "text", "")
+	isHelp := copyOpts.BoolP("help", "h", false, "")
+	copyOpts.Usage = func() {
+		cmd.DisplayUsage(cmd.MisuseCode, logsCopyUsage)
+	}
+
+	err := copyOpts.Parse(args)
+	if err != nil {
+		cmd.DisplayUsage(cmd.MisuseCode, logsCopyUsage)
+	}
+
+	if *isHelp {
+		cmd.DisplayUsage(cmd.SuccessCode, logsCopyUsage)
+	}
+
+	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
+
+	if copyOpts.NArg() != 2 {
+		cmd.DisplayUsage(cmd.MisuseCode, logsCopyUsage)
+	}
+
+	log, err := httpClient.CopyLog(copyOpts.Args()[0], copyOpts.Args()[1])
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	if *format == "json" {
+		cmd.DisplayAsJSON(log)
+		return
+	}
+
+	cmd.DisplayAsDefault(logsCopyTmpl, log)
+}
@@ -32,10 +32,17 @@ Options:
 	--log-max-count records 	Expire oldest segment when log exceeds this number of records
 	--log-max-size bytes 		Expire oldest segment when log exceeds this size
 	--log-max-age seconds 		Expire oldest segment when log exceeds this age
+	--log-compaction 		Only keep the latest record of each key
+	--tombstone-max-age seconds 	Remove tombstones from compacted log after this age
+	--compression codec 		Compress closed segments [none|gzip|zstd|snappy]
 
 Global Options:
 	-f, --format string		Output format [text|json] (default "text")
 	-H, --host string 		Server to connect to (default "http://localhost:8000")
+	    --token string 		Token used to authenticate requests
+	    --ca-file string 		CA certificates file to verify the server with
+	    --cert-file string		Client certificate file
+	    --key-file string 		Client certificate key file
 	-h, --help 			Display help
 `
 
@@ -58,8 +65,15 @@ func CreateLog(args []string) {
 	logMaxCount := createOpts.Int64("log-max-count", log.DefaultConfig.LogMaxCount, "")
 	logMaxSize := createOpts.Int64("log-max-size", log.DefaultConfig.LogMaxSize, "")
 	logMaxAge := createOpts.Int64("log-max-age", log.DefaultConfig.LogMaxAge, "")
+	logCompaction := createOpts.Bool("log-compaction", log.DefaultConfig.LogCompaction, "")
+	tombstoneMaxAge := createOpts.Int64("tombstone-max-age", log.DefaultConfig.TombstoneMaxAge, "")
+	compression := createOpts.String("compression", string(log.DefaultConfig.Compression), "")
 	format := createOpts.StringP("format", "f", "text", "")
 	host := createOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := createOpts.String("token", "", "")
+	caFile := createOpts.String("ca-file", "", "")
+	certFile := createOpts.String("cert-file", "", "")
+	keyFile := createOpts.String("key-file", "", "")
 	isHelp := createOpts.BoolP("help", "h", false, "")
 	createOpts.Usage = func() {
 		cmd.DisplayUsage(cmd.MisuseCode, logsCreateUsage)
@@ -79,6 +93,14 @@ func CreateLog(args []string) {
 	}
 
 	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
 
 	name := createOpts.Args()[0]
 	config := api.LogConfig{
@@ -90,6 +112,9 @@ func CreateLog(args []string) {
 		LogMaxCount:     *logMaxCount,
 		LogMaxSize:      *logMaxSize,
 		LogMaxAge:       *logMaxAge,
+		LogCompaction:   *logCompaction,
+		TombstoneMaxAge: *tombstoneMaxAge,
+		Compression:     log.Compression(*compression),
 	}
 
 	log, err := httpClient.CreateLog(name, config)
@@ -23,6 +23,10 @@ Delete a log
 
 Global Options:
 	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
 	-h, --help 		Display help
 `
 
@@ -30,6 +34,10 @@ func DeleteLog(args []string) {
 
 	deleteOpts := pflag.NewFlagSet("logs delete", pflag.ContinueOnError)
 	host := deleteOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := deleteOpts.String("token", "", "")
+	caFile := deleteOpts.String("ca-file", "", "")
+	certFile := deleteOpts.String("cert-file", "", "")
+	keyFile := deleteOpts.String("key-file", "", "")
 	isHelp := deleteOpts.BoolP("help", "h", false, "")
 	deleteOpts.Usage = func() {
 		cmd.DisplayUsage(cmd.MisuseCode, logsDeleteUsage)
@@ -45,6 +53,14 @@ func DeleteLog(args []string) {
 	}
 
 	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
 
 	if deleteOpts.NArg() != 1 {
 		cmd.DisplayUsage(cmd.MisuseCode, logsDeleteUsage)
@@ -24,6 +24,10 @@ Show log details
 Global Options:
 	-f, --format string	Output format [text|json] (default "text")
 	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
 	-h, --help 		Display help
 `
 
@@ -39,6 +43,10 @@ func GetLog(args []string) {
 
 	getOpts := pflag.NewFlagSet("logs get", pflag.ContinueOnError)
 	host := getOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := getOpts.String("token", "", "")
+	caFile := getOpts.String("ca-file", "", "")
+	certFile := getOpts.String("cert-file", "", "")
+	keyFile := getOpts.String("key-file", "", "")
 	format := getOpts.StringP("format", "f", "text", "")
 	isHelp := getOpts.BoolP("help", "h", false, "")
 	getOpts.Usage = func() {
@@ -55,6 +63,14 @@ func GetLog(args []string) {
 	}
 
 	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
 
 	if getOpts.NArg() != 1 {
 		cmd.DisplayUsage(cmd.MisuseCode, logsGetUsage)
@@ -28,6 +28,10 @@ Global Options:
 	-w, --watch		Display and update informations about logs
 	-f, --format string	Output format [text|json] (default "text")
 	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
 	-h, --help 		Display help
 `
 
@@ -41,6 +45,10 @@ func ListLogs(args []string) {
 	watch := listOpts.BoolP("watch", "w", false, "")
 	format := listOpts.StringP("format", "f", "default", "")
 	host := listOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := listOpts.String("token", "", "")
+	caFile := listOpts.String("ca-file", "", "")
+	certFile := listOpts.String("cert-file", "", "")
+	keyFile := listOpts.String("key-file", "", "")
 	isHelp := listOpts.BoolP("help", "h", false, "")
 	listOpts.Usage = func() {
 		cmd.DisplayUsage(cmd.MisuseCode, logsListUsage)
@@ -56,6 +64,14 @@ func ListLogs(args []string) {
 	}
 
 	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
 
 	if listOpts.NArg() != 0 {
 		cmd.DisplayUsage(cmd.MisuseCode, logsListUsage)
@@ -30,16 +30,27 @@ Usage: styx logs read NAME [OPTIONS]
 Read from log and output line delimited record payloads
 
 Options:
-	-P, --position int 	Position to start reading from (default 0)
-	-w, --whence string	Reference from which position is computed [origin|start|end] (default "start")
+	-P, --position int 	Position to start reading from, or Unix timestamp in seconds with --whence timestamp (default 0)
+	-w, --whence string	Reference from which position is computed [origin|start|end|timestamp|committed] (default "start")
+	-g, --group string	Consumer group to read from with --whence committed
 	-n, --count int		Maximum count of records to read (cannot be used in association with --follow)
 	-F, --follow 		Wait for new records when reaching end of stream
 	-u, --unbuffered	Do not buffer read
 	-b, --binary		Output binary records
 	-l, --line-ending   	Specify line-ending [cr|lf|crlf] for non binary record output
+	    --filter-contains string	Only read records whose payload contains string
+	    --filter-pattern string	Only read records whose payload matches regular expression
+	    --filter-field string	JSON payload field path compared with --filter-value
+	    --filter-value string	Only read records whose --filter-field equals value
+	    --filter-header string	Only read records having header
+	    --filter-header-value string	Only read records whose --filter-header has value
 
 Global Options:
 	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
 	-h, --help 		Display help
 `
 
@@ -54,12 +65,23 @@ func ReadLog(args []string) {
 	readOpts := pflag.NewFlagSet("read", pflag.ContinueOnError)
 	whence := readOpts.StringP("whence", "w", string(log.SeekOrigin), "")
 	position := readOpts.Int64P("position", "P", 0, "")
+	group := readOpts.StringP("group", "g", "", "")
 	count := readOpts.Int64P("count", "n", -1, "")
 	follow := readOpts.BoolP("follow", "F", false, "")
 	unbuffered := readOpts.BoolP("unbuffered", "u", false, "")
 	binary := readOpts.BoolP("binary", "b", false, "")
 	lineEnding := readOpts.StringP("line-ending", "l", "lf", "")
+	filterContains := readOpts.String("filter-contains", "", "")
+	filterPattern := readOpts.String("filter-pattern", "", "")
+	filterField := readOpts.String("filter-field", "", "")
+	filterValue := readOpts.String("filter-value", "", "")
+	filterHeader := readOpts.String("filter-header", "", "")
+	filterHeaderValue := readOpts.String("filter-header-value", "", "")
 	host := readOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := readOpts.String("token", "", "")
+	caFile := readOpts.String("ca-file", "", "")
+	certFile := readOpts.String("cert-file", "", "")
+	keyFile := readOpts.String("key-file", "", "")
 	isHelp := readOpts.BoolP("help", "h", false, "")
 	readOpts.Usage = func() {
 		cmd.DisplayUsage(cmd.MisuseCode, logsReadUsage)
@@ -79,12 +101,29 @@ func ReadLog(args []string) {
 	}
 
 	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
 
 	params := api.ReadRecordsTCPParams{
 		Whence:   log.Whence(*whence),
+		Group:    *group,
 		Position: *position,
 		Count: *count,
 		Follow: *follow,
+		RecordFilterParams: api.RecordFilterParams{
+			Contains:    *filterContains,
+			Pattern:     *filterPattern,
+			Field:       *filterField,
+			Value:       *filterValue,
+			Header:      *filterHeader,
+			HeaderValue: *filterHeaderValue,
+		},
 	}
 
 	logInfo, err := httpClient.GetLog(readOpts.Args()[0])
@@ -144,7 +183,8 @@ func ReadLog(args []string) {
 		if *binary {
 			encoder = record
 		} else {
-			encoder = (*recioutil.Line)(record)
+			line := recioutil.Line(record.Payload)
+			encoder = &line
 		}
 
 		_, err = writer.Write(encoder)
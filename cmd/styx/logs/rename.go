@@ -0,0 +1,90 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logs
+
+import (
+	"gitlab.com/dataptive/styx/client"
+	"gitlab.com/dataptive/styx/cmd"
+
+	"github.com/spf13/pflag"
+)
+
+const logsRenameUsage = `
+Usage: styx logs rename NAME NEW_NAME [OPTIONS]
+
+Rename a log
+
+Global Options:
+	-f, --format string	Output format [text|json] (default "text")
+	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
+	-h, --help 		Display help
+`
+
+const logsRenameTmpl = `name:	{{.Name}}
+status:	{{.Status}}
+record_count:	{{.RecordCount}}
+file_size:	{{.FileSize}}
+start_position:	{{.StartPosition}}
+end_position:	{{.EndPosition}}
+`
+
+func RenameLog(args []string) {
+
+	renameOpts := pflag.NewFlagSet("logs rename", pflag.ContinueOnError)
+	host := renameOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := renameOpts.String("token", "", "")
+	caFile := renameOpts.String("ca-file", "", "")
+	certFile := renameOpts.String("cert-file", "", "")
+	keyFile := renameOpts.String("key-file", "", "")
+	format := renameOpts.StringP("format", "f", "text", "")
+	isHelp := renameOpts.BoolP("help", "h", false, "")
+	renameOpts.Usage = func() {
+		cmd.DisplayUsage(cmd.MisuseCode, logsRenameUsage)
+	}
+
+	err := renameOpts.Parse(args)
+	if err != nil {
+		cmd.DisplayUsage(cmd.MisuseCode, logsRenameUsage)
+	}
+
+	if *isHelp {
+		cmd.DisplayUsage(cmd.SuccessCode, logsRenameUsage)
+	}
+
+	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
+
+	if renameOpts.NArg() != 2 {
+		cmd.DisplayUsage(cmd.MisuseCode, logsRenameUsage)
+	}
+
+	log, err := httpClient.RenameLog(renameOpts.Args()[0], renameOpts.Args()[1])
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	if *format == "json" {
+		cmd.DisplayAsJSON(log)
+		return
+	}
+
+	cmd.DisplayAsDefault(logsRenameTmpl, log)
+}
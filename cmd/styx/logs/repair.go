@@ -0,0 +1,94 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logs
+
+import (
+	"gitlab.com/dataptive/styx/client"
+	"gitlab.com/dataptive/styx/cmd"
+
+	"github.com/spf13/pflag"
+)
+
+const logsRepairUsage = `
+Usage: styx logs repair NAME [OPTIONS]
+
+Truncate the torn tail of a log left corrupt by a crash, and open it again
+
+Options:
+	--dry-run 			Only report what would be truncated
+
+Global Options:
+	-f, --format string		Output format [text|json] (default "text")
+	-H, --host string 		Server to connect to (default "http://localhost:8000")
+	    --token string 		Token used to authenticate requests
+	    --ca-file string 		CA certificates file to verify the server with
+	    --cert-file string		Client certificate file
+	    --key-file string 		Client certificate key file
+	-h, --help 			Display help
+`
+
+const logsRepairTmpl = `torn:	{{.Torn}}
+repaired:	{{.Repaired}}
+position:	{{.Position}}
+offset:	{{.Offset}}
+truncated_bytes:	{{.TruncatedBytes}}
+truncated_index_bytes:	{{.TruncatedIndexBytes}}
+`
+
+func RepairLog(args []string) {
+
+	repairOpts := pflag.NewFlagSet("logs repair", pflag.ContinueOnError)
+	host := repairOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := repairOpts.String("token", "", "")
+	caFile := repairOpts.String("ca-file", "", "")
+	certFile := repairOpts.String("cert-file", "", "")
+	keyFile := repairOpts.String("key-file", "", "")
+	format := repairOpts.StringP("format", "f", "text", "")
+	dryRun := repairOpts.Bool("dry-run", false, "")
+	isHelp := repairOpts.BoolP("help", "h", false, "")
+	repairOpts.Usage = func() {
+		cmd.DisplayUsage(cmd.MisuseCode, logsRepairUsage)
+	}
+
+	err := repairOpts.Parse(args)
+	if err != nil {
+		cmd.DisplayUsage(cmd.MisuseCode, logsRepairUsage)
+	}
+
+	if *isHelp {
+		cmd.DisplayUsage(cmd.SuccessCode, logsRepairUsage)
+	}
+
+	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
+
+	if repairOpts.NArg() != 1 {
+		cmd.DisplayUsage(cmd.MisuseCode, logsRepairUsage)
+	}
+
+	repair, err := httpClient.RepairLog(repairOpts.Args()[0], *dryRun)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	if *format == "json" {
+		cmd.DisplayAsJSON(repair)
+		return
+	}
+
+	cmd.DisplayAsDefault(logsRepairTmpl, repair)
+}
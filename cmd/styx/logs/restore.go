@@ -25,12 +25,20 @@ Restore log
 
 Global Options:
 	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
 	-h, --help 		Display help
 `
 
 func RestoreLog(args []string) {
 	restoreOpts := pflag.NewFlagSet("logs backup", pflag.ContinueOnError)
 	host := restoreOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := restoreOpts.String("token", "", "")
+	caFile := restoreOpts.String("ca-file", "", "")
+	certFile := restoreOpts.String("cert-file", "", "")
+	keyFile := restoreOpts.String("key-file", "", "")
 	isHelp := restoreOpts.BoolP("help", "h", false, "")
 	restoreOpts.Usage = func() {
 		cmd.DisplayUsage(cmd.MisuseCode, logsRestoreUsage)
@@ -46,6 +54,14 @@ func RestoreLog(args []string) {
 	}
 
 	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
 
 	if restoreOpts.NArg() != 1 {
 		cmd.DisplayUsage(cmd.MisuseCode, logsRestoreUsage)
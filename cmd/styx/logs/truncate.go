@@ -10,6 +10,7 @@
 package logs
 
 import (
+	"gitlab.com/dataptive/styx/api"
 	"gitlab.com/dataptive/styx/client"
 	"gitlab.com/dataptive/styx/cmd"
 
@@ -19,17 +20,41 @@ import (
 const logsTruncateUsage = `
 Usage: styx logs truncate NAME [OPTIONS]
 
-Truncate a log
+Truncate a log, or only delete the segments holding records before a boundary
+
+Options:
+	    --before int 		Delete segments holding only records before position
+	    --before-timestamp int	Delete segments holding only records written before Unix timestamp in seconds
 
 Global Options:
+	-f, --format string	Output format [text|json] (default "text")
 	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
 	-h, --help 		Display help
 `
 
+const logsTruncateTmpl = `name:	{{.Name}}
+status:	{{.Status}}
+record_count:	{{.RecordCount}}
+file_size:	{{.FileSize}}
+start_position:	{{.StartPosition}}
+end_position:	{{.EndPosition}}
+`
+
 func TruncateLog(args []string) {
 
 	truncateOpts := pflag.NewFlagSet("logs truncate", pflag.ContinueOnError)
 	host := truncateOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := truncateOpts.String("token", "", "")
+	caFile := truncateOpts.String("ca-file", "", "")
+	certFile := truncateOpts.String("cert-file", "", "")
+	keyFile := truncateOpts.String("key-file", "", "")
+	format := truncateOpts.StringP("format", "f", "text", "")
+	before := truncateOpts.Int64("before", 0, "")
+	beforeTimestamp := truncateOpts.Int64("before-timestamp", 0, "")
 	isHelp := truncateOpts.BoolP("help", "h", false, "")
 	truncateOpts.Usage = func() {
 		cmd.DisplayUsage(cmd.MisuseCode, logsTruncateUsage)
@@ -45,13 +70,42 @@ func TruncateLog(args []string) {
 	}
 
 	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
 
 	if truncateOpts.NArg() != 1 {
 		cmd.DisplayUsage(cmd.MisuseCode, logsTruncateUsage)
 	}
 
-	err = httpClient.TruncateLog(truncateOpts.Args()[0])
+	if truncateOpts.Changed("before") && truncateOpts.Changed("before-timestamp") {
+		cmd.DisplayUsage(cmd.MisuseCode, logsTruncateUsage)
+	}
+
+	params := api.TruncateLogParams{}
+
+	if truncateOpts.Changed("before") {
+		params.BeforePosition = before
+	}
+
+	if truncateOpts.Changed("before-timestamp") {
+		params.BeforeTimestamp = beforeTimestamp
+	}
+
+	log, err := httpClient.TruncateLog(truncateOpts.Args()[0], params)
 	if err != nil {
 		cmd.DisplayError(err)
 	}
+
+	if *format == "json" {
+		cmd.DisplayAsJSON(log)
+		return
+	}
+
+	cmd.DisplayAsDefault(logsTruncateTmpl, log)
 }
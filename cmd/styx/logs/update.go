@@ -0,0 +1,154 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logs
+
+import (
+	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/client"
+	"gitlab.com/dataptive/styx/cmd"
+	"gitlab.com/dataptive/styx/log"
+
+	"github.com/spf13/pflag"
+)
+
+const logsUpdateUsage = `
+Usage: styx logs update NAME [OPTIONS]
+
+Update the configuration of a log, only the given options are changed
+
+Options:
+	--segment-max-count records	Create a new segment when current segment exceeds this number of records
+	--segment-max-size bytes	Create a new segment when current segment exceeds this size
+	--segment-max-age seconds	Create a new segment when current segment exceeds this age
+	--log-max-count records 	Expire oldest segment when log exceeds this number of records
+	--log-max-size bytes 		Expire oldest segment when log exceeds this size
+	--log-max-age seconds 		Expire oldest segment when log exceeds this age
+	--log-compaction 		Only keep the latest record of each key
+	--tombstone-max-age seconds 	Remove tombstones from compacted log after this age
+	--compression codec 		Compress closed segments [none|gzip|zstd|snappy]
+
+Global Options:
+	-f, --format string		Output format [text|json] (default "text")
+	-H, --host string 		Server to connect to (default "http://localhost:8000")
+	    --token string 		Token used to authenticate requests
+	    --ca-file string 		CA certificates file to verify the server with
+	    --cert-file string		Client certificate file
+	    --key-file string 		Client certificate key file
+	-h, --help 			Display help
+`
+
+const logsUpdateTmpl = `name:	{{.Name}}
+status:	{{.Status}}
+record_count:	{{.RecordCount}}
+file_size:	{{.FileSize}}
+start_position:	{{.StartPosition}}
+end_position:	{{.EndPosition}}
+`
+
+func UpdateLog(args []string) {
+
+	updateOpts := pflag.NewFlagSet("logs update", pflag.ContinueOnError)
+	segmentMaxCount := updateOpts.Int64("segment-max-count", log.DefaultConfig.SegmentMaxCount, "")
+	segmentMaxSize := updateOpts.Int64("segment-max-size", log.DefaultConfig.SegmentMaxSize, "")
+	segmentMaxAge := updateOpts.Int64("segment-max-age", log.DefaultConfig.SegmentMaxAge, "")
+	logMaxCount := updateOpts.Int64("log-max-count", log.DefaultConfig.LogMaxCount, "")
+	logMaxSize := updateOpts.Int64("log-max-size", log.DefaultConfig.LogMaxSize, "")
+	logMaxAge := updateOpts.Int64("log-max-age", log.DefaultConfig.LogMaxAge, "")
+	logCompaction := updateOpts.Bool("log-compaction", log.DefaultConfig.LogCompaction, "")
+	tombstoneMaxAge := updateOpts.Int64("tombstone-max-age", log.DefaultConfig.TombstoneMaxAge, "")
+	compression := updateOpts.String("compression", string(log.DefaultConfig.Compression), "")
+	format := updateOpts.StringP("format", "f", "text", "")
+	host := updateOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := updateOpts.String("token", "", "")
+	caFile := updateOpts.String("ca-file", "", "")
+	certFile := updateOpts.String("cert-file", "", "")
+	keyFile := updateOpts.String("key-file", "", "")
+	isHelp := updateOpts.BoolP("help", "h", false, "")
+	updateOpts.Usage = func() {
+		cmd.DisplayUsage(cmd.MisuseCode, logsUpdateUsage)
+	}
+
+	err := updateOpts.Parse(args)
+	if err != nil {
+		cmd.DisplayUsage(cmd.MisuseCode, logsUpdateUsage)
+	}
+
+	if *isHelp {
+		cmd.DisplayUsage(cmd.SuccessCode, logsUpdateUsage)
+	}
+
+	if updateOpts.NArg() != 1 {
+		cmd.DisplayUsage(cmd.MisuseCode, logsUpdateUsage)
+	}
+
+	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
+
+	name := updateOpts.Args()[0]
+
+	// Only send the settings given on the command line.
+	form := api.UpdateLogForm{}
+
+	if updateOpts.Changed("segment-max-count") {
+		form.SegmentMaxCount = segmentMaxCount
+	}
+
+	if updateOpts.Changed("segment-max-size") {
+		form.SegmentMaxSize = segmentMaxSize
+	}
+
+	if updateOpts.Changed("segment-max-age") {
+		form.SegmentMaxAge = segmentMaxAge
+	}
+
+	if updateOpts.Changed("log-max-count") {
+		form.LogMaxCount = logMaxCount
+	}
+
+	if updateOpts.Changed("log-max-size") {
+		form.LogMaxSize = logMaxSize
+	}
+
+	if updateOpts.Changed("log-max-age") {
+		form.LogMaxAge = logMaxAge
+	}
+
+	if updateOpts.Changed("log-compaction") {
+		form.LogCompaction = logCompaction
+	}
+
+	if updateOpts.Changed("tombstone-max-age") {
+		form.TombstoneMaxAge = tombstoneMaxAge
+	}
+
+	if updateOpts.Changed("compression") {
+		codec := log.Compression(*compression)
+		form.Compression = &codec
+	}
+
+	log, err := httpClient.UpdateLog(name, form)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	if *format == "json" {
+		cmd.DisplayAsJSON(log)
+		return
+	}
+
+	cmd.DisplayAsDefault(logsUpdateTmpl, log)
+}
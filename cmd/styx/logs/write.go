@@ -35,6 +35,10 @@ Options:
 
 Global Options:
 	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
 	-h, --help 		Display help
 `
 
@@ -51,6 +55,10 @@ func WriteLog(args []string) {
 	binary := writeOpts.BoolP("binary", "b", false, "")
 	lineEnding := writeOpts.StringP("line-ending", "l", "lf", "")
 	host := writeOpts.StringP("host", "H", "http://localhost:8000", "")
+	token := writeOpts.String("token", "", "")
+	caFile := writeOpts.String("ca-file", "", "")
+	certFile := writeOpts.String("cert-file", "", "")
+	keyFile := writeOpts.String("key-file", "", "")
 	isHelp := writeOpts.BoolP("help", "h", false, "")
 	writeOpts.Usage = func() {
 		cmd.DisplayUsage(cmd.MisuseCode, logsWriteUsage)
@@ -66,6 +74,14 @@ func WriteLog(args []string) {
 	}
 
 	httpClient := client.NewClient(*host)
+	httpClient.SetToken(*token)
+
+	tlsConfig, err := client.NewTLSConfig(*caFile, *certFile, *keyFile)
+	if err != nil {
+		cmd.DisplayError(err)
+	}
+
+	httpClient.SetTLSConfig(tlsConfig)
 
 	if writeOpts.NArg() != 1 {
 		cmd.DisplayUsage(cmd.MisuseCode, logsWriteUsage)
@@ -120,7 +136,7 @@ func WriteLog(args []string) {
 		if *binary {
 			record = decoder.(*log.Record)
 		} else {
-			record = (*log.Record)(decoder.(*recioutil.Line))
+			record.Payload = []byte(*decoder.(*recioutil.Line))
 		}
 
 		_, err = tcpWriter.Write(record)
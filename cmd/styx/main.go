@@ -28,6 +28,10 @@ Commands:
 Global Options:
 	-f, --format string	Output format [text|json] (default "text")
 	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
 	-h, --help 		Display help
 `
 
@@ -40,8 +44,12 @@ Commands:
 	list			List available logs
 	create			Create a new log
 	get			Show log details
+	update			Update a log configuration
 	delete			Delete a log
 	truncate                Truncate a log
+	repair			Repair the torn tail of a log
+	rename			Rename a log
+	copy			Copy a log
 	backup			Backup a log
 	restore			Restore a log
 	write			Write records to a log
@@ -50,6 +58,10 @@ Commands:
 Global Options:
 	-f, --format string	Output format [text|json] (default "text")
 	-H, --host string 	Server to connect to (default "http://localhost:8000")
+	    --token string 	Token used to authenticate requests
+	    --ca-file string 	CA certificates file to verify the server with
+	    --cert-file string	Client certificate file
+	    --key-file string 	Client certificate key file
 	-h, --help 		Display help
 `
 )
@@ -78,10 +90,18 @@ func main() {
 			logs.CreateLog(args[1:])
 		case "get":
 			logs.GetLog(args[1:])
+		case "update":
+			logs.UpdateLog(args[1:])
 		case "delete":
 			logs.DeleteLog(args[1:])
 		case "truncate":
 			logs.TruncateLog(args[1:])
+		case "repair":
+			logs.RepairLog(args[1:])
+		case "rename":
+			logs.RenameLog(args[1:])
+		case "copy":
+			logs.CopyLog(args[1:])
 		case "backup":
 			logs.BackupLog(args[1:])
 		case "restore":
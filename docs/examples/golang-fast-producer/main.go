@@ -35,7 +35,7 @@ func main() {
 			logger.Fatal(err)
 		}
 
-		r := log.Record(payload)
+		r := log.Record{Payload: payload}
 
 		_, err = producer.Write(&r)
 		if err != nil {
@@ -0,0 +1,195 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gitlab.com/dataptive/styx/log"
+)
+
+var (
+	ErrInvalidPattern = errors.New("filter: invalid pattern")
+	ErrMissingPath    = errors.New("filter: missing field path")
+	ErrMissingHeader  = errors.New("filter: missing header name")
+)
+
+// Config holds the conditions records must all match, empty conditions are
+// ignored.
+type Config struct {
+	Contains    string // Payload contains Contains.
+	Pattern     string // Payload matches the Pattern regular expression.
+	Path        string // JSON payload field at Path equals Value.
+	Value       string
+	Header      string // Record has a Header header, equal to HeaderValue if set.
+	HeaderValue string
+}
+
+type Filter struct {
+	contains    []byte
+	pattern     *regexp.Regexp
+	path        []string
+	value       string
+	header      string
+	headerValue string
+}
+
+// New returns a filter matching records against config, or nil when config
+// holds no condition.
+func New(config Config) (f *Filter, err error) {
+
+	if config == (Config{}) {
+		return nil, nil
+	}
+
+	f = &Filter{
+		contains:    []byte(config.Contains),
+		pattern:     nil,
+		path:        nil,
+		value:       config.Value,
+		header:      config.Header,
+		headerValue: config.HeaderValue,
+	}
+
+	if config.Pattern != "" {
+		f.pattern, err = regexp.Compile(config.Pattern)
+		if err != nil {
+			return nil, ErrInvalidPattern
+		}
+	}
+
+	if config.Path != "" {
+		f.path = SplitPath(config.Path)
+	}
+
+	if config.Value != "" && config.Path == "" {
+		return nil, ErrMissingPath
+	}
+
+	if config.HeaderValue != "" && config.Header == "" {
+		return nil, ErrMissingHeader
+	}
+
+	return f, nil
+}
+
+// Match reports whether the record matches all conditions of the filter. A
+// nil filter matches all records.
+func (f *Filter) Match(r *log.Record) (match bool) {
+
+	if f == nil {
+		return true
+	}
+
+	if len(f.contains) > 0 && !bytes.Contains(r.Payload, f.contains) {
+		return false
+	}
+
+	if f.pattern != nil && !f.pattern.Match(r.Payload) {
+		return false
+	}
+
+	if f.path != nil && !f.matchField(r.Payload) {
+		return false
+	}
+
+	if f.header != "" && !f.matchHeader(r.Headers) {
+		return false
+	}
+
+	return true
+}
+
+// matchField compares strings unquoted, and other values by their JSON
+// encoding.
+func (f *Filter) matchField(payload []byte) (match bool) {
+
+	v, found := Lookup(payload, f.path)
+	if !found {
+		return false
+	}
+
+	s, isString := v.(string)
+	if !isString {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+
+		s = string(encoded)
+	}
+
+	return s == f.value
+}
+
+func (f *Filter) matchHeader(headers []log.Header) (match bool) {
+
+	for _, h := range headers {
+
+		if !strings.EqualFold(h.Name, f.header) {
+			continue
+		}
+
+		if f.headerValue == "" || h.Value == f.headerValue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SplitPath splits a dot separated JSON path, such as user.roles.0, into
+// keys.
+func SplitPath(path string) (keys []string) {
+
+	return strings.Split(strings.TrimPrefix(path, "$."), ".")
+}
+
+// Lookup decodes a JSON payload and returns the value found by following
+// keys, which are object keys or array indexes.
+func Lookup(payload []byte, keys []string) (v interface{}, found bool) {
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&v)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, key := range keys {
+
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v, found = node[key]
+			if !found {
+				return nil, false
+			}
+
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+
+			v = node[index]
+
+		default:
+			return nil, false
+		}
+	}
+
+	return v, true
+}
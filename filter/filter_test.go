@@ -0,0 +1,100 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package filter
+
+import (
+	"testing"
+
+	"gitlab.com/dataptive/styx/log"
+)
+
+// Tests that filters match the expected records.
+func TestFilter_Match(t *testing.T) {
+
+	records := []log.Record{
+		{Payload: []byte(`{"level": "error", "code": 500}`), Headers: []log.Header{{Name: "source", Value: "api"}}},
+		{Payload: []byte(`{"level": "info", "code": 200}`), Headers: []log.Header{{Name: "source", Value: "web"}}},
+		{Payload: []byte(`plain text error`)},
+	}
+
+	tests := []struct {
+		config   Config
+		expected []int
+	}{
+		{
+			config:   Config{},
+			expected: []int{0, 1, 2},
+		},
+		{
+			config:   Config{Contains: "error"},
+			expected: []int{0, 2},
+		},
+		{
+			config:   Config{Pattern: `^plain`},
+			expected: []int{2},
+		},
+		{
+			config:   Config{Path: "code", Value: "200"},
+			expected: []int{1},
+		},
+		{
+			config:   Config{Header: "Source"},
+			expected: []int{0, 1},
+		},
+		{
+			config:   Config{Header: "source", HeaderValue: "api"},
+			expected: []int{0},
+		},
+		{
+			config:   Config{Contains: "error", Path: "level", Value: "info"},
+			expected: []int{},
+		},
+	}
+
+	for _, test := range tests {
+
+		f, err := New(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		matched := []int{}
+
+		for i := range records {
+			if f.Match(&records[i]) {
+				matched = append(matched, i)
+			}
+		}
+
+		if len(matched) != len(test.expected) {
+			t.Fatalf("filter %+v matched %v, expected %v", test.config, matched, test.expected)
+		}
+
+		for i := range matched {
+			if matched[i] != test.expected[i] {
+				t.Fatalf("filter %+v matched %v, expected %v", test.config, matched, test.expected)
+			}
+		}
+	}
+
+	invalid := []Config{
+		{Pattern: "(("},
+		{Value: "error"},
+		{HeaderValue: "api"},
+	}
+
+	for _, config := range invalid {
+
+		_, err := New(config)
+		if err == nil {
+			t.Fatalf("filter %+v should be invalid", config)
+		}
+	}
+}
@@ -0,0 +1,481 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/recio"
+)
+
+const (
+	compactInterval = time.Minute
+)
+
+type keepCondition func(position int64, r *Record) bool
+
+type scanHandler func(position int64, timestamp int64, r *Record) (err error)
+
+// keyEntry locates the latest record of a key in the closed segments of a
+// log.
+type keyEntry struct {
+	position  int64
+	segment   int
+	tombstone bool
+}
+
+// compact rewrites the closed segments of the log so that only the latest
+// record of each key survives. Records without key are always kept.
+// Tombstones, records with a key and an empty payload, are removed once the
+// segment holding them was closed for more than TombstoneMaxAge seconds.
+//
+// Removed records leave gaps in positions. Compacted segments keep their base
+// position, offset and timestamp, and record gaps in their index.
+func (l *Log) compact() (err error) {
+
+	config := l.Config()
+
+	if !config.LogCompaction {
+		return nil
+	}
+
+	segmentList, descriptors := l.rewritableSegments()
+
+	if len(descriptors) == 0 {
+		return nil
+	}
+
+	// Find the latest record of each key and count records that can be
+	// removed from each segment, so that only those are rewritten.
+	latest := make(map[string]keyEntry)
+	removable := make([]int64, len(descriptors))
+
+	for i, desc := range descriptors {
+
+		err = l.scanSegment(desc, func(position int64, timestamp int64, r *Record) (err error) {
+
+			if len(r.Key) == 0 {
+				return nil
+			}
+
+			key := string(r.Key)
+
+			previous, exists := latest[key]
+			if exists {
+				removable[previous.segment] += 1
+			}
+
+			latest[key] = keyEntry{
+				position:  position,
+				segment:   i,
+				tombstone: len(r.Payload) == 0,
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// A segment is closed when the next one is created.
+	expiredTimestamp := now.Unix() - config.TombstoneMaxAge
+
+	tombstoneExpired := func(segment int) bool {
+		if config.TombstoneMaxAge == -1 {
+			return false
+		}
+
+		return segmentList[segment+1].baseTimestamp < expiredTimestamp
+	}
+
+	for _, entry := range latest {
+		if entry.tombstone && tombstoneExpired(entry.segment) {
+			removable[entry.segment] += 1
+		}
+	}
+
+	for i, desc := range descriptors {
+
+		if removable[i] == 0 {
+			continue
+		}
+
+		expired := tombstoneExpired(i)
+
+		err = l.compactSegment(desc, func(position int64, r *Record) bool {
+
+			if len(r.Key) == 0 {
+				return true
+			}
+
+			entry := latest[string(r.Key)]
+
+			if entry.position != position {
+				return false
+			}
+
+			if entry.tombstone && expired {
+				return false
+			}
+
+			return true
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rewritableSegments returns a copy of the segment list, along with the closed
+// segments that compaction and compression may rewrite. The last segment is
+// still being written to and is never rewritten, neither are segments holding
+// records from the rollback hold on.
+func (l *Log) rewritableSegments() (segmentList []segmentDescriptor, descriptors []segmentDescriptor) {
+
+	l.stateLock.Lock()
+	segmentList = make([]segmentDescriptor, len(l.segmentList))
+	copy(segmentList, l.segmentList)
+	hold := l.rollbackHold
+	l.stateLock.Unlock()
+
+	if len(segmentList) <= 1 {
+		return segmentList, nil
+	}
+
+	count := len(segmentList) - 1
+
+	if hold != -1 {
+		for count > 0 && segmentList[count].basePosition > hold {
+			count--
+		}
+	}
+
+	return segmentList, segmentList[:count]
+}
+
+// rewritablePosition returns the position following the records of the
+// segments returned by rewritableSegments.
+func (l *Log) rewritablePosition() (position int64) {
+
+	segmentList, descriptors := l.rewritableSegments()
+
+	if len(segmentList) == 0 {
+		return 0
+	}
+
+	return segmentList[len(descriptors)].basePosition
+}
+
+// scanSegment calls handler for each record of a segment, along with its
+// position and the timestamp at which it was written. Segments deleted in the
+// meantime are ignored.
+func (l *Log) scanSegment(desc segmentDescriptor, handler scanHandler) (err error) {
+
+	config := l.Config()
+
+	bufferSize := scanBufferSize
+	if config.MaxRecordSize > bufferSize {
+		bufferSize = config.MaxRecordSize
+	}
+
+	segmentReader, err := newSegmentReader(l.path, desc.segmentName, config, bufferSize)
+	if err == errSegmentNotExist {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer segmentReader.Close()
+
+	if !segmentReader.tracksIndex {
+		err = segmentReader.trackIndex()
+		if err != nil {
+			return err
+		}
+	}
+
+	record := &Record{}
+	for {
+		_, err := segmentReader.Read(record)
+
+		if err == io.EOF {
+			break
+		}
+
+		if err == recio.ErrMustFill {
+			err = segmentReader.Fill()
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		position, _ := segmentReader.Tell()
+
+		err = handler(position-1, segmentReader.timestamp, record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compactSegment writes the next generation of a segment, only keeping the
+// records matching keepCondition, and replaces the segment with it.
+func (l *Log) compactSegment(desc segmentDescriptor, keepCondition keepCondition) (err error) {
+
+	name := buildCompactedSegmentName(desc.basePosition, desc.baseOffset, desc.baseTimestamp, desc.generation+1)
+	pathname := filepath.Join(l.path, name)
+
+	recordsFilename := pathname + recordsSuffix
+	indexFilename := pathname + indexSuffix
+
+	// Write the compacted segment to temporary files first, so that
+	// readers never see it partially written.
+	recordsFile, err := os.OpenFile(recordsFilename+tmpSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(filePerm))
+	if err != nil {
+		return err
+	}
+	defer recordsFile.Close()
+
+	indexFile, err := os.OpenFile(indexFilename+tmpSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(filePerm))
+	if err != nil {
+		return err
+	}
+	defer indexFile.Close()
+
+	config := l.Config()
+
+	bufferSize := scanBufferSize
+	if config.MaxRecordSize > bufferSize {
+		bufferSize = config.MaxRecordSize
+	}
+
+	recordsBufferedWriter := recio.NewBufferedWriter(recordsFile, bufferSize, recio.ModeAuto)
+	recordsAtomicWriter := recio.NewAtomicWriter(recordsBufferedWriter)
+
+	indexBufferedWriter := recio.NewBufferedWriter(indexFile, bufferSize, recio.ModeAuto)
+	indexAtomicWriter := recio.NewAtomicWriter(indexBufferedWriter)
+
+	offset := desc.baseOffset
+	nextPosition := desc.basePosition
+
+	// Index the first record, records following a gap and records whose
+	// timestamp differs from the previous one, so that positions and
+	// timestamps can be recovered from the index.
+	lastIndexEntry := indexEntry{
+		position:  desc.basePosition,
+		offset:    desc.baseOffset,
+		timestamp: unknownTimestamp,
+	}
+
+	first := true
+
+	err = l.scanSegment(desc, func(position int64, timestamp int64, r *Record) (err error) {
+
+		if !keepCondition(position, r) {
+			return nil
+		}
+
+		if first || position != nextPosition || timestamp != lastIndexEntry.timestamp || offset-lastIndexEntry.offset >= config.IndexAfterSize {
+
+			lastIndexEntry = indexEntry{
+				position:  position,
+				offset:    offset,
+				timestamp: timestamp,
+			}
+
+			_, err = indexAtomicWriter.Write(&lastIndexEntry)
+			if err != nil {
+				return err
+			}
+
+			first = false
+		}
+
+		n, err := recordsAtomicWriter.Write(r)
+		if err != nil {
+			return err
+		}
+
+		offset += int64(n)
+		nextPosition = position + 1
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = recordsBufferedWriter.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = indexBufferedWriter.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = recordsFile.Sync()
+	if err != nil {
+		return err
+	}
+
+	err = indexFile.Sync()
+	if err != nil {
+		return err
+	}
+
+	// Segments are listed from their records file, so rename it last.
+	err = os.Rename(indexFilename+tmpSuffix, indexFilename)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(recordsFilename+tmpSuffix, recordsFilename)
+	if err != nil {
+		return err
+	}
+
+	err = syncDirectory(l.path)
+	if err != nil {
+		return err
+	}
+
+	err = l.replaceSegment(desc, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// replaceSegment swaps a segment with its compacted version in the segment
+// list and deletes it. Readers that already opened the previous version can
+// keep on reading it.
+func (l *Log) replaceSegment(desc segmentDescriptor, name string) (err error) {
+
+	l.stateLock.Lock()
+	defer l.stateLock.Unlock()
+
+	pos := -1
+	for i, current := range l.segmentList {
+		if current.segmentName == desc.segmentName {
+			pos = i
+			break
+		}
+	}
+
+	// The segment was deleted by retention in the meantime.
+	if pos == -1 {
+		err = deleteSegment(l.path, name)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	l.segmentList[pos].segmentName = name
+	l.segmentList[pos].generation = desc.generation + 1
+
+	err = deleteSegment(l.path, desc.segmentName)
+	if err != nil {
+		return err
+	}
+
+	l.directoryDirty = true
+
+	return nil
+}
+
+// Compact compacts and compresses the closed segments of the log right away,
+// as the compactor does every minute when segments were closed.
+func (l *Log) Compact() (err error) {
+
+	l.compactLock.Lock()
+	defer l.compactLock.Unlock()
+
+	err = l.compact()
+	if err != nil {
+		return err
+	}
+
+	// Compression runs along compaction so that they never rewrite the
+	// same segment at the same time.
+	err = l.compress()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// compactor compacts and compresses closed segments every compactInterval,
+// only when segments were closed or settings changed since the previous pass,
+// so that idle logs are not read over and over. Expired tombstones are thus
+// removed once a new segment is closed. Compaction is disabled for the log on
+// failure, such as when a closed segment is corrupt.
+func (l *Log) compactor() {
+
+	ticker := time.NewTicker(compactInterval)
+
+	compactedPosition := int64(-1)
+	compactedConfig := Config{}
+	disabled := false
+
+	for {
+		select {
+		case <-ticker.C:
+			if disabled {
+				continue
+			}
+
+			position := l.rewritablePosition()
+			config := l.Config()
+
+			if position == compactedPosition && config.LogCompaction == compactedConfig.LogCompaction && config.Compression == compactedConfig.Compression {
+				continue
+			}
+
+			err := l.Compact()
+			if err != nil {
+				logger.Warnf("log: compaction of %s disabled: %v", l.path, err)
+				disabled = true
+				continue
+			}
+
+			compactedPosition = position
+			compactedConfig = config
+
+		case <-l.compactorStop:
+			ticker.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/dataptive/styx/recio"
+)
+
+type compactionTestRecord struct {
+	position int64
+	key      string
+	payload  string
+}
+
+func testCompaction_Write(t *testing.T, l *Log, records []Record) {
+
+	lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range records {
+		_, err := lw.Write(&records[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testCompaction_Check(t *testing.T, l *Log, expected []compactionTestRecord) {
+
+	lr, err := l.NewReader(1<<20, false, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+
+	for _, e := range expected {
+		var r Record
+
+		_, err := lr.Read(&r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		position, _ := lr.Tell()
+
+		if position-1 != e.position || string(r.Key) != e.key || string(r.Payload) != e.payload {
+			t.Fatalf("should have read %v but got record at position %d with key %q and payload %q", e, position-1, r.Key, r.Payload)
+		}
+	}
+
+	var r Record
+
+	_, err = lr.Read(&r)
+	if err != io.EOF {
+		t.Fatalf("read should have failed with error EOF but got err = %s", err)
+	}
+}
+
+// Tests that compaction only keeps the latest record of each key, and that
+// positions of remaining records are preserved.
+func TestCompaction_Compact(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	config.SegmentMaxCount = 3
+	config.LogCompaction = true
+	config.TombstoneMaxAge = 1
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []Record{
+		{Key: []byte("a"), Payload: []byte("a1")},
+		{Key: []byte("b"), Payload: []byte("b1")},
+		{Key: []byte("a"), Payload: []byte("a2")},
+		{Payload: []byte("x")},
+		{Key: []byte("b"), Payload: []byte("b2")},
+		{Key: []byte("c"), Payload: []byte("c1")},
+		{Key: []byte("a")},
+		{Key: []byte("c"), Payload: []byte("c2")},
+		{Key: []byte("d"), Payload: []byte("d1")},
+		{Key: []byte("e"), Payload: []byte("e1")},
+	}
+
+	testCompaction_Write(t, l, records)
+
+	err = l.compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tombstone of key a is still within its grace period.
+	testCompaction_Check(t, l, []compactionTestRecord{
+		{3, "", "x"},
+		{4, "b", "b2"},
+		{6, "a", ""},
+		{7, "c", "c2"},
+		{8, "d", "d1"},
+		{9, "e", "e1"},
+	})
+
+	time.Sleep(3 * time.Second)
+
+	err = l.compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []compactionTestRecord{
+		{3, "", "x"},
+		{4, "b", "b2"},
+		{7, "c", "c2"},
+		{8, "d", "d1"},
+		{9, "e", "e1"},
+	}
+
+	testCompaction_Check(t, l, expected)
+
+	// Seeking to a removed position should land on the next record.
+	lr, err := l.NewReader(1<<20, false, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lr.Seek(5, SeekOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r Record
+
+	_, err = lr.Read(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(r.Payload) != "c2" {
+		t.Fatalf("should have read c2 but got %q", r.Payload)
+	}
+
+	err = lr.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Scan(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(name, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	testCompaction_Check(t, l, expected)
+}
+
+// Tests that segments holding records from the rollback hold on are never
+// rewritten.
+func TestCompaction_RewritableSegments(t *testing.T) {
+
+	l := &Log{
+		segmentList: []segmentDescriptor{
+			{basePosition: 0},
+			{basePosition: 10},
+			{basePosition: 20},
+			{basePosition: 30},
+		},
+	}
+
+	tests := []struct {
+		hold     int64
+		count    int
+		position int64
+	}{
+		{hold: -1, count: 3, position: 30},
+		{hold: 30, count: 3, position: 30},
+		{hold: 25, count: 2, position: 20},
+		{hold: 20, count: 2, position: 20},
+		{hold: 5, count: 0, position: 0},
+	}
+
+	for _, test := range tests {
+
+		l.rollbackHold = test.hold
+
+		_, descriptors := l.rewritableSegments()
+		if len(descriptors) != test.count {
+			t.Fatalf("hold at %d should allow %d segments but got %d", test.hold, test.count, len(descriptors))
+		}
+
+		position := l.rewritablePosition()
+		if position != test.position {
+			t.Fatalf("hold at %d should allow rewriting up to position %d but got %d", test.hold, test.position, position)
+		}
+	}
+}
@@ -0,0 +1,803 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gitlab.com/dataptive/styx/recio"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+type Compression string
+
+const (
+	CompressionNone   Compression = "none"   // Segments are stored as written.
+	CompressionGzip   Compression = "gzip"   // Closed segments are compressed with gzip.
+	CompressionZstd   Compression = "zstd"   // Closed segments are compressed with zstd.
+	CompressionSnappy Compression = "snappy" // Closed segments are compressed with snappy, faster but larger.
+)
+
+const (
+	// Blocks start at index entries, unless the current block is smaller
+	// than compressedBlockMinSize, and never exceed compressedBlockMaxSize.
+	compressedBlockMinSize = 64 << 10 // 64KB
+	compressedBlockMaxSize = 4 << 20  // 4MB
+
+	compressedHeaderSize     = 8 + 4
+	compressedBlockEntrySize = 8 + 8
+	compressedFooterSize     = 8 + 8 + 4
+)
+
+var (
+	ErrUnknownCompression = errors.New("log: unknown compression")
+
+	errUnsupportedSeek = errors.New("log: unsupported seek")
+
+	// Compressed records files start with a magic number whose first 4
+	// bytes can't be the size of a valid record, which tells them apart
+	// from records files stored as written.
+	compressedMagic = []byte{0xff, 0xff, 0xff, 0xff, 'S', 'X', 'Z', 1}
+
+	compressionCodes = map[Compression]uint32{
+		"":                0,
+		CompressionNone:   0,
+		CompressionGzip:   1,
+		CompressionZstd:   2,
+		CompressionSnappy: 3,
+	}
+
+	compressionNames = map[uint32]Compression{
+		0: CompressionNone,
+		1: CompressionGzip,
+		2: CompressionZstd,
+		3: CompressionSnappy,
+	}
+)
+
+// recordsReader reads the records file of a segment, decompressing it when
+// needed. Offsets are always counted in uncompressed bytes.
+type recordsReader interface {
+	io.ReadSeeker
+	io.Closer
+	Size() (size int64, err error)
+}
+
+type plainRecordsReader struct {
+	*os.File
+}
+
+func (pr *plainRecordsReader) Size() (size int64, err error) {
+
+	fi, err := pr.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return fi.Size(), nil
+}
+
+type compressedBlock struct {
+	offset     int64
+	fileOffset int64
+}
+
+// compressedRecordsReader reads compressed records files, which are made of a
+// header, a sequence of independently compressed blocks, a block table and a
+// footer.
+//
+//	+--------------------+-----------------+
+//	|  magic (8 bytes)   |  codec (uint32) |
+//	+--------------------+-----------------+
+//	+- - - - - - - - - - - - - - - - - - - +
+//	|        compressed blocks             |
+//	+- - - - - - - - - - - - - - - - - - - +
+//	+--------------------------------------+--------------------------------+
+//	|  block offset (int64)                |  block file offset (int64)     |  x block count
+//	+--------------------------------------+--------------------------------+
+//	+-------------------+------------------------+----------------+
+//	|  size (int64)     |  table offset (int64)  |  CRC (uint32)  |
+//	+-------------------+------------------------+----------------+
+//
+// Block offsets are uncompressed byte offsets relative to the start of the
+// segment, and block file offsets locate blocks in the file. Size is the
+// uncompressed size of the segment. The CRC32-C covers the block table, size
+// and table offset.
+type compressedRecordsReader struct {
+	file        *os.File
+	codec       Compression
+	blocks      []compressedBlock
+	tableOffset int64
+	size        int64
+	block       int
+	offset      int64
+	blockReader io.Reader
+	gzipReader  *gzip.Reader
+	zstdReader  *zstd.Decoder
+	blockBuffer []byte
+}
+
+// openRecordsFile opens the records file of a segment, whether it is stored as
+// written or compressed.
+func openRecordsFile(pathname string) (rr recordsReader, err error) {
+
+	file, err := os.OpenFile(pathname, os.O_RDONLY, os.FileMode(0))
+	if err != nil {
+		return nil, err
+	}
+
+	compressed, err := isCompressed(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if !compressed {
+		rr = &plainRecordsReader{
+			File: file,
+		}
+
+		return rr, nil
+	}
+
+	rr, err = newCompressedRecordsReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return rr, nil
+}
+
+func isCompressed(file *os.File) (compressed bool, err error) {
+
+	magic := make([]byte, len(compressedMagic))
+
+	_, err = file.ReadAt(magic, 0)
+	if err == io.EOF {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(magic, compressedMagic), nil
+}
+
+func newCompressedRecordsReader(file *os.File) (cr *compressedRecordsReader, err error) {
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	fileSize := fi.Size()
+
+	if fileSize < compressedHeaderSize+compressedFooterSize {
+		return nil, ErrCorrupt
+	}
+
+	header := make([]byte, compressedHeaderSize)
+
+	_, err = file.ReadAt(header, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, known := compressionNames[binary.BigEndian.Uint32(header[len(compressedMagic):])]
+	if !known || codec == CompressionNone {
+		return nil, ErrCorrupt
+	}
+
+	footer := make([]byte, compressedFooterSize)
+
+	_, err = file.ReadAt(footer, fileSize-compressedFooterSize)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(binary.BigEndian.Uint64(footer[0:]))
+	tableOffset := int64(binary.BigEndian.Uint64(footer[8:]))
+	crc := binary.BigEndian.Uint32(footer[16:])
+
+	if tableOffset < compressedHeaderSize || tableOffset > fileSize-compressedFooterSize {
+		return nil, ErrCorrupt
+	}
+
+	tableSize := fileSize - compressedFooterSize - tableOffset
+
+	if tableSize%compressedBlockEntrySize != 0 {
+		return nil, ErrCorrupt
+	}
+
+	table := make([]byte, tableSize)
+
+	_, err = file.ReadAt(table, tableOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	computedCRC := crc32.Checksum(table, castagnoliTable)
+	computedCRC = crc32.Update(computedCRC, castagnoliTable, footer[:16])
+
+	if crc != computedCRC {
+		return nil, ErrCorrupt
+	}
+
+	blocks := make([]compressedBlock, tableSize/compressedBlockEntrySize)
+
+	for i := range blocks {
+		entry := table[i*compressedBlockEntrySize:]
+
+		blocks[i] = compressedBlock{
+			offset:     int64(binary.BigEndian.Uint64(entry[0:])),
+			fileOffset: int64(binary.BigEndian.Uint64(entry[8:])),
+		}
+	}
+
+	cr = &compressedRecordsReader{
+		file:        file,
+		codec:       codec,
+		blocks:      blocks,
+		tableOffset: tableOffset,
+		size:        size,
+		block:       0,
+		offset:      0,
+		blockReader: nil,
+		gzipReader:  nil,
+		zstdReader:  nil,
+		blockBuffer: nil,
+	}
+
+	return cr, nil
+}
+
+func (cr *compressedRecordsReader) Close() (err error) {
+
+	if cr.zstdReader != nil {
+		cr.zstdReader.Close()
+	}
+
+	err = cr.file.Close()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (cr *compressedRecordsReader) Size() (size int64, err error) {
+
+	return cr.size, nil
+}
+
+func (cr *compressedRecordsReader) Read(p []byte) (n int, err error) {
+
+	for {
+		if cr.blockReader == nil {
+
+			if cr.block >= len(cr.blocks) {
+				return 0, io.EOF
+			}
+
+			err = cr.openBlock(cr.block)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		n, err = cr.blockReader.Read(p)
+		cr.offset += int64(n)
+
+		if cr.offset > cr.blockEnd(cr.block) {
+			return 0, ErrCorrupt
+		}
+
+		if err == io.EOF {
+
+			if cr.offset != cr.blockEnd(cr.block) {
+				return 0, ErrCorrupt
+			}
+
+			cr.block += 1
+			cr.blockReader = nil
+
+			if n == 0 {
+				continue
+			}
+
+			return n, nil
+		}
+
+		if err == gzip.ErrChecksum || err == gzip.ErrHeader || err == io.ErrUnexpectedEOF {
+			return 0, ErrCorrupt
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		return n, nil
+	}
+}
+
+// Seek only supports seeking from the start of the segment, to an offset
+// counted in uncompressed bytes. It decompresses the block holding offset up
+// to it.
+func (cr *compressedRecordsReader) Seek(offset int64, whence int) (ret int64, err error) {
+
+	if whence != io.SeekStart {
+		return 0, errUnsupportedSeek
+	}
+
+	cr.blockReader = nil
+
+	if offset >= cr.size {
+		cr.block = len(cr.blocks)
+		cr.offset = offset
+
+		return offset, nil
+	}
+
+	cr.block = sort.Search(len(cr.blocks), func(i int) bool {
+		return cr.blocks[i].offset > offset
+	}) - 1
+
+	if cr.block < 0 {
+		return 0, ErrCorrupt
+	}
+
+	cr.offset = cr.blocks[cr.block].offset
+
+	_, err = io.CopyN(ioutil.Discard, cr, offset-cr.offset)
+	if err == io.EOF {
+		return 0, ErrCorrupt
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return offset, nil
+}
+
+func (cr *compressedRecordsReader) blockEnd(block int) (offset int64) {
+
+	if block+1 < len(cr.blocks) {
+		return cr.blocks[block+1].offset
+	}
+
+	return cr.size
+}
+
+func (cr *compressedRecordsReader) openBlock(block int) (err error) {
+
+	start := cr.blocks[block].fileOffset
+
+	end := cr.tableOffset
+	if block+1 < len(cr.blocks) {
+		end = cr.blocks[block+1].fileOffset
+	}
+
+	if start < compressedHeaderSize || end < start {
+		return ErrCorrupt
+	}
+
+	section := io.NewSectionReader(cr.file, start, end-start)
+
+	if cr.codec != CompressionGzip {
+		return cr.decodeBlock(block, section)
+	}
+
+	if cr.gzipReader == nil {
+		cr.gzipReader, err = gzip.NewReader(section)
+	} else {
+		err = cr.gzipReader.Reset(section)
+	}
+
+	if err == gzip.ErrHeader || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrCorrupt
+	}
+
+	if err != nil {
+		return err
+	}
+
+	cr.gzipReader.Multistream(false)
+
+	cr.blockReader = cr.gzipReader
+	cr.offset = cr.blocks[block].offset
+
+	return nil
+}
+
+// decodeBlock decompresses a whole block of a codec that isn't streamed.
+func (cr *compressedRecordsReader) decodeBlock(block int, section *io.SectionReader) (err error) {
+
+	compressed := make([]byte, section.Size())
+
+	_, err = io.ReadFull(section, compressed)
+	if err != nil {
+		return err
+	}
+
+	var decoded []byte
+
+	switch cr.codec {
+	case CompressionZstd:
+		if cr.zstdReader == nil {
+			cr.zstdReader, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return err
+			}
+		}
+
+		decoded, err = cr.zstdReader.DecodeAll(compressed, cr.blockBuffer[:0])
+
+	case CompressionSnappy:
+		decoded, err = s2.Decode(cr.blockBuffer[:cap(cr.blockBuffer)], compressed)
+
+	default:
+		return ErrUnknownCompression
+	}
+
+	// Blocks are fully read, decoding errors can only come from
+	// corrupt data.
+	if err != nil {
+		return ErrCorrupt
+	}
+
+	cr.blockBuffer = decoded
+
+	cr.blockReader = bytes.NewReader(decoded)
+	cr.offset = cr.blocks[block].offset
+
+	return nil
+}
+
+// blockCompressor compresses blocks of records files with a codec.
+type blockCompressor struct {
+	codec       Compression
+	buffer      *bytes.Buffer
+	gzipWriter  *gzip.Writer
+	zstdWriter  *zstd.Encoder
+	blockBuffer []byte
+}
+
+func newBlockCompressor(codec Compression) (bc *blockCompressor, err error) {
+
+	bc = &blockCompressor{
+		codec:       codec,
+		buffer:      &bytes.Buffer{},
+		gzipWriter:  nil,
+		zstdWriter:  nil,
+		blockBuffer: nil,
+	}
+
+	switch codec {
+	case CompressionGzip:
+		bc.gzipWriter = gzip.NewWriter(bc.buffer)
+
+	case CompressionZstd:
+		bc.zstdWriter, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+
+	case CompressionSnappy:
+
+	default:
+		return nil, ErrUnknownCompression
+	}
+
+	return bc, nil
+}
+
+func (bc *blockCompressor) Close() (err error) {
+
+	if bc.zstdWriter != nil {
+		err = bc.zstdWriter.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compress returns the compressed version of block, which is only valid
+// until the next call.
+func (bc *blockCompressor) compress(block []byte) (compressed []byte, err error) {
+
+	switch bc.codec {
+	case CompressionGzip:
+		bc.buffer.Reset()
+		bc.gzipWriter.Reset(bc.buffer)
+
+		_, err = bc.gzipWriter.Write(block)
+		if err != nil {
+			return nil, err
+		}
+
+		err = bc.gzipWriter.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		compressed = bc.buffer.Bytes()
+
+	case CompressionZstd:
+		compressed = bc.zstdWriter.EncodeAll(block, bc.blockBuffer[:0])
+		bc.blockBuffer = compressed
+
+	case CompressionSnappy:
+		compressed = s2.EncodeSnappy(bc.blockBuffer[:cap(bc.blockBuffer)], block)
+		bc.blockBuffer = compressed
+	}
+
+	return compressed, nil
+}
+
+// compress compresses the closed segments of the log that are still stored
+// as written. Records files are replaced by their compressed version, so that
+// segment names, positions and offsets are left untouched.
+func (l *Log) compress() (err error) {
+
+	compression := l.Config().Compression
+
+	if compression == CompressionNone || compression == "" {
+		return nil
+	}
+
+	_, known := compressionCodes[compression]
+	if !known {
+		return ErrUnknownCompression
+	}
+
+	_, descriptors := l.rewritableSegments()
+
+	for _, desc := range descriptors {
+
+		err = l.compressSegment(desc, compression)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *Log) compressSegment(desc segmentDescriptor, compression Compression) (err error) {
+
+	pathname := filepath.Join(l.path, desc.segmentName)
+	recordsFilename := pathname + recordsSuffix
+
+	recordsFile, err := os.Open(recordsFilename)
+	if err != nil {
+		// The segment was deleted by retention in the meantime.
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+	defer recordsFile.Close()
+
+	compressed, err := isCompressed(recordsFile)
+	if err != nil {
+		return err
+	}
+
+	if compressed {
+		return nil
+	}
+
+	fi, err := recordsFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := fi.Size()
+
+	offsets, err := l.blockOffsets(desc, size)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.OpenFile(recordsFilename+tmpSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(filePerm))
+	if err != nil {
+		return err
+	}
+	defer tmpFile.Close()
+
+	header := make([]byte, compressedHeaderSize)
+	copy(header, compressedMagic)
+	binary.BigEndian.PutUint32(header[len(compressedMagic):], compressionCodes[compression])
+
+	_, err = tmpFile.Write(header)
+	if err != nil {
+		return err
+	}
+
+	fileOffset := int64(compressedHeaderSize)
+	table := make([]byte, len(offsets)*compressedBlockEntrySize)
+
+	compressor, err := newBlockCompressor(compression)
+	if err != nil {
+		return err
+	}
+	defer compressor.Close()
+
+	block := make([]byte, compressedBlockMaxSize)
+
+	for i, offset := range offsets {
+
+		end := size
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+
+		_, err = io.ReadFull(recordsFile, block[:end-offset])
+		if err != nil {
+			return err
+		}
+
+		compressed, err := compressor.compress(block[:end-offset])
+		if err != nil {
+			return err
+		}
+
+		entry := table[i*compressedBlockEntrySize:]
+		binary.BigEndian.PutUint64(entry[0:], uint64(offset))
+		binary.BigEndian.PutUint64(entry[8:], uint64(fileOffset))
+
+		n, err := tmpFile.Write(compressed)
+		if err != nil {
+			return err
+		}
+
+		fileOffset += int64(n)
+	}
+
+	footer := make([]byte, compressedFooterSize)
+	binary.BigEndian.PutUint64(footer[0:], uint64(size))
+	binary.BigEndian.PutUint64(footer[8:], uint64(fileOffset))
+
+	crc := crc32.Checksum(table, castagnoliTable)
+	crc = crc32.Update(crc, castagnoliTable, footer[:16])
+	binary.BigEndian.PutUint32(footer[16:], crc)
+
+	_, err = tmpFile.Write(table)
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpFile.Write(footer)
+	if err != nil {
+		return err
+	}
+
+	err = tmpFile.Sync()
+	if err != nil {
+		return err
+	}
+
+	l.stateLock.Lock()
+	defer l.stateLock.Unlock()
+
+	exists := false
+	for _, current := range l.segmentList {
+		if current.segmentName == desc.segmentName {
+			exists = true
+			break
+		}
+	}
+
+	// The segment was deleted by retention in the meantime.
+	if !exists {
+		err = os.Remove(recordsFilename + tmpSuffix)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	// Readers that already opened the records file keep on reading the
+	// uncompressed version.
+	err = os.Rename(recordsFilename+tmpSuffix, recordsFilename)
+	if err != nil {
+		return err
+	}
+
+	l.directoryDirty = true
+
+	return nil
+}
+
+// blockOffsets returns the offsets, relative to the start of the segment, at
+// which compressed blocks start. Blocks start at index entries so that seeks
+// don't need to decompress records preceding the entry.
+func (l *Log) blockOffsets(desc segmentDescriptor, size int64) (offsets []int64, err error) {
+
+	pathname := filepath.Join(l.path, desc.segmentName)
+
+	indexFile, err := os.Open(pathname + indexSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer indexFile.Close()
+
+	indexBufferedReader := recio.NewBufferedReader(indexFile, scanBufferSize, recio.ModeAuto)
+	indexAtomicReader := recio.NewAtomicReader(indexBufferedReader)
+
+	start := int64(0)
+	offsets = []int64{}
+
+	if size == 0 {
+		return offsets, nil
+	}
+
+	offsets = append(offsets, start)
+
+	// Split blocks larger than compressedBlockMaxSize.
+	split := func(end int64) {
+		for end-start > compressedBlockMaxSize {
+			start += compressedBlockMaxSize
+			offsets = append(offsets, start)
+		}
+	}
+
+	entry := indexEntry{}
+	for {
+		// Index entries are only used to align blocks, and corrupt
+		// entries are ignored.
+		_, err = indexAtomicReader.Read(&entry)
+
+		if err == recio.ErrCorrupt {
+			continue
+		}
+
+		if err != nil {
+			break
+		}
+
+		offset := entry.offset - desc.baseOffset
+
+		if offset <= start || offset >= size {
+			continue
+		}
+
+		split(offset)
+
+		if offset-start < compressedBlockMinSize {
+			continue
+		}
+
+		start = offset
+		offsets = append(offsets, start)
+	}
+
+	split(size)
+
+	return offsets, nil
+}
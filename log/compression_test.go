@@ -0,0 +1,198 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/dataptive/styx/recio"
+)
+
+func testCompression_Payload(position int64) (payload []byte) {
+
+	return []byte(fmt.Sprintf(`{"position": %d, "event": "compression test record"}`, position))
+}
+
+func testCompression_Check(t *testing.T, l *Log, count int64) {
+
+	lr, err := l.NewReader(1<<20, false, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+
+	var r Record
+
+	for i := int64(0); i < count; i++ {
+		_, err := lr.Read(&r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(r.Payload) != string(testCompression_Payload(i)) {
+			t.Fatalf("should have read %q but got %q", testCompression_Payload(i), r.Payload)
+		}
+	}
+
+	_, err = lr.Read(&r)
+	if err != io.EOF {
+		t.Fatalf("read should have failed with error EOF but got err = %s", err)
+	}
+
+	lr, err = l.NewReader(1<<20, false, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+
+	for _, position := range []int64{0, 1, 2999, 3000, 4567, 8999, 9000, count - 1} {
+
+		err = lr.Seek(position, SeekOrigin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := lr.Read(&r)
+		if err != nil {
+			t.Fatalf("read at position %d failed with err = %s", position, err)
+		}
+
+		if string(r.Payload) != string(testCompression_Payload(position)) {
+			t.Fatalf("should have read %q but got %q", testCompression_Payload(position), r.Payload)
+		}
+	}
+}
+
+// Tests that closed segments are compressed with each codec, and that records
+// stay readable with the same positions and offsets.
+func TestCompression_Compress(t *testing.T) {
+
+	for _, compression := range []Compression{CompressionGzip, CompressionZstd, CompressionSnappy} {
+		t.Run(string(compression), func(t *testing.T) {
+			testCompression_Compress(t, compression)
+		})
+	}
+}
+
+func testCompression_Compress(t *testing.T, compression Compression) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	config.SegmentMaxCount = 3000
+	config.IndexAfterSize = 1 << 10
+	config.Compression = compression
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := int64(10000)
+
+	for i := int64(0); i < count; i++ {
+		_, err := lw.Write(&Record{Payload: testCompression_Payload(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat := l.Stat()
+
+	err = l.compress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Compressing again should leave compressed segments untouched.
+	err = l.compress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptors, err := listSegmentDescriptors(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(descriptors) != 4 {
+		t.Fatalf("log should have 4 segments but has %d", len(descriptors))
+	}
+
+	for i, desc := range descriptors {
+
+		f, err := os.Open(filepath.Join(name, desc.segmentName) + recordsSuffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		compressed, err := isCompressed(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f.Close()
+
+		last := i == len(descriptors)-1
+
+		if compressed == last {
+			t.Fatalf("segment %s should have compressed = %v", desc.segmentName, !last)
+		}
+	}
+
+	if l.Stat() != stat {
+		t.Fatalf("log stat should have been %v but got %v", stat, l.Stat())
+	}
+
+	testCompression_Check(t, l, count)
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Scan(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(name, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if l.Stat() != stat {
+		t.Fatalf("log stat should have been %v but got %v", stat, l.Stat())
+	}
+
+	testCompression_Check(t, l, count)
+}
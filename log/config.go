@@ -17,7 +17,7 @@ import (
 )
 
 const (
-	configVersion = 0
+	configVersion = 2
 )
 
 var (
@@ -30,30 +30,52 @@ var (
 		LogMaxCount:     -1,
 		LogMaxSize:      -1,
 		LogMaxAge:       -1,
+		LogCompaction:   false,
+		TombstoneMaxAge: 24 * 60 * 60, // 1 day
+		Compression:     CompressionNone,
 	}
 )
 
 var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
 
 type Config struct {
-	MaxRecordSize   int   // Maximum size of an encoded record.
-	IndexAfterSize  int64 // Create an index entry every N bytes.
-	SegmentMaxCount int64 // Maximum record count in a segment.
-	SegmentMaxSize  int64 // Maximum byte size of a segment.
-	SegmentMaxAge   int64 // Maximum age in seconds of a segment.
-	LogMaxCount     int64 // Maximum record count in the log.
-	LogMaxSize      int64 // Maximum byte size of the log.
-	LogMaxAge       int64 // Maximum age in seconds of the log.
+	MaxRecordSize   int         // Maximum size of an encoded record.
+	IndexAfterSize  int64       // Create an index entry every N bytes.
+	SegmentMaxCount int64       // Maximum record count in a segment.
+	SegmentMaxSize  int64       // Maximum byte size of a segment.
+	SegmentMaxAge   int64       // Maximum age in seconds of a segment.
+	LogMaxCount     int64       // Maximum record count in the log.
+	LogMaxSize      int64       // Maximum byte size of the log.
+	LogMaxAge       int64       // Maximum age in seconds of the log.
+	LogCompaction   bool        // Only keep the latest record of each key.
+	TombstoneMaxAge int64       // Age in seconds after which compaction removes tombstones.
+	Compression     Compression // Codec used to compress closed segments.
 }
 
 func (config *Config) dump(pathname string) (err error) {
 
-	size := 2*4 + 7*8 + 4
+	compression, known := compressionCodes[config.Compression]
+	if !known {
+		return ErrUnknownCompression
+	}
+
+	// Dump the oldest version holding the settings in use, so that logs
+	// not using newer settings can still be opened by older versions.
+	version := config.version()
+
+	size := 4*4 + 8*8 + 4
+
+	switch version {
+	case 0:
+		size = 2*4 + 7*8 + 4
+	case 1:
+		size = 3*4 + 8*8 + 4
+	}
 
 	buffer := make([]byte, size)
 	n := 0
 
-	binary.BigEndian.PutUint32(buffer[n:n+4], uint32(configVersion))
+	binary.BigEndian.PutUint32(buffer[n:n+4], uint32(version))
 	n += 4
 
 	binary.BigEndian.PutUint32(buffer[n:n+4], uint32(config.MaxRecordSize))
@@ -80,6 +102,26 @@ func (config *Config) dump(pathname string) (err error) {
 	binary.BigEndian.PutUint64(buffer[n:n+8], uint64(config.LogMaxAge))
 	n += 8
 
+	if version >= 1 {
+
+		compaction := 0
+		if config.LogCompaction {
+			compaction = 1
+		}
+
+		binary.BigEndian.PutUint32(buffer[n:n+4], uint32(compaction))
+		n += 4
+
+		binary.BigEndian.PutUint64(buffer[n:n+8], uint64(config.TombstoneMaxAge))
+		n += 8
+	}
+
+	if version >= 2 {
+
+		binary.BigEndian.PutUint32(buffer[n:n+4], compression)
+		n += 4
+	}
+
 	crc := crc32.Checksum(buffer[:n], castagnoliTable)
 
 	binary.BigEndian.PutUint32(buffer[n:n+4], crc)
@@ -92,6 +134,25 @@ func (config *Config) dump(pathname string) (err error) {
 	return nil
 }
 
+// version returns the config file version needed to store config. Version 1
+// adds log compaction settings and version 2 adds segment compression.
+func (config *Config) version() (version int) {
+
+	if config.Compression != DefaultConfig.Compression {
+		return 2
+	}
+
+	if config.LogCompaction != DefaultConfig.LogCompaction {
+		return 1
+	}
+
+	if config.TombstoneMaxAge != DefaultConfig.TombstoneMaxAge {
+		return 1
+	}
+
+	return 0
+}
+
 func (config *Config) load(pathname string) (err error) {
 
 	buffer, err := ioutil.ReadFile(pathname)
@@ -104,12 +165,20 @@ func (config *Config) load(pathname string) (err error) {
 	version := int(binary.BigEndian.Uint32(buffer[n:]))
 	n += 4
 
-	if version != 0 {
+	// Version 0 config files predate log compaction, and version 1 config
+	// files predate segment compression.
+	size := 4*4 + 8*8 + 4
+
+	switch version {
+	case 0:
+		size = 2*4 + 7*8 + 4
+	case 1:
+		size = 3*4 + 8*8 + 4
+	case configVersion:
+	default:
 		return ErrBadVersion
 	}
 
-	size := 2*4 + 7*8 + 4
-
 	if len(buffer) != size {
 		return ErrCorrupt
 	}
@@ -138,6 +207,30 @@ func (config *Config) load(pathname string) (err error) {
 	config.LogMaxAge = int64(binary.BigEndian.Uint64(buffer[n:]))
 	n += 8
 
+	config.LogCompaction = DefaultConfig.LogCompaction
+	config.TombstoneMaxAge = DefaultConfig.TombstoneMaxAge
+	config.Compression = DefaultConfig.Compression
+
+	if version >= 1 {
+
+		config.LogCompaction = binary.BigEndian.Uint32(buffer[n:]) != 0
+		n += 4
+
+		config.TombstoneMaxAge = int64(binary.BigEndian.Uint64(buffer[n:]))
+		n += 8
+	}
+
+	if version >= 2 {
+
+		compression, known := compressionNames[binary.BigEndian.Uint32(buffer[n:])]
+		if !known {
+			return ErrUnknownCompression
+		}
+
+		config.Compression = compression
+		n += 4
+	}
+
 	crc := binary.BigEndian.Uint32(buffer[n:])
 
 	computedCRC := crc32.Checksum(buffer[:n], castagnoliTable)
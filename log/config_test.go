@@ -0,0 +1,182 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/dataptive/styx/recio"
+)
+
+func testConfig_Write(t *testing.T, lw *LogWriter, count int) {
+
+	for i := 0; i < count; i++ {
+		_, err := lw.Write(&Record{Payload: []byte("config test record")})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that config changes apply to an open log with an open writer, and
+// are persisted.
+func TestConfig_SetConfig(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	config.SegmentMaxCount = 100
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testConfig_Write(t, lw, 1000)
+
+	// Rolling settings apply to the current segment.
+	config.SegmentMaxCount = 50
+	config.LogMaxCount = 300
+
+	err = l.SetConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Retention is enforced right away.
+	stat := l.Stat()
+	if stat.StartPosition != 700 {
+		t.Fatalf("log should start at position 700 but starts at %d", stat.StartPosition)
+	}
+
+	testConfig_Write(t, lw, 100)
+
+	descriptors, err := listSegmentDescriptors(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Segments are rolled on the next write, the current segment was full
+	// and two segments of 50 records were written since.
+	last := descriptors[len(descriptors)-1]
+	if last.basePosition != 1050 {
+		t.Fatalf("last segment should start at position 1050 but starts at %d", last.basePosition)
+	}
+
+	immutable := config
+	immutable.MaxRecordSize = 1 << 10
+
+	err = l.SetConfig(immutable)
+	if err != ErrImmutable {
+		t.Fatalf("set config should have failed with err = %s but got err = %v", ErrImmutable, err)
+	}
+
+	unknown := config
+	unknown.Compression = "unknown"
+
+	err = l.SetConfig(unknown)
+	if err != ErrUnknownCompression {
+		t.Fatalf("set config should have failed with err = %s but got err = %v", ErrUnknownCompression, err)
+	}
+
+	err = lw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(name, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if l.Config() != config {
+		t.Fatalf("log config should have been %v but got %v", config, l.Config())
+	}
+}
+
+// Tests that configs are dumped with the oldest version holding their
+// settings, and loaded back.
+func TestConfig_DumpLoad(t *testing.T) {
+
+	compaction := DefaultConfig
+	compaction.LogCompaction = true
+
+	tombstones := DefaultConfig
+	tombstones.TombstoneMaxAge = -1
+
+	compression := DefaultConfig
+	compression.LogCompaction = true
+	compression.Compression = CompressionGzip
+
+	tests := []struct {
+		name    string
+		config  Config
+		version int
+	}{
+		{name: "default", config: DefaultConfig, version: 0},
+		{name: "compaction", config: compaction, version: 1},
+		{name: "tombstones", config: tombstones, version: 1},
+		{name: "compression", config: compression, version: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			pathname := filepath.Join(t.TempDir(), configFilename)
+
+			err := test.config.dump(pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buffer, err := ioutil.ReadFile(pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			version := int(binary.BigEndian.Uint32(buffer))
+			if version != test.version {
+				t.Fatalf("config should be dumped with version %d, got %d", test.version, version)
+			}
+
+			config := Config{}
+
+			err = config.load(pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if config != test.config {
+				t.Fatalf("expected config %+v, got %+v", test.config, config)
+			}
+		})
+	}
+}
@@ -10,23 +10,37 @@
 package log
 
 import (
+	"errors"
 	"sync"
 	"sync/atomic"
 
 	"gitlab.com/dataptive/styx/recio"
 )
 
+var (
+	ErrPositionMismatch = errors.New("log: unexpected end position")
+)
+
 type Fanin struct {
 	waitingLock     int32
 	logWriter       *LogWriter
 	writeLock       sync.Mutex
 	subscribers     []chan SyncProgress
 	subscribersLock sync.Mutex
+	producers       *producerStore
 	closed          bool
 	closeLock       sync.Mutex
 }
 
-func NewFanin(lw *LogWriter) (f *Fanin) {
+// NewFanin returns a fanin writing to lw. Records holding a producer id and a
+// sequence that isn't greater than the last one written for this producer are
+// dropped, and their write fails with ErrDuplicate.
+func NewFanin(lw *LogWriter) (f *Fanin, err error) {
+
+	producers, err := openProducerStore(lw.log)
+	if err != nil {
+		return nil, err
+	}
 
 	f = &Fanin{
 		logWriter:       lw,
@@ -34,13 +48,14 @@ func NewFanin(lw *LogWriter) (f *Fanin) {
 		writeLock:       sync.Mutex{},
 		subscribers:     []chan SyncProgress{},
 		subscribersLock: sync.Mutex{},
+		producers:       producers,
 		closed:          false,
 		closeLock:       sync.Mutex{},
 	}
 
 	lw.HandleSync(f.syncHandler)
 
-	return f
+	return f, nil
 }
 
 func (f *Fanin) Close() (err error) {
@@ -63,11 +78,27 @@ func (f *Fanin) Write(r *Record) (n int, err error) {
 		return 0, ErrClosed
 	}
 
+	id, sequence, err := r.Producer()
+	if err != nil {
+		return 0, err
+	}
+
+	// Drop records already written by the producer, typically resent
+	// after a lost acknowledgement.
+	if id != "" && !f.producers.accepts(id, sequence) {
+		return 0, ErrDuplicate
+	}
+
 	n, err = f.logWriter.Write(r)
 	if err != nil {
 		return n, err
 	}
 
+	if id != "" {
+		position, _ := f.logWriter.Tell()
+		f.producers.update(id, sequence, position)
+	}
+
 	return n, nil
 }
 
@@ -88,8 +119,30 @@ func (f *Fanin) Flush() (err error) {
 	return nil
 }
 
+// Skip moves the write position forward, see LogWriter.Skip.
+func (f *Fanin) Skip(position int64) (err error) {
+
+	f.closeLock.Lock()
+	defer f.closeLock.Unlock()
+
+	if f.closed {
+		return ErrClosed
+	}
+
+	err = f.logWriter.Skip(position)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (f *Fanin) syncHandler(syncProgress SyncProgress) {
 
+	// A failed checkpoint is harmless, records written since the
+	// previous one are scanned when opening the log.
+	f.producers.synced(syncProgress.Position)
+
 	f.subscribersLock.Lock()
 	defer f.subscribersLock.Unlock()
 
@@ -132,39 +185,45 @@ func (f *Fanin) unsubscribe(subscriber chan SyncProgress) {
 }
 
 type FaninWriter struct {
-	fanin           *Fanin
-	ioMode          recio.IOMode
-	ownsLock        bool
-	mustFlush       bool
-	flushedCount    int64
-	initialPosition int64
-	pendingSyncs    []SyncProgress
-	pendingLock     sync.Mutex
-	syncChan        chan SyncProgress
-	syncHandler     SyncHandler
-	notifierStop    chan struct{}
-	notifierDone    chan struct{}
-	closed          bool
-	closeLock       sync.Mutex
+	fanin            *Fanin
+	ioMode           recio.IOMode
+	ownsLock         bool
+	mustFlush        bool
+	flushedCount     int64
+	initialPosition  int64
+	conditional      bool
+	expectedPosition int64
+	holdsLock        bool
+	pendingSyncs     []SyncProgress
+	pendingLock      sync.Mutex
+	syncChan         chan SyncProgress
+	syncHandler      SyncHandler
+	notifierStop     chan struct{}
+	notifierDone     chan struct{}
+	closed           bool
+	closeLock        sync.Mutex
 }
 
 func NewFaninWriter(f *Fanin, ioMode recio.IOMode) (fw *FaninWriter) {
 
 	fw = &FaninWriter{
-		fanin:           f,
-		ioMode:          ioMode,
-		ownsLock:        false,
-		mustFlush:       false,
-		flushedCount:    0,
-		initialPosition: 0,
-		pendingSyncs:    []SyncProgress{},
-		pendingLock:     sync.Mutex{},
-		syncChan:        make(chan SyncProgress, 1),
-		syncHandler:     nil,
-		notifierStop:    make(chan struct{}),
-		notifierDone:    make(chan struct{}),
-		closed:          false,
-		closeLock:       sync.Mutex{},
+		fanin:            f,
+		ioMode:           ioMode,
+		ownsLock:         false,
+		mustFlush:        false,
+		flushedCount:     0,
+		initialPosition:  0,
+		conditional:      false,
+		expectedPosition: 0,
+		holdsLock:        false,
+		pendingSyncs:     []SyncProgress{},
+		pendingLock:      sync.Mutex{},
+		syncChan:         make(chan SyncProgress, 1),
+		syncHandler:      nil,
+		notifierStop:     make(chan struct{}),
+		notifierDone:     make(chan struct{}),
+		closed:           false,
+		closeLock:        sync.Mutex{},
 	}
 
 	fw.fanin.subscribe(fw.syncChan)
@@ -174,6 +233,53 @@ func NewFaninWriter(f *Fanin, ioMode recio.IOMode) (fw *FaninWriter) {
 	return fw
 }
 
+// ExpectPosition makes writes fail with ErrPositionMismatch unless the log
+// ends at position when the writer starts writing. The writer then keeps
+// other writers out until it is closed, so that its records are written in a
+// row.
+func (fw *FaninWriter) ExpectPosition(position int64) {
+
+	fw.conditional = true
+	fw.expectedPosition = position
+	fw.holdsLock = true
+}
+
+// Acquire keeps other writers out until the writer is closed, and returns the
+// position at which its records will be written. Conditional writers fail
+// with ErrPositionMismatch when the log doesn't end at the expected position.
+func (fw *FaninWriter) Acquire() (position int64, err error) {
+
+	fw.closeLock.Lock()
+	defer fw.closeLock.Unlock()
+
+	if fw.closed {
+		return 0, ErrClosed
+	}
+
+	if !fw.ownsLock {
+		fw.acquireWriteLock()
+		fw.saveCurrentPosition()
+
+		if fw.conditional && fw.initialPosition != fw.expectedPosition {
+			fw.releaseWriteLock()
+			return 0, ErrPositionMismatch
+		}
+	}
+
+	fw.holdsLock = true
+
+	return fw.initialPosition, nil
+}
+
+// Tell returns the position following the last record written by a writer
+// holding the lock.
+func (fw *FaninWriter) Tell() (position int64) {
+
+	position, _ = fw.fanin.logWriter.Tell()
+
+	return position
+}
+
 func (fw *FaninWriter) HandleSync(h SyncHandler) {
 
 	fw.syncHandler = h
@@ -218,6 +324,12 @@ Retry:
 			return 0, ErrClosed
 		}
 
+		if fw.conditional && fw.initialPosition != fw.expectedPosition {
+			fw.releaseWriteLock()
+			fw.closeLock.Unlock()
+			return 0, ErrPositionMismatch
+		}
+
 		fw.closeLock.Unlock()
 	}
 
@@ -245,6 +357,38 @@ Retry:
 	return n, nil
 }
 
+// Skip flushes buffered records and moves the write position forward, leaving
+// a gap for records removed from the log being copied.
+func (fw *FaninWriter) Skip(position int64) (err error) {
+
+	err = fw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fw.closeLock.Lock()
+	defer fw.closeLock.Unlock()
+
+	if fw.closed {
+		return ErrClosed
+	}
+
+	if !fw.ownsLock {
+		fw.acquireWriteLock()
+	}
+
+	err = fw.fanin.Skip(position)
+
+	// Skipped positions don't count as written records.
+	fw.saveCurrentPosition()
+
+	if !fw.holdsLock {
+		fw.releaseWriteLock()
+	}
+
+	return err
+}
+
 func (fw *FaninWriter) Flush() (err error) {
 
 	fw.closeLock.Lock()
@@ -260,7 +404,9 @@ func (fw *FaninWriter) Flush() (err error) {
 
 	waitingLock := atomic.LoadInt32(&fw.fanin.waitingLock)
 
-	if fw.mustFlush || waitingLock == 1 {
+	// Writers holding the lock always flush, since waiting writers can't
+	// do it for them.
+	if fw.mustFlush || waitingLock == 1 || fw.holdsLock {
 
 		err = fw.fanin.Flush()
 		if err != nil {
@@ -271,6 +417,14 @@ func (fw *FaninWriter) Flush() (err error) {
 	}
 
 	fw.addPendingSync()
+
+	// Conditional and acquiring writers only release the lock when
+	// closed.
+	if fw.holdsLock {
+		fw.saveCurrentPosition()
+		return nil
+	}
+
 	fw.releaseWriteLock()
 
 	return nil
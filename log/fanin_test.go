@@ -0,0 +1,151 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/dataptive/styx/recio"
+)
+
+// Tests that conditional writers only write when the log ends at the
+// expected position, and keep other writers out until closed.
+func TestFaninWriter_ExpectPosition(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	l, err := Create(name, DefaultConfig, DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lw.Close()
+
+	f, err := NewFanin(lw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	record := &Record{Payload: []byte("conditional test record")}
+
+	fw := NewFaninWriter(f, recio.ModeAuto)
+	fw.ExpectPosition(0)
+
+	for i := 0; i < 2; i++ {
+		_, err = fw.Write(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = fw.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Unconditional writes wait for the conditional writer to be closed.
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		other := NewFaninWriter(f, recio.ModeAuto)
+		defer other.Close()
+
+		_, err := other.Write(record)
+		if err != nil {
+			t.Error(err)
+		}
+
+		err = other.Flush()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	_, err = fw.Write(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	position, _ := lw.Tell()
+	if position != 3 {
+		t.Fatalf("expected position 3, got %d", position)
+	}
+
+	err = fw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-done
+
+	fw = NewFaninWriter(f, recio.ModeAuto)
+	fw.ExpectPosition(3)
+
+	_, err = fw.Write(record)
+	if err != ErrPositionMismatch {
+		t.Fatalf("expected %v, got %v", ErrPositionMismatch, err)
+	}
+
+	err = fw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Acquiring checks the position without writing.
+	fw = NewFaninWriter(f, recio.ModeAuto)
+	fw.ExpectPosition(3)
+
+	_, err = fw.Acquire()
+	if err != ErrPositionMismatch {
+		t.Fatalf("expected %v, got %v", ErrPositionMismatch, err)
+	}
+
+	err = fw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fw = NewFaninWriter(f, recio.ModeAuto)
+	fw.ExpectPosition(4)
+
+	position, err = fw.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if position != 4 {
+		t.Fatalf("expected position 4, got %d", position)
+	}
+
+	_, err = fw.Write(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
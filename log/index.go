@@ -11,62 +11,106 @@ package log
 
 import (
 	"encoding/binary"
+	"errors"
 
 	"gitlab.com/dataptive/styx/recio"
 )
 
 const (
-	indexEntrySize = 8 + 8 + 4
+	indexEntrySize = 8 + 8 + 8 + 4
+
+	// Flag set on the encoded position of index entries carrying a
+	// timestamp, allowing them to be told apart from legacy entries.
+	indexTimestampFlag = 1 << 63
+
+	// Timestamp of index entries whose records write time is unknown.
+	unknownTimestamp = -1
+)
+
+var (
+	errUnsearchableIndex = errors.New("log: index can't be searched")
 )
 
-// indexEntry implements the encoding and decoding of record position and
-// offset pairs. Encoded index entries are structured as follows. A CRC32-C
-// of the index entry is implicitly appended and checked when using recio
-// atomic readers / writers.
+// indexEntry implements the encoding and decoding of record position, offset
+// and timestamp triplets. Encoded index entries are structured as follows. A
+// CRC32-C of the index entry is implicitly appended and checked when using
+// recio atomic readers / writers.
 //
-//	+--------------------+--------------------+- - - - - - - - +
-//	|  position (int64)  |   offset (int64)   |  CRC (uint32)  |
-//	+--------------------+--------------------+- - - - - - - - +
+//	+--------------------+--------------------+--------------------+- - - - - - - - +
+//	|  position (int64)  |   offset (int64)   | timestamp (int64)  |  CRC (uint32)  |
+//	+--------------------+--------------------+--------------------+- - - - - - - - +
 //
-// Position and offset are big-endian int64, and encode respectively a record's
-// absolute position (or sequence number from the log origin) and absolute byte
-// offset.
+// Position, offset and timestamp are big-endian int64, and encode
+// respectively a record's absolute position (or sequence number from the log
+// origin), absolute byte offset, and the Unix timestamp at which the records
+// following the entry were written.
 //
+// The most significant bit of the encoded position is always set. Index
+// entries written by earlier versions have no timestamp field and a cleared
+// most significant bit. They are still decoded, with their timestamp set to
+// unknownTimestamp.
 type indexEntry struct {
-	position int64
-	offset   int64
+	position  int64
+	offset    int64
+	timestamp int64
 }
 
 // Encode encodes the indexEntry to p.
 func (ie *indexEntry) Encode(p []byte) (n int, err error) {
 
 	// Check that we can encode a complete index entry.
-	if 8+8 > len(p) {
+	if 8+8+8 > len(p) {
 		return 0, recio.ErrShortBuffer
 	}
 
-	binary.BigEndian.PutUint64(p, uint64(ie.position))
+	binary.BigEndian.PutUint64(p, uint64(ie.position)|indexTimestampFlag)
 	n += 8
 
 	binary.BigEndian.PutUint64(p[n:], uint64(ie.offset))
 	n += 8
 
+	binary.BigEndian.PutUint64(p[n:], uint64(ie.timestamp))
+	n += 8
+
 	return n, nil
 }
 
 // Decode decodes the indexEntry from p.
 func (ie *indexEntry) Decode(p []byte) (n int, err error) {
 
-	// Check that we can decode a complete index entry.
+	// Check that we can decode a complete legacy index entry.
 	if 8+8 > len(p) {
 		return 0, recio.ErrShortBuffer
 	}
 
-	ie.position = int64(binary.BigEndian.Uint64(p[:8]))
+	position := binary.BigEndian.Uint64(p[:8])
+
+	if position&indexTimestampFlag == 0 {
+
+		ie.position = int64(position)
+		n += 8
+
+		ie.offset = int64(binary.BigEndian.Uint64(p[n : n+8]))
+		n += 8
+
+		ie.timestamp = unknownTimestamp
+
+		return n, nil
+	}
+
+	// Check that we can decode a complete index entry.
+	if 8+8+8 > len(p) {
+		return 0, recio.ErrShortBuffer
+	}
+
+	ie.position = int64(position &^ indexTimestampFlag)
 	n += 8
 
 	ie.offset = int64(binary.BigEndian.Uint64(p[n : n+8]))
 	n += 8
 
+	ie.timestamp = int64(binary.BigEndian.Uint64(p[n : n+8]))
+	n += 8
+
 	return n, nil
 }
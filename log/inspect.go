@@ -0,0 +1,353 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"gitlab.com/dataptive/styx/lockfile"
+	"gitlab.com/dataptive/styx/recio"
+)
+
+// Functions below inspect log files directly, without opening the log. They
+// never modify files, and keep reading damaged segments as far as possible.
+
+// SegmentInfo describes a segment as stored on disk.
+type SegmentInfo struct {
+	Name          string
+	BasePosition  int64
+	BaseOffset    int64
+	BaseTimestamp int64
+	Generation    int64 // Count of compactions of the segment.
+	Compressed    bool
+	RecordsSize   int64 // Size of the records file, -1 if missing.
+	IndexSize     int64 // Size of the index file, -1 if missing.
+}
+
+// IndexEntry is a decoded segment index entry. Timestamp is -1 when the write
+// time of the records following the entry is unknown.
+type IndexEntry struct {
+	Position  int64
+	Offset    int64
+	Timestamp int64
+}
+
+// SegmentReport is the result of checking the CRCs of a segment. Records
+// are counted up to the first invalid one, whose error is reported.
+type SegmentReport struct {
+	Segment      string
+	Records      int64
+	EndPosition  int64 // Position following the last valid record.
+	EndOffset    int64 // Offset following the last valid record.
+	IndexEntries int64
+	RecordsError error
+	IndexError   error
+}
+
+// OK reports whether both the records and index files of the segment are
+// valid.
+func (sr SegmentReport) OK() (ok bool) {
+
+	return sr.RecordsError == nil && sr.IndexError == nil
+}
+
+// InspectHandler is called for each record read by InspectRecords, along with
+// its position and offset, and the timestamp of the index entry preceding it.
+type InspectHandler func(position int64, offset int64, timestamp int64, r *Record) (err error)
+
+// ReadConfig reads the config of a log.
+func ReadConfig(path string) (config Config, err error) {
+
+	err = config.load(filepath.Join(path, configFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, ErrNotExist
+		}
+
+		return config, err
+	}
+
+	return config, nil
+}
+
+// IsOpened reports whether a log is currently opened by another process, such
+// as a running server.
+func IsOpened(path string) (opened bool, err error) {
+
+	pathname := filepath.Join(path, lockFilename)
+
+	// Opened logs always hold their lock file. Don't create one, so that
+	// the log can still be opened meanwhile.
+	_, err = os.Stat(pathname)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	// Orphaned lock files are left in place, so that the log is still
+	// scanned when opened.
+	err = lockfile.New(pathname, os.FileMode(filePerm)).Acquire()
+	if err == lockfile.ErrLocked {
+		return true, nil
+	}
+
+	if err != nil && err != lockfile.ErrOrphaned {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// ListSegments returns the segments of a log, ordered by position.
+func ListSegments(path string) (segments []SegmentInfo, err error) {
+
+	descriptors, err := listSegmentDescriptors(path)
+	if err != nil {
+		return nil, err
+	}
+
+	segments = []SegmentInfo{}
+
+	for _, desc := range descriptors {
+
+		pathname := filepath.Join(path, desc.segmentName)
+
+		si := SegmentInfo{
+			Name:          desc.segmentName,
+			BasePosition:  desc.basePosition,
+			BaseOffset:    desc.baseOffset,
+			BaseTimestamp: desc.baseTimestamp,
+			Generation:    desc.generation,
+			Compressed:    false,
+			RecordsSize:   fileSize(pathname + recordsSuffix),
+			IndexSize:     fileSize(pathname + indexSuffix),
+		}
+
+		recordsFile, err := os.Open(pathname + recordsSuffix)
+		if err == nil {
+			si.Compressed, _ = isCompressed(recordsFile)
+			recordsFile.Close()
+		}
+
+		segments = append(segments, si)
+	}
+
+	return segments, nil
+}
+
+// ReadIndex returns the entries of a segment index. Entries read before an
+// invalid one are returned along with the error.
+func ReadIndex(path string, segment string) (entries []IndexEntry, err error) {
+
+	desc, err := findSegmentDescriptor(path, segment)
+	if err != nil {
+		return nil, err
+	}
+
+	return readIndex(filepath.Join(path, desc.segmentName) + indexSuffix)
+}
+
+// InspectRecords calls handler for each record of a segment, up to the first
+// invalid one whose error is returned. Positions of compacted segments are
+// taken from the segment index when readable.
+func InspectRecords(path string, segment string, handler InspectHandler) (err error) {
+
+	_, _, err = inspectRecords(path, segment, handler)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// inspectRecords implements InspectRecords, and returns the position and offset
+// following the last valid record.
+func inspectRecords(path string, segment string, handler InspectHandler) (position int64, offset int64, err error) {
+
+	config, err := ReadConfig(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	desc, err := findSegmentDescriptor(path, segment)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	position = desc.basePosition
+	offset = desc.baseOffset
+
+	pathname := filepath.Join(path, desc.segmentName)
+
+	// Damaged indexes only make positions and timestamps unknown.
+	entries, _ := readIndex(pathname + indexSuffix)
+
+	recordsReader, err := openRecordsFile(pathname + recordsSuffix)
+	if err != nil {
+		return position, offset, err
+	}
+	defer recordsReader.Close()
+
+	return readRecords(recordsReader, desc, config, entries, handler)
+}
+
+// readRecords reads records up to the first invalid one, taking their
+// positions and timestamps from index entries.
+func readRecords(recordsReader io.Reader, desc segmentDescriptor, config Config, entries []IndexEntry, handler InspectHandler) (position int64, offset int64, err error) {
+
+	position = desc.basePosition
+	offset = desc.baseOffset
+
+	bufferSize := scanBufferSize
+	if config.MaxRecordSize > bufferSize {
+		bufferSize = config.MaxRecordSize
+	}
+
+	recordsBufferedReader := recio.NewBufferedReader(recordsReader, bufferSize, recio.ModeAuto)
+	recordsAtomicReader := recio.NewAtomicReader(recordsBufferedReader)
+
+	timestamp := int64(unknownTimestamp)
+
+	r := Record{}
+	for {
+		n, err := recordsAtomicReader.Read(&r)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return position, offset, err
+		}
+
+		for len(entries) > 0 && entries[0].Offset <= offset {
+			if entries[0].Offset == offset {
+				position = entries[0].Position
+				timestamp = entries[0].Timestamp
+			}
+
+			entries = entries[1:]
+		}
+
+		err = handler(position, offset, timestamp, &r)
+		if err != nil {
+			return position, offset, err
+		}
+
+		position += 1
+		offset += int64(n)
+	}
+
+	return position, offset, nil
+}
+
+// VerifySegments checks the CRCs of the records and index files of all
+// segments of a log.
+func VerifySegments(path string) (reports []SegmentReport, err error) {
+
+	segments, err := ListSegments(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reports = []SegmentReport{}
+
+	for _, si := range segments {
+
+		entries, indexErr := ReadIndex(path, si.Name)
+
+		records := int64(0)
+		position, offset, recordsErr := inspectRecords(path, si.Name, func(position int64, offset int64, timestamp int64, r *Record) (err error) {
+
+			records += 1
+
+			return nil
+		})
+
+		sr := SegmentReport{
+			Segment:      si.Name,
+			Records:      records,
+			EndPosition:  position,
+			EndOffset:    offset,
+			IndexEntries: int64(len(entries)),
+			RecordsError: recordsErr,
+			IndexError:   indexErr,
+		}
+
+		reports = append(reports, sr)
+	}
+
+	return reports, nil
+}
+
+// readIndex returns the entries of an index file, up to the first invalid one.
+func readIndex(pathname string) (entries []IndexEntry, err error) {
+
+	indexFile, err := os.Open(pathname)
+	if err != nil {
+		return nil, err
+	}
+	defer indexFile.Close()
+
+	indexBufferedReader := recio.NewBufferedReader(indexFile, scanBufferSize, recio.ModeAuto)
+	indexAtomicReader := recio.NewAtomicReader(indexBufferedReader)
+
+	entries = []IndexEntry{}
+
+	ie := indexEntry{}
+	for {
+		_, err = indexAtomicReader.Read(&ie)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return entries, err
+		}
+
+		entries = append(entries, IndexEntry{
+			Position:  ie.position,
+			Offset:    ie.offset,
+			Timestamp: ie.timestamp,
+		})
+	}
+
+	return entries, nil
+}
+
+func findSegmentDescriptor(path string, segment string) (desc segmentDescriptor, err error) {
+
+	descriptors, err := listSegmentDescriptors(path)
+	if err != nil {
+		return desc, err
+	}
+
+	for _, desc := range descriptors {
+		if desc.segmentName == segment {
+			return desc, nil
+		}
+	}
+
+	return desc, errSegmentNotExist
+}
+
+func fileSize(pathname string) (size int64) {
+
+	fi, err := os.Stat(pathname)
+	if err != nil {
+		return -1
+	}
+
+	return fi.Size()
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that segments are listed and read without opening the log, and that
+// verification reports the first invalid record of corrupt segments.
+func TestInspect_Verify(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	config.SegmentMaxCount = 10
+	config.IndexAfterSize = 64
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []Record{}
+	for i := 0; i < 25; i++ {
+		records = append(records, Record{Payload: []byte("some record payload")})
+	}
+
+	testCompaction_Write(t, l, records)
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := ListSegments(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(segments) != 3 || segments[1].BasePosition != 10 || segments[2].BasePosition != 20 {
+		t.Fatalf("log should have 3 segments of 10 records but got %+v", segments)
+	}
+
+	entries, err := ReadIndex(name, segments[1].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) == 0 || entries[0].Position != 10 || entries[0].Offset != segments[1].BaseOffset {
+		t.Fatalf("index should start at the segment base but got %+v", entries)
+	}
+
+	positions := []int64{}
+	err = InspectRecords(name, segments[2].Name, func(position int64, offset int64, timestamp int64, r *Record) (err error) {
+
+		positions = append(positions, position)
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(positions) != 5 || positions[0] != 20 || positions[4] != 24 {
+		t.Fatalf("segment should have records 20 to 24 but got %v", positions)
+	}
+
+	// Corrupt the payload of the 4th record of the second segment.
+	recordSize := (segments[2].BaseOffset - segments[1].BaseOffset) / 10
+
+	f, err := os.OpenFile(filepath.Join(name, segments[1].Name)+recordsSuffix, os.O_WRONLY, os.FileMode(filePerm))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.WriteAt([]byte{0xff}, 3*recordSize+5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+
+	reports, err := VerifySegments(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, sr := range reports {
+
+		if i == 1 {
+			if sr.OK() || sr.Records != 3 || sr.EndPosition != 13 || sr.EndOffset != segments[1].BaseOffset+3*recordSize {
+				t.Fatalf("segment should be reported corrupt after 3 records but got %+v", sr)
+			}
+
+			continue
+		}
+
+		if !sr.OK() {
+			t.Fatalf("segment should be reported valid but got %+v", sr)
+		}
+	}
+}
@@ -57,6 +57,8 @@ var (
 	ErrOrphaned   = errors.New("log: orphaned")
 	ErrClosed     = errors.New("log: closed")
 	ErrTimeout    = errors.New("log: timeout")
+	ErrImmutable  = errors.New("log: setting can't be changed")
+	ErrRollback   = errors.New("log: records can't be rolled back")
 
 	now = clock.New(time.Second)
 )
@@ -68,6 +70,10 @@ const (
 	SeekStart   Whence = "start"   // Seek from the first available record.
 	SeekCurrent Whence = "current" // Seek from the current position.
 	SeekEnd     Whence = "end"     // Seek from the end of the log.
+
+	// Seek to the first record written at or after a Unix timestamp in
+	// seconds. Write times are used, not record timestamps.
+	SeekTimestamp Whence = "timestamp"
 )
 
 type breakCondition func(segmentDescriptor) bool
@@ -91,7 +97,11 @@ type Log struct {
 	syncedPosition  int64
 	syncedOffset    int64
 	stateLock       sync.RWMutex
+	configLock      sync.Mutex
 	expirerStop     chan struct{}
+	compactorStop   chan struct{}
+	compactLock     sync.Mutex
+	rollbackHold    int64
 	subscribers     []chan Stat
 	subscribersLock sync.Mutex
 	writeLock       sync.Mutex
@@ -104,6 +114,11 @@ type Log struct {
 
 func Create(path string, config Config, options Options) (l *Log, err error) {
 
+	_, known := compressionCodes[config.Compression]
+	if !known {
+		return nil, ErrUnknownCompression
+	}
+
 	err = os.Mkdir(path, os.FileMode(dirPerm))
 	if err != nil {
 		if os.IsExist(err) {
@@ -181,6 +196,40 @@ func Delete(path string) (err error) {
 	return nil
 }
 
+// Rename moves a closed log to newPath, which must not exist.
+func Rename(path string, newPath string) (err error) {
+
+	_, err = os.Stat(newPath)
+	if err == nil {
+		return ErrExist
+	}
+
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.Rename(path, newPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotExist
+		}
+
+		return err
+	}
+
+	err = syncDirectory(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	err = syncDirectory(filepath.Dir(newPath))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func Truncate(path string) (err error) {
 
 	names, err := listSegments(path)
@@ -195,9 +244,71 @@ func Truncate(path string) (err error) {
 		}
 	}
 
+	err = deleteProducerStore(path)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// Rollback removes the records following position from a closed log. Records
+// to remove must not be compacted or compressed yet.
+func Rollback(path string, position int64) (err error) {
+
+	descriptors, err := listSegmentDescriptors(path)
+	if err != nil {
+		return err
+	}
+
+	if len(descriptors) == 0 {
+		return ErrCorrupt
+	}
+
+	// Delete segments starting after position, always keeping the first
+	// one.
+	last := len(descriptors) - 1
+	for last > 0 && descriptors[last].basePosition >= position {
+
+		err = deleteSegment(path, descriptors[last].segmentName)
+		if err != nil {
+			return err
+		}
+
+		last--
+	}
+
+	err = truncateSegment(path, descriptors[last], position)
+	if err != nil {
+		return err
+	}
+
+	err = syncDirectory(path)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// HoldRollback keeps compaction and compression from rewriting the segments
+// holding records from position on, so that the log can still be rolled back
+// to position.
+func (l *Log) HoldRollback(position int64) {
+
+	l.stateLock.Lock()
+	l.rollbackHold = position
+	l.stateLock.Unlock()
+}
+
+// ReleaseRollback releases the hold set by HoldRollback.
+func (l *Log) ReleaseRollback() {
+
+	l.stateLock.Lock()
+	l.rollbackHold = -1
+	l.stateLock.Unlock()
+}
+
 func Scan(path string) (err error) {
 
 	configPathname := filepath.Join(path, configFilename)
@@ -222,9 +333,29 @@ func Scan(path string) (err error) {
 	position := segmentDescriptors[0].basePosition
 	offset := segmentDescriptors[0].basePosition
 
-	for _, descriptor := range segmentDescriptors {
+	for i, descriptor := range segmentDescriptors {
+
+		// Check segments are contiguous. Records at the end of a
+		// compacted segment may have been removed, and positions may
+		// have been skipped by a writer.
+		if i > 0 && descriptor.basePosition > position && descriptor.baseOffset == offset {
+			position = descriptor.basePosition
+		}
+
+		if i > 0 && segmentDescriptors[i-1].generation > 0 {
+
+			if descriptor.basePosition < position {
+				return ErrCorrupt
+			}
+
+			if descriptor.baseOffset < offset {
+				return ErrCorrupt
+			}
+
+			position = descriptor.basePosition
+			offset = descriptor.baseOffset
+		}
 
-		// Check segments are contiguous.
 		if descriptor.basePosition != position {
 			return ErrCorrupt
 		}
@@ -233,32 +364,17 @@ func Scan(path string) (err error) {
 			return ErrCorrupt
 		}
 
-		// Scan segment index for errors.
+		// Scan segment index for errors, and rebuild it from the
+		// records file when missing or damaged.
 		pathname := filepath.Join(path, descriptor.segmentName)
-		indexFilename := pathname + indexSuffix
 
-		indexFile, err := os.OpenFile(indexFilename, os.O_RDONLY, os.FileMode(0))
-		if err != nil {
-			if os.IsNotExist(err) {
-				return ErrCorrupt
-			}
-
-			return err
+		err = checkIndex(pathname + indexSuffix)
+		if isDamagedIndex(err) {
+			err = rebuildIndex(path, descriptor, *config)
 		}
 
-		indexBufferedReader := recio.NewBufferedReader(indexFile, scanBufferSize, recio.ModeAuto)
-		indexAtomicReader := recio.NewAtomicReader(indexBufferedReader)
-
-		entry := &indexEntry{}
-		for {
-			_, err := indexAtomicReader.Read(entry)
-			if err == io.EOF {
-				break
-			}
-
-			if err != nil {
-				return err
-			}
+		if err != nil {
+			return err
 		}
 
 		// Scan segment for errors.
@@ -269,7 +385,7 @@ func Scan(path string) (err error) {
 
 		record := &Record{}
 		for {
-			n, err := segmentReader.Read(record)
+			_, err := segmentReader.Read(record)
 			if err == io.EOF {
 				break
 			}
@@ -287,10 +403,9 @@ func Scan(path string) (err error) {
 			if err != nil {
 				return err
 			}
-
-			offset += int64(n)
-			position ++
 		}
+
+		position, offset = segmentReader.Tell()
 	}
 
 	return nil
@@ -375,6 +490,9 @@ func newLog(path string, config Config, options Options) (l *Log, err error) {
 		syncedOffset:    0,
 		stateLock:       sync.RWMutex{},
 		expirerStop:     make(chan struct{}),
+		compactorStop:   make(chan struct{}),
+		compactLock:     sync.Mutex{},
+		rollbackHold:    -1,
 		subscribers:     []chan Stat{},
 		subscribersLock: sync.Mutex{},
 		writeLock:       sync.Mutex{},
@@ -390,17 +508,28 @@ func newLog(path string, config Config, options Options) (l *Log, err error) {
 		return nil, err
 	}
 
+	// Release the lock when the log fails to open, so that it can be
+	// opened again once repaired.
+	err = deleteObsoleteSegments(path)
+	if err != nil {
+		l.releaseFileLock()
+		return nil, err
+	}
+
 	err = l.updateSegmentList()
 	if err != nil {
+		l.releaseFileLock()
 		return nil, err
 	}
 
 	err = l.initialize()
 	if err != nil {
+		l.releaseFileLock()
 		return nil, err
 	}
 
 	go l.expirer()
+	go l.compactor()
 
 	return l, nil
 }
@@ -418,6 +547,7 @@ func (l *Log) Close() (err error) {
 	}
 
 	l.expirerStop <- struct{}{}
+	l.compactorStop <- struct{}{}
 
 	err = l.releaseFileLock()
 	if err != nil {
@@ -445,6 +575,66 @@ func (l *Log) Stat() (stat Stat) {
 	return stat
 }
 
+// Config returns the current config of the log.
+func (l *Log) Config() (config Config) {
+
+	l.stateLock.RLock()
+	defer l.stateLock.RUnlock()
+
+	return l.config
+}
+
+// SetConfig replaces the config of an open log. Retention settings are
+// enforced right away, and rolling settings apply to the current segment at
+// the next flush. MaxRecordSize and IndexAfterSize can't be changed since
+// existing segments depend on them.
+func (l *Log) SetConfig(config Config) (err error) {
+
+	l.configLock.Lock()
+	defer l.configLock.Unlock()
+
+	current := l.Config()
+
+	if config.MaxRecordSize != current.MaxRecordSize || config.IndexAfterSize != current.IndexAfterSize {
+		return ErrImmutable
+	}
+
+	// Replace the config file atomically so that a crash leaves either
+	// the previous or the new config.
+	pathname := filepath.Join(l.path, configFilename)
+
+	err = config.dump(pathname + tmpSuffix)
+	if err != nil {
+		return err
+	}
+
+	err = syncFile(pathname + tmpSuffix)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(pathname+tmpSuffix, pathname)
+	if err != nil {
+		return err
+	}
+
+	err = syncDirectory(l.path)
+	if err != nil {
+		return err
+	}
+
+	l.stateLock.Lock()
+	l.config = config
+	l.stateLock.Unlock()
+
+	err = l.enforceRetention()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (l *Log) NewWriter(bufferSize int, ioMode recio.IOMode) (lw *LogWriter, err error) {
 
 	lw, err = newLogWriter(l, bufferSize, ioMode)
@@ -467,11 +657,20 @@ func (l *Log) NewReader(bufferSize int, follow bool, ioMode recio.IOMode) (lr *L
 
 func (l *Log) Backup(w io.Writer) (err error) {
 
+	// Open producer sequences before checkpointing the log state, so that
+	// they never account for records missing from the backup.
+	producersPathname := filepath.Join(l.path, producersFilename)
+
+	producersFile, err := os.Open(producersPathname)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
 	// Checkpoint current log state.
 	stat := l.Stat()
 
 	// Build a list of index and records file handles.
-	names, err := listSegments(l.path)
+	descriptors, err := listSegmentDescriptors(l.path)
 	if err != nil {
 		return err
 	}
@@ -479,9 +678,9 @@ func (l *Log) Backup(w io.Writer) (err error) {
 	var recordsFiles []*os.File
 	var indexFiles []*os.File
 
-	for _, name := range names {
+	for _, desc := range descriptors {
 
-		pathname := filepath.Join(l.path, name)
+		pathname := filepath.Join(l.path, desc.segmentName)
 
 		f, err := os.Open(pathname + recordsSuffix)
 		if err != nil {
@@ -536,6 +735,35 @@ func (l *Log) Backup(w io.Writer) (err error) {
 		return err
 	}
 
+	// Add the producers file to the archive.
+	if producersFile != nil {
+
+		fi, err := producersFile.Stat()
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(fi, fi.Name())
+		if err != nil {
+			return err
+		}
+
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(tw, producersFile)
+		if err != nil {
+			return err
+		}
+
+		err = producersFile.Close()
+		if err != nil {
+			return err
+		}
+	}
+
 	// Save the last records file to process it separately.
 	lastRecordsFile := recordsFiles[len(recordsFiles)-1]
 
@@ -716,6 +944,159 @@ func (l *Log) Backup(w io.Writer) (err error) {
 	return nil
 }
 
+// Copy creates a copy of the log at path, up to its flushed records. Closed
+// segments are never modified in place and are hard linked when possible,
+// the last segment is copied.
+func (l *Log) Copy(path string) (err error) {
+
+	err = os.Mkdir(path, os.FileMode(dirPerm))
+	if err != nil {
+		if os.IsExist(err) {
+			return ErrExist
+		}
+
+		return err
+	}
+
+	err = syncDirectory(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	config := l.Config()
+
+	configPathname := filepath.Join(path, configFilename)
+
+	err = config.dump(configPathname)
+	if err != nil {
+		return err
+	}
+
+	err = syncFile(configPathname)
+	if err != nil {
+		return err
+	}
+
+	// Link producer sequences before the segments, so that they never
+	// account for records missing from the copy.
+	err = linkProducerStore(l.path, path)
+	if err != nil {
+		return err
+	}
+
+	last, endOffset, recordsFile, indexFile, err := l.linkClosedSegments(path)
+	if err != nil {
+		return err
+	}
+	defer recordsFile.Close()
+	defer indexFile.Close()
+
+	pathname := filepath.Join(path, last.segmentName)
+
+	size := endOffset - last.baseOffset
+	if size < 0 {
+		size = 0
+	}
+
+	err = copyFile(recordsFile, pathname+recordsSuffix, size)
+	if err != nil {
+		return err
+	}
+
+	// Find the last index entry matching the copied records and copy the
+	// index file up to this point.
+	indexBufferedReader := recio.NewBufferedReader(indexFile, 1<<20, recio.ModeAuto)
+	indexAtomicReader := recio.NewAtomicReader(indexBufferedReader)
+
+	ie := indexEntry{}
+
+	offset := int64(0)
+	for {
+		n, err := indexAtomicReader.Read(&ie)
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if ie.offset > endOffset {
+			break
+		}
+
+		offset += int64(n)
+	}
+
+	_, err = indexFile.Seek(0, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+
+	err = copyFile(indexFile, pathname+indexSuffix, offset)
+	if err != nil {
+		return err
+	}
+
+	err = syncDirectory(path)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// linkClosedSegments links all segments but the last one to path, and opens
+// the files of the last segment along with the offset it should be copied up
+// to. The state lock is held so that retention and compaction can't delete
+// segments in the meantime.
+func (l *Log) linkClosedSegments(path string) (last segmentDescriptor, endOffset int64, recordsFile *os.File, indexFile *os.File, err error) {
+
+	l.stateLock.RLock()
+	defer l.stateLock.RUnlock()
+
+	closed := l.segmentList[:len(l.segmentList)-1]
+
+	for _, desc := range closed {
+
+		pathname := filepath.Join(l.path, desc.segmentName)
+		newPathname := filepath.Join(path, desc.segmentName)
+
+		err = linkFile(pathname+recordsSuffix, newPathname+recordsSuffix)
+		if err != nil {
+			return last, 0, nil, nil, err
+		}
+
+		err = linkFile(pathname+indexSuffix, newPathname+indexSuffix)
+		if err != nil {
+			return last, 0, nil, nil, err
+		}
+	}
+
+	// Closed segments hold all their flushed records, so the last segment
+	// is copied up to the flushed offset too.
+	last = l.segmentList[len(l.segmentList)-1]
+	endOffset = l.flushedOffset
+
+	// Keep the last segment files open, it can be deleted by retention
+	// once rolled.
+	pathname := filepath.Join(l.path, last.segmentName)
+
+	recordsFile, err = os.Open(pathname + recordsSuffix)
+	if err != nil {
+		return last, 0, nil, nil, err
+	}
+
+	indexFile, err = os.Open(pathname + indexSuffix)
+	if err != nil {
+		recordsFile.Close()
+		return last, 0, nil, nil, err
+	}
+
+	return last, endOffset, recordsFile, indexFile, nil
+}
+
 func (l *Log) Subscribe(subscriber chan Stat) {
 
 	l.subscribersLock.Lock()
@@ -945,13 +1326,15 @@ func (l *Log) deleteSegments(breakCondition breakCondition) (err error) {
 
 func (l *Log) enforceMaxAge() (err error) {
 
-	if l.config.LogMaxAge == -1 {
+	config := l.Config()
+
+	if config.LogMaxAge == -1 {
 		return nil
 	}
 
 	timestamp := now.Unix()
 
-	expiredTimestamp := timestamp - l.config.LogMaxAge
+	expiredTimestamp := timestamp - config.LogMaxAge
 
 	err = l.deleteSegments(func(desc segmentDescriptor) bool {
 		return desc.baseTimestamp >= expiredTimestamp
@@ -964,6 +1347,96 @@ func (l *Log) enforceMaxAge() (err error) {
 	return nil
 }
 
+// enforceRetention deletes the segments exceeding the log max age, count and
+// size, based on the flushed position and offset.
+func (l *Log) enforceRetention() (err error) {
+
+	err = l.enforceMaxAge()
+	if err != nil {
+		return err
+	}
+
+	l.stateLock.RLock()
+	config := l.config
+	flushedPosition := l.flushedPosition
+	flushedOffset := l.flushedOffset
+	l.stateLock.RUnlock()
+
+	if config.LogMaxCount != -1 {
+		expiredPosition := flushedPosition - config.LogMaxCount
+
+		err = l.deleteSegments(func(desc segmentDescriptor) bool {
+			return desc.basePosition >= expiredPosition
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.LogMaxSize != -1 {
+		expiredOffset := flushedOffset - config.LogMaxSize
+
+		err = l.deleteSegments(func(desc segmentDescriptor) bool {
+			return desc.baseOffset >= expiredOffset
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TruncateBefore deletes the segments holding only records before position,
+// moving the start of the log forward. Like retention, it only deletes whole
+// segments and always keeps the last one.
+func (l *Log) TruncateBefore(position int64) (err error) {
+
+	return l.truncateBefore(func(desc segmentDescriptor) bool {
+		return desc.basePosition <= position
+	})
+}
+
+// TruncateBeforeTimestamp deletes the segments holding only records written
+// before a Unix timestamp in seconds. Write times are used, not record
+// timestamps.
+func (l *Log) TruncateBeforeTimestamp(timestamp int64) (err error) {
+
+	// Records of a segment are all written before the next segment is
+	// created.
+	return l.truncateBefore(func(desc segmentDescriptor) bool {
+		return desc.baseTimestamp < timestamp
+	})
+}
+
+// truncateBefore deletes the segments preceding the last one matching
+// condition.
+func (l *Log) truncateBefore(condition func(segmentDescriptor) bool) (err error) {
+
+	l.stateLock.RLock()
+	first := l.segmentList[0]
+	for _, desc := range l.segmentList {
+		if !condition(desc) {
+			break
+		}
+
+		first = desc
+	}
+	l.stateLock.RUnlock()
+
+	err = l.deleteSegments(func(desc segmentDescriptor) bool {
+		return desc.basePosition >= first.basePosition
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (l *Log) expirer() {
 
 	ticker := time.NewTicker(expireInterval)
@@ -11,12 +11,18 @@ package log
 
 import (
 	"io"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gitlab.com/dataptive/styx/recio"
 )
 
+// ReadHandler is called with the count and size of records read since it was
+// last called.
+type ReadHandler func(count int64, size int64)
+
 type LogReader struct {
 	log           *Log
 	bufferSize    int
@@ -35,6 +41,11 @@ type LogReader struct {
 	closeLock     sync.Mutex
 	deadline      <-chan time.Time
 	deadlineTimer *time.Timer
+	readCount     int64
+	readSize      int64
+	readHandler   ReadHandler
+	readPosition  int64
+	readOffset    int64
 }
 
 func newLogReader(l *Log, bufferSize int, follow bool, ioMode recio.IOMode) (lr *LogReader, err error) {
@@ -58,6 +69,11 @@ func newLogReader(l *Log, bufferSize int, follow bool, ioMode recio.IOMode) (lr
 		closed:        false,
 		closeLock:     sync.Mutex{},
 		deadlineTimer: deadlineTimer,
+		readCount:     0,
+		readSize:      0,
+		readHandler:   nil,
+		readPosition:  0,
+		readOffset:    0,
 	}
 
 	err = lr.openFirstSegment()
@@ -95,6 +111,8 @@ func (lr *LogReader) Close() (err error) {
 
 	close(lr.notifyChan)
 
+	lr.reportRead()
+
 	lr.deadlineTimer.Stop()
 
 	err = lr.closeCurrentSegment()
@@ -110,6 +128,16 @@ func (lr *LogReader) Tell() (position int64, offset int64) {
 	return lr.position, lr.offset
 }
 
+// Progress returns the position and offset of the next record to be read. It
+// is safe to call while another goroutine reads from the reader.
+func (lr *LogReader) Progress() (position int64, offset int64) {
+
+	position = atomic.LoadInt64(&lr.readPosition)
+	offset = atomic.LoadInt64(&lr.readOffset)
+
+	return position, offset
+}
+
 func (lr *LogReader) Read(r *Record) (n int, err error) {
 
 	if lr.closed {
@@ -133,17 +161,27 @@ Retry:
 	}
 
 	if lr.mustNext {
+		previous := lr.segmentReader.name
+
 		err = lr.closeCurrentSegment()
 		if err != nil {
 			return 0, err
 		}
 
-		err = lr.openNextSegment()
+		err = lr.openNextSegment(previous)
 		if err != nil {
 			return 0, err
 		}
 
 		lr.mustNext = false
+
+		// Skipping the gap at the end of a compacted segment may have
+		// brought us to the end of the log.
+		if lr.position == lr.endPosition {
+			lr.mustWait = true
+
+			goto Retry
+		}
 	}
 
 	if lr.mustFill {
@@ -178,8 +216,13 @@ Retry:
 		return n, err
 	}
 
-	lr.position += 1
-	lr.offset += int64(n)
+	// Counters are updated atomically since readers may be closed from
+	// another goroutine.
+	atomic.AddInt64(&lr.readCount, 1)
+	atomic.AddInt64(&lr.readSize, int64(n))
+
+	lr.position, lr.offset = lr.segmentReader.Tell()
+	lr.updateProgress()
 
 	if lr.position == lr.endPosition {
 		lr.mustWait = true
@@ -190,6 +233,8 @@ Retry:
 
 func (lr *LogReader) Fill() (err error) {
 
+	lr.reportRead()
+
 Retry:
 	if lr.mustWait && lr.follow {
 
@@ -228,6 +273,27 @@ Retry:
 	return nil
 }
 
+func (lr *LogReader) HandleRead(h ReadHandler) {
+
+	lr.readHandler = h
+}
+
+func (lr *LogReader) updateProgress() {
+
+	atomic.StoreInt64(&lr.readPosition, lr.position)
+	atomic.StoreInt64(&lr.readOffset, lr.offset)
+}
+
+func (lr *LogReader) reportRead() {
+
+	count := atomic.SwapInt64(&lr.readCount, 0)
+	size := atomic.SwapInt64(&lr.readSize, 0)
+
+	if lr.readHandler != nil && count > 0 {
+		lr.readHandler(count, size)
+	}
+}
+
 func (lr *LogReader) Seek(position int64, whence Whence) (err error) {
 
 	lr.closeLock.Lock()
@@ -252,6 +318,14 @@ func (lr *LogReader) Seek(position int64, whence Whence) (err error) {
 		reference = lr.position
 	case SeekEnd:
 		reference = lr.endPosition
+	case SeekTimestamp:
+		// Position is a timestamp, resolve the first record written
+		// at or after it.
+		reference, err = lr.searchTimestamp(position)
+		if err != nil {
+			return err
+		}
+		position = 0
 	}
 
 	absolute := reference + position
@@ -326,6 +400,7 @@ func (lr *LogReader) seekPosition(position int64) (err error) {
 	lr.segmentReader = segmentReader
 	lr.position = position
 	lr.offset = offset
+	lr.updateProgress()
 
 	if lr.position == lr.endPosition {
 		lr.mustWait = true
@@ -334,6 +409,61 @@ func (lr *LogReader) seekPosition(position int64) (err error) {
 	return nil
 }
 
+func (lr *LogReader) searchTimestamp(timestamp int64) (position int64, err error) {
+
+	lr.log.stateLock.Lock()
+	defer lr.log.stateLock.Unlock()
+
+	segmentList := lr.log.segmentList
+
+	// Find the last segment created before timestamp, since records
+	// written at or after timestamp can't be found in previous segments.
+	pos := sort.Search(len(segmentList), func(i int) bool {
+		return segmentList[i].baseTimestamp >= timestamp
+	})
+
+	if pos > 0 {
+		pos -= 1
+	}
+
+	current := segmentList[pos]
+
+	segmentReader, err := newSegmentReader(lr.log.path, current.segmentName, lr.log.config, indexSeekBufferSize)
+	if err != nil {
+		return 0, err
+	}
+	defer segmentReader.Close()
+
+	position, err = segmentReader.SearchTimestamp(timestamp)
+
+	if err == ErrOutOfRange {
+
+		// All records of the segment were written before timestamp,
+		// the first matching record is the next segment's first.
+		position = lr.endPosition
+
+		if pos+1 < len(segmentList) {
+			position = segmentList[pos+1].basePosition
+		}
+
+		err = nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if position < lr.startPosition {
+		position = lr.startPosition
+	}
+
+	if position > lr.endPosition {
+		position = lr.endPosition
+	}
+
+	return position, nil
+}
+
 func (lr *LogReader) updateBoundaries() {
 
 	lr.log.stateLock.Lock()
@@ -358,11 +488,12 @@ func (lr *LogReader) openFirstSegment() (err error) {
 	lr.segmentReader = segmentReader
 	lr.position = first.basePosition
 	lr.offset = first.baseOffset
+	lr.updateProgress()
 
 	return nil
 }
 
-func (lr *LogReader) openNextSegment() (err error) {
+func (lr *LogReader) openNextSegment(previous string) (err error) {
 
 	lr.log.stateLock.Lock()
 	defer lr.log.stateLock.Unlock()
@@ -373,34 +504,65 @@ func (lr *LogReader) openNextSegment() (err error) {
 		return ErrLagging
 	}
 
+	// Move on to the segment following the one we've just read, or look
+	// it up by position if it was replaced by compaction in the meantime.
 	pos := -1
 	for i, desc := range lr.log.segmentList {
-
-		if desc.basePosition == lr.position {
-			pos = i
-			continue
+		if desc.segmentName == previous {
+			pos = i + 1
+			break
 		}
+	}
 
-		if desc.basePosition > lr.position {
-			if pos == -1 {
-				return ErrCorrupt
+	if pos == -1 {
+		for i, desc := range lr.log.segmentList {
+
+			if desc.basePosition == lr.position {
+				pos = i
+				continue
+			}
+
+			if desc.basePosition > lr.position {
+				if pos == -1 {
+					pos = i
+				}
+				break
 			}
-			break
 		}
 	}
 
-	if pos == -1 {
+	if pos == -1 || pos == len(lr.log.segmentList) {
 		return io.EOF
 	}
 
 	next := lr.log.segmentList[pos]
 
+	if next.basePosition < lr.position {
+		return ErrCorrupt
+	}
+
+	// Records at the end of a compacted segment may have been removed, in
+	// which case the next segment starts after the current position.
+	// Positions skipped by a writer leave a gap in positions only.
+	if next.basePosition > lr.position {
+		if pos == 0 {
+			return ErrCorrupt
+		}
+
+		if lr.log.segmentList[pos-1].generation == 0 && next.baseOffset != lr.offset {
+			return ErrCorrupt
+		}
+	}
+
 	segmentReader, err := newSegmentReader(lr.log.path, next.segmentName, lr.log.config, lr.bufferSize)
 	if err != nil {
 		return err
 	}
 
 	lr.segmentReader = segmentReader
+	lr.position = next.basePosition
+	lr.offset = next.baseOffset
+	lr.updateProgress()
 
 	return nil
 }
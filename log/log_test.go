@@ -10,8 +10,10 @@
 package log
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -65,7 +67,7 @@ func benchmarkLog_Writer(b *testing.B, payloadSize int) {
 	}
 
 	payload := make([]byte, payloadSize)
-	r := Record(payload)
+	r := Record{Payload: payload}
 
 	b.StartTimer()
 
@@ -139,7 +141,7 @@ func benchmarkLog_Reader(b *testing.B, payloadSize int) {
 	}
 
 	payload := make([]byte, payloadSize)
-	r := Record(payload)
+	r := Record{Payload: payload}
 
 	for i := 0; i < b.N; i++ {
 		_, err := lw.Write(&r)
@@ -298,7 +300,7 @@ func testLog_Write(t *testing.T, path string, config Config, options Options, re
 	}
 
 	payload := make([]byte, payloadSize)
-	r := Record(payload)
+	r := Record{Payload: payload}
 
 	for i := 0; i < recordCount; i++ {
 		_, err := lw.Write(&r)
@@ -461,6 +463,92 @@ func TestLog_AgeRetention(t *testing.T) {
 	}
 }
 
+// Tests that truncating a log only deletes whole segments before the
+// boundary, and always keeps the last one.
+func TestLog_TruncateBefore(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	config.SegmentMaxCount = 10
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	records := []Record{}
+	for i := 0; i < 25; i++ {
+		records = append(records, Record{Payload: []byte("some record payload")})
+	}
+
+	testCompaction_Write(t, l, records)
+
+	// No segment was written before the epoch.
+	err = l.TruncateBeforeTimestamp(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat := l.Stat()
+	if stat.StartPosition != 0 || stat.EndPosition != 25 {
+		t.Fatalf("log should still start at 0 but got %+v", stat)
+	}
+
+	lr, err := l.NewReader(1<<20, false, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	record := Record{}
+
+	for {
+		_, err = lr.Read(&record)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count++
+	}
+
+	err = lr.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 25 {
+		t.Fatalf("log should still hold 25 records but got %d", count)
+	}
+
+	err = l.TruncateBefore(15)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat = l.Stat()
+	if stat.StartPosition != 10 || stat.EndPosition != 25 {
+		t.Fatalf("log should start at 10 but got %+v", stat)
+	}
+
+	err = l.TruncateBefore(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat = l.Stat()
+	if stat.StartPosition != 20 || stat.EndPosition != 25 {
+		t.Fatalf("log should start at 20 but got %+v", stat)
+	}
+}
+
 // Tests that records written are correctly notified to log.
 func TestLog_SyncAuto(t *testing.T) {
 
@@ -485,7 +573,7 @@ func TestLog_SyncAuto(t *testing.T) {
 	}
 
 	payload := make([]byte, payloadSize)
-	r := Record(payload)
+	r := Record{Payload: payload}
 
 	for i := 0; i < recordCount; i++ {
 		_, err := lw.Write(&r)
@@ -586,7 +674,7 @@ func TestLog_UnblockFollow(t *testing.T) {
 	go func() {
 		time.Sleep(50 * time.Millisecond)
 
-		r := Record([]byte("test"))
+		r := Record{Payload: []byte("test")}
 
 		_, err := lw.Write(&r)
 		if err != nil {
@@ -1108,6 +1196,433 @@ func TestLog_Restore(t *testing.T) {
 	}
 }
 
+// Tests that copy creates a log ending at the position of the copied log, and
+// that closed segments are linked.
+func TestLog_Copy(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+	copyName := filepath.Join(path, "copy")
+
+	config := DefaultConfig
+	config.SegmentMaxCount = 100
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lw.Close()
+
+	for i := 0; i < 250; i++ {
+		_, err := lw.Write(&Record{Payload: []byte("copy test record")})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Copy(copyName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Copy(copyName)
+	if err != ErrExist {
+		t.Fatalf("copy should have failed with err = %s but got err = %v", ErrExist, err)
+	}
+
+	// Records written after the copy don't show up in the copied log.
+	_, err = lw.Write(&Record{Payload: []byte("copy test record")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptors, err := listSegmentDescriptors(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, desc := range descriptors[:len(descriptors)-1] {
+
+		fi, err := os.Stat(filepath.Join(name, desc.segmentName) + recordsSuffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		copyFi, err := os.Stat(filepath.Join(copyName, desc.segmentName) + recordsSuffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !os.SameFile(fi, copyFi) {
+			t.Fatalf("segment %s should have been linked", desc.segmentName)
+		}
+	}
+
+	copied, err := Open(copyName, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+
+	stat := copied.Stat()
+
+	if stat.EndPosition != 250 {
+		t.Fatalf("copied log should end at position 250 but ends at %d", stat.EndPosition)
+	}
+
+	lr, err := copied.NewReader(1<<20, false, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+
+	var r Record
+	count := int64(0)
+
+	for {
+		_, err := lr.Read(&r)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count++
+	}
+
+	if count != stat.EndPosition {
+		t.Fatalf("should have read %d records from the copied log but got %d", stat.EndPosition, count)
+	}
+}
+
+// Tests that rolling back a copied log leaves the segments it shares with the
+// original log untouched.
+func TestLog_CopyRollback(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+	copyName := filepath.Join(path, "copy")
+
+	config := DefaultConfig
+	config.SegmentMaxCount = 100
+	options := DefaultOptions
+
+	testLog_Write(t, name, config, options, 250, 100, 0)
+
+	l, err := Open(name, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Copy(copyName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Rollback(copyName, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		position int64
+	}{
+		{name: name, position: 250},
+		{name: copyName, position: 50},
+	} {
+		err = Scan(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l, err := Open(test.name, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stat := l.Stat()
+
+		err = l.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stat.EndPosition != test.position {
+			t.Fatalf("log %s should end at position %d but ends at %d", test.name, test.position, stat.EndPosition)
+		}
+	}
+}
+
+// Tests that rename moves a log, and fails when the new name is taken.
+func TestLog_Rename(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+	newName := filepath.Join(path, "renamed")
+	otherName := filepath.Join(path, "other")
+
+	config := DefaultConfig
+	options := DefaultOptions
+
+	testLog_Write(t, name, config, options, 100, 100, 0)
+	testLog_Write(t, otherName, config, options, 100, 100, 0)
+
+	err := Rename(name, otherName)
+	if err != ErrExist {
+		t.Fatalf("rename should have failed with err = %s but got err = %v", ErrExist, err)
+	}
+
+	err = Rename(name, newName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(name)
+	if !os.IsNotExist(err) {
+		t.Fatalf("log %s should not exist anymore", name)
+	}
+
+	l, err := Open(newName, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	stat := l.Stat()
+
+	if stat.EndPosition != 100 {
+		t.Fatalf("renamed log should end at position 100 but ends at %d", stat.EndPosition)
+	}
+}
+
+// Tests that rolling back drops trailing segments and records, and that
+// writes resume at the rollback position.
+func TestLog_Rollback(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	config.SegmentMaxCount = 100
+	config.IndexAfterSize = 1000
+
+	options := DefaultOptions
+
+	testLog_Write(t, name, config, options, 250, 100, 0)
+
+	err := Rollback(name, 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Open(name, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat := l.Stat()
+	if stat.EndPosition != 150 {
+		t.Fatalf("rolled back log should end at position 150 but ends at %d", stat.EndPosition)
+	}
+
+	lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Record{Payload: []byte("rollback test record")}
+
+	_, err = lw.Write(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lr, err := l.NewReader(1<<20, false, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lr.Seek(150, SeekOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := Record{}
+
+	_, err = lr.Read(&read)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(read.Payload) != "rollback test record" {
+		t.Fatalf("expected record written after rollback, got %q", read.Payload)
+	}
+
+	err = lr.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rolling back to the start empties the log.
+	err = Rollback(name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(name, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	stat = l.Stat()
+	if stat.EndPosition != 0 {
+		t.Fatalf("rolled back log should end at position 0 but ends at %d", stat.EndPosition)
+	}
+}
+
+// Tests that writers can skip positions, and that readers and scans accept
+// the resulting gap.
+func TestLog_Skip(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	options := DefaultOptions
+
+	l, err := Create(name, DefaultConfig, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Record{Payload: []byte("skip test record")}
+
+	for i := 0; i < 5; i++ {
+		_, err = lw.Write(&r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = lw.Skip(2)
+	if err != ErrOutOfRange {
+		t.Fatalf("skipping backwards should fail with %v, got %v", ErrOutOfRange, err)
+	}
+
+	err = lw.Skip(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = lw.Write(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Scan(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(name, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	stat := l.Stat()
+	if stat.EndPosition != 21 {
+		t.Fatalf("log should end at position 21 but ends at %d", stat.EndPosition)
+	}
+
+	lr, err := l.NewReader(1<<20, false, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+
+	positions := []int64{}
+
+	for {
+		_, err = lr.Read(&r)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		position, _ := lr.Tell()
+		positions = append(positions, position-1)
+	}
+
+	expected := []int64{0, 1, 2, 3, 4, 20}
+	if !reflect.DeepEqual(positions, expected) {
+		t.Fatalf("expected records at positions %v, got %v", expected, positions)
+	}
+}
+
 // Tests that readers and writers get closed on log close.
 func TestLog_ForceClose(t *testing.T) {
 
@@ -1147,3 +1662,96 @@ func TestLog_ForceClose(t *testing.T) {
 		t.Fatalf("fill should have failed with error ErrClosed but got err = %s", err)
 	}
 }
+
+// Tests that readers seek to the first record written at or after a timestamp.
+func TestLog_SeekTimestamp(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := make([]byte, 256)
+	r := Record{Payload: payload}
+
+	for i := 0; i < 4; i++ {
+		_, err := lw.Write(&r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * time.Second)
+
+	timestamp := now.Unix()
+
+	for i := 0; i < 4; i++ {
+		_, err := lw.Write(&r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lr, err := l.NewReader(1<<20, false, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+
+	err = lr.Seek(timestamp, SeekTimestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	position, _ := lr.Tell()
+	if position != 4 {
+		t.Fatalf("should have position 4 but got %d", position)
+	}
+
+	err = lr.Seek(timestamp+60, SeekTimestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	position, _ = lr.Tell()
+	if position != 8 {
+		t.Fatalf("should have position 8 but got %d", position)
+	}
+
+	err = lr.Seek(0, SeekTimestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	position, _ = lr.Tell()
+	if position != 0 {
+		t.Fatalf("should have position 0 but got %d", position)
+	}
+}
@@ -11,6 +11,7 @@ package log
 
 import (
 	"sync"
+	"time"
 
 	"gitlab.com/dataptive/styx/recio"
 )
@@ -22,6 +23,10 @@ type SyncProgress struct {
 
 type SyncHandler func(syncProgress SyncProgress)
 
+// SyncDurationHandler is called with the time spent syncing segments and
+// directory to disk.
+type SyncDurationHandler func(duration time.Duration)
+
 type LogWriter struct {
 	log             *Log
 	bufferSize      int
@@ -36,6 +41,7 @@ type LogWriter struct {
 	closed          bool
 	closeLock       sync.Mutex
 	syncHandler     SyncHandler
+	durationHandler SyncDurationHandler
 	initialPosition int64
 }
 
@@ -55,6 +61,7 @@ func newLogWriter(l *Log, bufferSize int, ioMode recio.IOMode) (lw *LogWriter, e
 		closed:          false,
 		closeLock:       sync.Mutex{},
 		syncHandler:     nil,
+		durationHandler: nil,
 		initialPosition: 0,
 	}
 
@@ -110,6 +117,11 @@ func (lw *LogWriter) HandleSync(h SyncHandler) {
 	lw.syncHandler = h
 }
 
+func (lw *LogWriter) HandleSyncDuration(h SyncDurationHandler) {
+
+	lw.durationHandler = h
+}
+
 func (lw *LogWriter) Tell() (position int64, offset int64) {
 
 	return lw.position, lw.offset
@@ -214,6 +226,45 @@ func (lw *LogWriter) Flush() (err error) {
 	return nil
 }
 
+// Skip moves the write position forward, leaving a gap for records removed
+// from the log being copied, typically by compaction. Positions are skipped
+// by starting a new segment at position, with the current offset.
+func (lw *LogWriter) Skip(position int64) (err error) {
+
+	if lw.closed {
+		return ErrClosed
+	}
+
+	if position < lw.position {
+		return ErrOutOfRange
+	}
+
+	if position == lw.position {
+		return nil
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = lw.closeCurrentSegment()
+	if err != nil {
+		return err
+	}
+
+	lw.position = position
+
+	err = lw.createNewSegment()
+	if err != nil {
+		return err
+	}
+
+	lw.mustRoll = false
+
+	return lw.Flush()
+}
+
 func (lw *LogWriter) getDirtyCount() (count int) {
 
 	lw.log.stateLock.Lock()
@@ -253,6 +304,8 @@ func (lw *LogWriter) sync() (err error) {
 
 	lw.log.stateLock.Unlock()
 
+	start := time.Now()
+
 	if directoryDirty {
 		err = syncDirectory(lw.log.path)
 		if err != nil {
@@ -267,6 +320,10 @@ func (lw *LogWriter) sync() (err error) {
 		}
 	}
 
+	if lw.durationHandler != nil && (directoryDirty || len(dirtySegments) > 0) {
+		lw.durationHandler(time.Since(start))
+	}
+
 	lw.updateSyncProgress(flushedPosition, flushedOffset)
 
 	return nil
@@ -277,6 +334,10 @@ func (lw *LogWriter) updateFlushProgress(position int64, offset int64) {
 	lw.log.stateLock.Lock()
 	defer lw.log.stateLock.Unlock()
 
+	// Pick up config changes, so that rolling settings apply to the
+	// current segment.
+	lw.segmentWriter.config = lw.log.config
+
 	current := len(lw.log.segmentList) - 1
 	lw.log.segmentList[current].segmentDirty = true
 
@@ -315,11 +376,13 @@ func (lw *LogWriter) updateSyncProgress(position int64, offset int64) {
 
 func (lw *LogWriter) enforceMaxCount() (err error) {
 
-	if lw.log.config.LogMaxCount == -1 {
+	config := lw.log.Config()
+
+	if config.LogMaxCount == -1 {
 		return nil
 	}
 
-	expiredPosition := lw.position - lw.log.config.LogMaxCount
+	expiredPosition := lw.position - config.LogMaxCount
 
 	err = lw.log.deleteSegments(func(desc segmentDescriptor) bool {
 		return desc.basePosition >= expiredPosition
@@ -334,11 +397,13 @@ func (lw *LogWriter) enforceMaxCount() (err error) {
 
 func (lw *LogWriter) enforceMaxSize() (err error) {
 
-	if lw.log.config.LogMaxSize == -1 {
+	config := lw.log.Config()
+
+	if config.LogMaxSize == -1 {
 		return nil
 	}
 
-	expiredOffset := lw.offset - lw.log.config.LogMaxSize
+	expiredOffset := lw.offset - config.LogMaxSize
 
 	err = lw.log.deleteSegments(func(desc segmentDescriptor) bool {
 		return desc.baseOffset >= expiredOffset
@@ -0,0 +1,438 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/dataptive/styx/recio"
+)
+
+const (
+	// Records holding these headers are only written once per producer
+	// id and sequence.
+	ProducerIDHeader       = "Styx-Producer-Id"
+	ProducerSequenceHeader = "Styx-Producer-Sequence"
+
+	producersFilename = "producers"
+	producersVersion  = 0
+
+	// Producer sequences are checkpointed at most this often, records
+	// written since the last checkpoint are scanned when opening the log.
+	producersCheckpointInterval = 10 * time.Second
+)
+
+var (
+	ErrInvalidSequence  = errors.New("log: invalid producer sequence")
+	ErrCorruptProducers = errors.New("log: corrupt producers file")
+	ErrDuplicate        = errors.New("log: duplicate producer sequence")
+)
+
+// SetProducer sets the producer id and sequence headers of the record,
+// replacing existing ones. Headers are copied so that the record can share
+// them with other records.
+func (r *Record) SetProducer(id string, sequence int64) {
+
+	headers := make([]Header, 0, len(r.Headers)+2)
+
+	for _, h := range r.Headers {
+		if isProducerHeader(h.Name) {
+			continue
+		}
+
+		headers = append(headers, h)
+	}
+
+	headers = append(headers,
+		Header{Name: ProducerIDHeader, Value: id},
+		Header{Name: ProducerSequenceHeader, Value: strconv.FormatInt(sequence, 10)},
+	)
+
+	r.Headers = headers
+}
+
+// Producer returns the producer id and sequence of the record, id is empty
+// when the record has no producer.
+func (r *Record) Producer() (id string, sequence int64, err error) {
+
+	rawSequence := ""
+
+	for _, h := range r.Headers {
+		if strings.EqualFold(h.Name, ProducerIDHeader) {
+			id = h.Value
+		}
+
+		if strings.EqualFold(h.Name, ProducerSequenceHeader) {
+			rawSequence = h.Value
+		}
+	}
+
+	if id == "" {
+		return "", 0, nil
+	}
+
+	sequence, err = strconv.ParseInt(rawSequence, 10, 64)
+	if err != nil {
+		return "", 0, ErrInvalidSequence
+	}
+
+	return id, sequence, nil
+}
+
+func isProducerHeader(name string) (ok bool) {
+
+	return strings.EqualFold(name, ProducerIDHeader) || strings.EqualFold(name, ProducerSequenceHeader)
+}
+
+// producerStore holds the last sequence written by each producer of a log.
+//
+// Sequences are checkpointed in the producers file of the log directory,
+// along with the position up to which they account for written records. The
+// file is structured as follows.
+//
+//	+-------------------+--------------------+-----------------+- - - - - - - - - -+----------------+
+//	|  version (int32)  |  position (int64)  |  count (int32)  |     producers     |  CRC (uint32)  |
+//	+-------------------+--------------------+-----------------+- - - - - - - - - -+----------------+
+//
+// Each producer is encoded as an id length (int32), the id and its last
+// sequence (int64). The CRC32-C covers the whole file. The file is replaced
+// atomically on each checkpoint.
+//
+// Checkpoints only hold synced records, and records written after the
+// checkpointed position are scanned when opening the store, so that
+// sequences always match the records persisted in the log.
+type producerStore struct {
+	pathname       string
+	sequences      map[string]int64
+	position       int64 // Position up to which sequences account for written records.
+	dirty          bool
+	checkpointTime time.Time
+	lock           sync.Mutex
+}
+
+func openProducerStore(l *Log) (ps *producerStore, err error) {
+
+	ps = &producerStore{
+		pathname:  filepath.Join(l.path, producersFilename),
+		sequences: make(map[string]int64),
+	}
+
+	stat := l.Stat()
+
+	err = ps.load()
+	if os.IsNotExist(err) {
+
+		// Logs written before producers existed hold no sequences.
+		ps.position = stat.EndPosition
+
+		err = ps.checkpoint()
+		if err != nil {
+			return nil, err
+		}
+
+		return ps, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Records accounted for by the checkpoint were rolled back, rebuild
+	// sequences from the whole log.
+	if ps.position > stat.EndPosition {
+		ps.sequences = make(map[string]int64)
+		ps.position = 0
+	}
+
+	err = ps.scan(l)
+	if err != nil {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+func deleteProducerStore(path string) (err error) {
+
+	pathname := filepath.Join(path, producersFilename)
+
+	err = os.Remove(pathname)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// linkProducerStore links the producers file of a log to another log
+// directory. Since the file is replaced on each checkpoint, the link keeps
+// the current checkpoint.
+func linkProducerStore(path string, newPath string) (err error) {
+
+	pathname := filepath.Join(path, producersFilename)
+	newPathname := filepath.Join(newPath, producersFilename)
+
+	err = linkFile(pathname, newPathname)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// scan updates sequences with the records written after the checkpointed
+// position.
+func (ps *producerStore) scan(l *Log) (err error) {
+
+	lr, err := l.NewReader(scanBufferSize, false, recio.ModeAuto)
+	if err != nil {
+		return err
+	}
+	defer lr.Close()
+
+	stat := l.Stat()
+
+	// Records preceding the log start are gone, along with their
+	// producers.
+	position := ps.position
+	if position < stat.StartPosition {
+		position = stat.StartPosition
+	}
+
+	if position >= stat.EndPosition {
+		return nil
+	}
+
+	err = lr.Seek(position, SeekOrigin)
+	if err != nil {
+		return err
+	}
+
+	record := &Record{}
+
+	for {
+		_, err := lr.Read(record)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		id, sequence, err := record.Producer()
+		if err != nil {
+			continue
+		}
+
+		if id == "" {
+			continue
+		}
+
+		position, _ := lr.Tell()
+
+		ps.update(id, sequence, position)
+	}
+
+	return nil
+}
+
+// accepts reports whether a record with the producer id and sequence was not
+// written yet.
+func (ps *producerStore) accepts(id string, sequence int64) (accepted bool) {
+
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	last, exists := ps.sequences[id]
+
+	return !exists || sequence > last
+}
+
+func (ps *producerStore) update(id string, sequence int64, position int64) {
+
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	last, exists := ps.sequences[id]
+	if !exists || sequence > last {
+		ps.sequences[id] = sequence
+	}
+
+	ps.position = position
+	ps.dirty = true
+}
+
+// synced checkpoints sequences once all records holding a producer are
+// synced, at most every producersCheckpointInterval. The checkpointed position
+// moves on with synced records even when no producer wrote, so that opening
+// the log only scans records written since the last checkpoint.
+func (ps *producerStore) synced(position int64) (err error) {
+
+	ps.lock.Lock()
+
+	mustCheckpoint := (ps.dirty || position > ps.position) && position >= ps.position && time.Since(ps.checkpointTime) >= producersCheckpointInterval
+
+	if mustCheckpoint {
+		ps.position = position
+	}
+
+	ps.lock.Unlock()
+
+	if !mustCheckpoint {
+		return nil
+	}
+
+	err = ps.checkpoint()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ps *producerStore) checkpoint() (err error) {
+
+	ps.lock.Lock()
+
+	size := 4 + 8 + 4
+	for id := range ps.sequences {
+		size += 4 + len(id) + 8
+	}
+	size += 4
+
+	buffer := make([]byte, size)
+	n := 0
+
+	binary.BigEndian.PutUint32(buffer[n:n+4], uint32(producersVersion))
+	n += 4
+
+	binary.BigEndian.PutUint64(buffer[n:n+8], uint64(ps.position))
+	n += 8
+
+	binary.BigEndian.PutUint32(buffer[n:n+4], uint32(len(ps.sequences)))
+	n += 4
+
+	for id, sequence := range ps.sequences {
+
+		binary.BigEndian.PutUint32(buffer[n:n+4], uint32(len(id)))
+		n += 4
+
+		n += copy(buffer[n:], id)
+
+		binary.BigEndian.PutUint64(buffer[n:n+8], uint64(sequence))
+		n += 8
+	}
+
+	ps.dirty = false
+	ps.checkpointTime = time.Now()
+
+	ps.lock.Unlock()
+
+	crc := crc32.Checksum(buffer[:n], castagnoliTable)
+
+	binary.BigEndian.PutUint32(buffer[n:n+4], crc)
+
+	// Write to a temporary file first and rename it, so that a crash
+	// never leaves a partially written producers file behind.
+	tmpPathname := ps.pathname + tmpSuffix
+
+	err = ioutil.WriteFile(tmpPathname, buffer, os.FileMode(filePerm))
+	if err != nil {
+		return err
+	}
+
+	err = syncFile(tmpPathname)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPathname, ps.pathname)
+	if err != nil {
+		return err
+	}
+
+	err = syncDirectory(filepath.Dir(ps.pathname))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ps *producerStore) load() (err error) {
+
+	buffer, err := ioutil.ReadFile(ps.pathname)
+	if err != nil {
+		return err
+	}
+
+	if len(buffer) < 4+8+4+4 {
+		return ErrCorruptProducers
+	}
+
+	end := len(buffer) - 4
+
+	crc := binary.BigEndian.Uint32(buffer[end:])
+	if crc != crc32.Checksum(buffer[:end], castagnoliTable) {
+		return ErrCorruptProducers
+	}
+
+	n := 0
+
+	version := int(binary.BigEndian.Uint32(buffer[n:]))
+	n += 4
+
+	if version != producersVersion {
+		return ErrCorruptProducers
+	}
+
+	ps.position = int64(binary.BigEndian.Uint64(buffer[n:]))
+	n += 8
+
+	count := int(binary.BigEndian.Uint32(buffer[n:]))
+	n += 4
+
+	for i := 0; i < count; i++ {
+
+		if n+4 > end {
+			return ErrCorruptProducers
+		}
+
+		length := int(binary.BigEndian.Uint32(buffer[n:]))
+		n += 4
+
+		if length < 0 || n+length+8 > end {
+			return ErrCorruptProducers
+		}
+
+		id := string(buffer[n : n+length])
+		n += length
+
+		sequence := int64(binary.BigEndian.Uint64(buffer[n:]))
+		n += 8
+
+		ps.sequences[id] = sequence
+	}
+
+	ps.checkpointTime = time.Now()
+
+	return nil
+}
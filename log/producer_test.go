@@ -0,0 +1,203 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/dataptive/styx/recio"
+)
+
+// Tests that producer headers are replaced and parsed back.
+func TestRecord_Producer(t *testing.T) {
+
+	headers := []Header{{Name: "Other", Value: "value"}}
+
+	r := &Record{Headers: headers}
+	r.SetProducer("producer", 1)
+	r.SetProducer("producer", 2)
+
+	id, sequence, err := r.Producer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != "producer" || sequence != 2 {
+		t.Fatalf("got producer %q sequence %d", id, sequence)
+	}
+
+	if len(r.Headers) != 3 || len(headers) != 1 {
+		t.Fatalf("got %d headers, shared headers %d", len(r.Headers), len(headers))
+	}
+
+	r = &Record{Headers: []Header{{Name: ProducerIDHeader, Value: "producer"}}}
+
+	_, _, err = r.Producer()
+	if err != ErrInvalidSequence {
+		t.Fatalf("expected %v, got %v", ErrInvalidSequence, err)
+	}
+}
+
+// Tests that the fanin drops records already written by a producer, before
+// and after reopening the log.
+func TestFanin_Producer(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeSequences := func(l *Log, first int64, last int64, checkpoint bool) (position int64) {
+
+		lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := NewFanin(lw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for sequence := first; sequence <= last; sequence++ {
+
+			r := &Record{Payload: []byte("producer test record")}
+			r.SetProducer("producer", sequence)
+
+			_, err := f.Write(r)
+			if err != nil && err != ErrDuplicate {
+				t.Fatal(err)
+			}
+		}
+
+		// Records without producer are always written.
+		_, err = f.Write(&Record{Payload: []byte("producer test record")})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if checkpoint {
+			err = f.producers.checkpoint()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		position, _ = lw.Tell()
+
+		err = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = lw.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return position
+	}
+
+	position := writeSequences(l, 0, 9, false)
+	if position != 11 {
+		t.Fatalf("expected position 11, got %d", position)
+	}
+
+	position = writeSequences(l, 5, 14, true)
+	if position != 17 {
+		t.Fatalf("expected position 17, got %d", position)
+	}
+
+	position = writeSequences(l, 10, 19, false)
+	if position != 23 {
+		t.Fatalf("expected position 23, got %d", position)
+	}
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sequences are restored from the checkpoint, and records written
+	// after it are scanned.
+	l, err = Open(name, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	position = writeSequences(l, 0, 20, false)
+	if position != 25 {
+		t.Fatalf("expected position 25, got %d", position)
+	}
+}
+
+// Tests that the checkpointed position moves on with synced records written
+// without producer, and only once records holding a producer are synced.
+func TestProducerStore_Synced(t *testing.T) {
+
+	ps := &producerStore{
+		pathname:  filepath.Join(t.TempDir(), producersFilename),
+		sequences: make(map[string]int64),
+	}
+
+	err := ps.synced(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ps.position != 100 {
+		t.Fatalf("checkpoint should be at position 100 but is at %d", ps.position)
+	}
+
+	ps.update("producer", 1, 150)
+	ps.checkpointTime = time.Time{}
+
+	err = ps.synced(120)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ps.position != 150 {
+		t.Fatalf("checkpoint should wait for position 150 but is at %d", ps.position)
+	}
+
+	err = ps.synced(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := &producerStore{
+		pathname:  ps.pathname,
+		sequences: make(map[string]int64),
+	}
+
+	err = loaded.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.position != 200 || loaded.sequences["producer"] != 1 {
+		t.Fatalf("checkpoint should be at position 200 with sequence 1 but is at %d with %v", loaded.position, loaded.sequences)
+	}
+}
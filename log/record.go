@@ -25,9 +25,10 @@ const (
 // encoded size to stay below the MaxRecordSize hard limit.
 var ErrRecordTooLarge = errors.New("log: record too large")
 
-// Record implements the encoding and decoding of length-prefixed byte buffers.
+// Record implements the encoding and decoding of length-prefixed records,
+// made of a payload and optional metadata.
 //
-// Encoded log records are structured as follows.
+// Records without metadata are encoded as follows.
 //
 //	+----------------+--------------------------------+- - - - - - - - +
 //	|  size (int32)  |      payload (size bytes)      |  CRC (uint32)  |
@@ -37,7 +38,27 @@ var ErrRecordTooLarge = errors.New("log: record too large")
 // variable length byte buffer. A CRC32-C of the whole record is implicitly
 // appended and checked when using recio atomic readers / writers.
 //
-// Payload length is limited to 2,147,483,639 bytes (~2GB, max int32 - 8).
+// Records holding a key, a timestamp or headers are encoded as follows.
+//
+//	+----------------+---------------------+------------------+- - - - - -+
+//	|  size (int32)  |  timestamp (int64)  |  key len (int32) |    key    |
+//	+----------------+---------------------+------------------+- - - - - -+
+//	+----------------------+- - - - - - - - - -+- - - - - - - -+- - - - - - - - +
+//	|  header count (int32) |      headers      |    payload    |  CRC (uint32)  |
+//	+----------------------+- - - - - - - - - -+- - - - - - - -+- - - - - - - - +
+//
+// The most significant bit of size is set to flag this encoding, the
+// remaining bits encode the length of everything following the size field.
+// Each header is encoded as a name length (int32), the name, a value length
+// (int32) and the value. The payload extends up to the end of the record.
+//
+// Records written by earlier versions never have the most significant bit of
+// their size set, since negative sizes are invalid. Both encodings can thus
+// coexist in the same segment, and records without metadata stay readable by
+// older clients.
+//
+// Payload length is limited to 2,147,483,639 bytes (~2GB, max int32 - 8),
+// including metadata.
 //
 // DESIGN: Limiting record size to the maximum signed 32 bits integer ensures
 // that records will not overflow Encode and Decode return values, and that
@@ -52,22 +73,65 @@ var ErrRecordTooLarge = errors.New("log: record too large")
 // and data integrity reasons, since no CRC for this kind of block length is
 // as well understood and hardware accelerated as CRC32-C.
 //
-type Record []byte
+// Decoded payloads and keys point to the decoding buffer and are only valid
+// until the next read.
+//
+// Record used to be a byte slice holding the payload. Code converting between
+// records and byte slices should use the Payload field instead, for example
+// Record{Payload: p} and r.Payload.
+//
+// Timestamp is set by writers, typically to an event time, and is unrelated
+// to the write times in seconds used by SeekTimestamp and retention.
+type Record struct {
+	Key       []byte   // Optional record key.
+	Timestamp int64    // Optional Unix timestamp in nanoseconds, 0 if unset.
+	Headers   []Header // Optional record headers.
+	Payload   []byte
+}
+
+// Header is a record header, a string name and value pair.
+type Header struct {
+	Name  string
+	Value string
+}
+
+const recordMetadataFlag = 1 << 31
+
+// HasMetadata reports whether the record holds a key, a timestamp or
+// headers.
+func (r *Record) HasMetadata() (ok bool) {
+
+	return len(r.Key) != 0 || r.Timestamp != 0 || len(r.Headers) != 0
+}
 
 // Size returns the record's encoded byte size.
 func (r *Record) Size() (size int) {
 
-	return 4 + len(*r)
+	return 4 + r.bodySize()
+}
+
+func (r *Record) bodySize() (size int) {
+
+	if !r.HasMetadata() {
+		return len(r.Payload)
+	}
+
+	size = 8 + 4 + len(r.Key) + 4
+	for _, h := range r.Headers {
+		size += 4 + len(h.Name) + 4 + len(h.Value)
+	}
+	size += len(r.Payload)
+
+	return size
 }
 
 // Encode implements the recio.Encoder interface. It encodes the record to the
 // provided byte slice. It fails with err == ErrRecordTooLarge if the payload
-// exeeds MaxPayloadSize. This method is used by Write to encode records and
-// should not be called directly.
+// and metadata exeed MaxPayloadSize. This method is used by Write to encode
+// records and should not be called directly.
 func (r *Record) Encode(p []byte) (n int, err error) {
 
-	payload := []byte(*r)
-	size := len(payload)
+	size := r.bodySize()
 
 	if size > MaxPayloadSize {
 		return 0, ErrRecordTooLarge
@@ -78,19 +142,50 @@ func (r *Record) Encode(p []byte) (n int, err error) {
 		return 0, recio.ErrShortBuffer
 	}
 
-	binary.BigEndian.PutUint32(p, uint32(size))
+	if !r.HasMetadata() {
+		binary.BigEndian.PutUint32(p, uint32(size))
+		n += 4
+
+		n += copy(p[n:], r.Payload)
+
+		return n, nil
+	}
+
+	binary.BigEndian.PutUint32(p, uint32(size)|recordMetadataFlag)
 	n += 4
 
-	n += copy(p[n:], payload)
+	binary.BigEndian.PutUint64(p[n:], uint64(r.Timestamp))
+	n += 8
+
+	binary.BigEndian.PutUint32(p[n:], uint32(len(r.Key)))
+	n += 4
+
+	n += copy(p[n:], r.Key)
+
+	binary.BigEndian.PutUint32(p[n:], uint32(len(r.Headers)))
+	n += 4
+
+	for _, h := range r.Headers {
+		binary.BigEndian.PutUint32(p[n:], uint32(len(h.Name)))
+		n += 4
+
+		n += copy(p[n:], h.Name)
+
+		binary.BigEndian.PutUint32(p[n:], uint32(len(h.Value)))
+		n += 4
+
+		n += copy(p[n:], h.Value)
+	}
+
+	n += copy(p[n:], r.Payload)
 
 	return n, nil
 }
 
 // Decode implements the recio.Decoder interface. It decodes the record from
-// the provided byte slice. It fails with err == ErrRecordTooLarge if the
-// payload exeeds MaxPayloadSize. If the records is not decodeable, it returns
-// err == ErrInvalidRecord. This method is used by Read to decode records and
-// should not be called directly.
+// the provided byte slice. If the record is not decodeable, or if its size
+// exceeds MaxPayloadSize, it returns err == ErrCorrupt. This method is used
+// by Read to decode records and should not be called directly.
 func (r *Record) Decode(p []byte) (n int, err error) {
 
 	// Check that we can decode the size prefix.
@@ -98,14 +193,12 @@ func (r *Record) Decode(p []byte) (n int, err error) {
 		return 0, recio.ErrShortBuffer
 	}
 
-	size := int(binary.BigEndian.Uint32(p[:4]))
+	flagged := binary.BigEndian.Uint32(p[:4])
 	n += 4
 
-	if size > MaxPayloadSize {
-		return 0, ErrCorrupt
-	}
+	size := int(flagged &^ recordMetadataFlag)
 
-	if size < 0 {
+	if size > MaxPayloadSize {
 		return 0, ErrCorrupt
 	}
 
@@ -114,9 +207,99 @@ func (r *Record) Decode(p []byte) (n int, err error) {
 		return 0, recio.ErrShortBuffer
 	}
 
-	payload := p[n : n+size]
-	*r = []byte(payload)
+	body := p[n : n+size]
 	n += size
 
+	if flagged&recordMetadataFlag == 0 {
+		r.Key = nil
+		r.Timestamp = 0
+		r.Headers = r.Headers[:0]
+		r.Payload = body
+
+		return n, nil
+	}
+
+	err = r.decodeMetadata(body)
+	if err != nil {
+		return 0, err
+	}
+
 	return n, nil
 }
+
+func (r *Record) decodeMetadata(body []byte) (err error) {
+
+	pos := 0
+
+	if pos+8+4 > len(body) {
+		return ErrCorrupt
+	}
+
+	r.Timestamp = int64(binary.BigEndian.Uint64(body[pos:]))
+	pos += 8
+
+	key, pos, err := decodeField(body, pos)
+	if err != nil {
+		return err
+	}
+
+	r.Key = key
+
+	if pos+4 > len(body) {
+		return ErrCorrupt
+	}
+
+	count := int(binary.BigEndian.Uint32(body[pos:]))
+	pos += 4
+
+	// Each header takes at least 8 bytes, which bounds the count of
+	// headers before allocating them.
+	if count < 0 || count > (len(body)-pos)/8 {
+		return ErrCorrupt
+	}
+
+	r.Headers = r.Headers[:0]
+
+	for i := 0; i < count; i++ {
+		var name, value []byte
+
+		name, pos, err = decodeField(body, pos)
+		if err != nil {
+			return err
+		}
+
+		value, pos, err = decodeField(body, pos)
+		if err != nil {
+			return err
+		}
+
+		r.Headers = append(r.Headers, Header{
+			Name:  string(name),
+			Value: string(value),
+		})
+	}
+
+	r.Payload = body[pos:]
+
+	return nil
+}
+
+// decodeField decodes a length-prefixed field at pos in body and returns it
+// along with the position following it.
+func decodeField(body []byte, pos int) (field []byte, next int, err error) {
+
+	if pos+4 > len(body) {
+		return nil, 0, ErrCorrupt
+	}
+
+	size := int(int32(binary.BigEndian.Uint32(body[pos:])))
+	pos += 4
+
+	if size < 0 || size > len(body)-pos {
+		return nil, 0, ErrCorrupt
+	}
+
+	field = body[pos : pos+size]
+
+	return field, pos + size, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gitlab.com/dataptive/styx/recio"
+)
+
+// Tests that records without metadata keep the legacy encoding.
+func TestRecord_EncodeLegacy(t *testing.T) {
+
+	r := Record{Payload: []byte("test")}
+
+	p := make([]byte, r.Size())
+
+	n, err := r.Encode(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{0, 0, 0, 4, 't', 'e', 's', 't'}
+
+	if !bytes.Equal(p[:n], expected) {
+		t.Fatalf("should have encoded %v but got %v", expected, p[:n])
+	}
+}
+
+// Tests that records with metadata decode to the record they were encoded
+// from.
+func TestRecord_EncodeDecode(t *testing.T) {
+
+	r := Record{
+		Key:       []byte("key"),
+		Timestamp: 1234567890,
+		Headers: []Header{
+			{Name: "name1", Value: "value1"},
+			{Name: "name2", Value: ""},
+		},
+		Payload: []byte("payload"),
+	}
+
+	p := make([]byte, r.Size())
+
+	n, err := r.Encode(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != r.Size() {
+		t.Fatalf("should have encoded %d bytes but got %d", r.Size(), n)
+	}
+
+	var d Record
+
+	n, err = d.Decode(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != len(p) {
+		t.Fatalf("should have decoded %d bytes but got %d", len(p), n)
+	}
+
+	if !reflect.DeepEqual(r, d) {
+		t.Fatalf("should have decoded %v but got %v", r, d)
+	}
+
+	_, err = d.Decode(p[:n-1])
+	if err != recio.ErrShortBuffer {
+		t.Fatalf("decode should have failed with error ErrShortBuffer but got err = %s", err)
+	}
+}
+
+// Tests that corrupt metadata is detected.
+func TestRecord_DecodeCorrupt(t *testing.T) {
+
+	r := Record{
+		Key:     []byte("key"),
+		Payload: []byte("payload"),
+	}
+
+	p := make([]byte, r.Size())
+
+	_, err := r.Encode(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Make the key length overflow the record.
+	p[4+8+3] = 0xff
+
+	var d Record
+
+	_, err = d.Decode(p)
+	if err != ErrCorrupt {
+		t.Fatalf("decode should have failed with error ErrCorrupt but got err = %s", err)
+	}
+}
+
+// Tests that records with and without metadata can be read back from the
+// same log.
+func TestRecord_MixedLog(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []Record{
+		{Payload: []byte("legacy")},
+		{Key: []byte("key"), Timestamp: 42, Payload: []byte("metadata")},
+		{Payload: []byte("legacy")},
+	}
+
+	for i := range records {
+		_, err := lw.Write(&records[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lr, err := l.NewReader(1<<20, false, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+
+	for i, expected := range records {
+		var r Record
+
+		_, err := lr.Read(&r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(r.Key) != string(expected.Key) || r.Timestamp != expected.Timestamp || string(r.Payload) != string(expected.Payload) {
+			t.Fatalf("record %d should be %v but got %v", i, expected, r)
+		}
+	}
+}
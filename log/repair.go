@@ -0,0 +1,489 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gitlab.com/dataptive/styx/lockfile"
+	"gitlab.com/dataptive/styx/recio"
+)
+
+var (
+	ErrRepair = errors.New("log: tail can't be repaired")
+)
+
+// TailRepair describes the torn tail of a log, made of the bytes following the
+// last valid record of its last segment, such as a record partially written
+// when the server lost power.
+type TailRepair struct {
+	Position            int64 // Position following the last valid record.
+	Offset              int64 // Offset following the last valid record.
+	TruncatedBytes      int64 // Size of the records file tail to truncate.
+	TruncatedIndexBytes int64 // Size of the index file tail to truncate.
+}
+
+// Needed reports whether the log tail is torn.
+func (tr TailRepair) Needed() (needed bool) {
+
+	return tr.TruncatedBytes > 0 || tr.TruncatedIndexBytes > 0
+}
+
+// CheckTail returns the repair needed by the tail of a closed log, without
+// modifying it.
+func CheckTail(path string) (tr TailRepair, err error) {
+
+	desc, err := lastSegmentDescriptor(path)
+	if err != nil {
+		return tr, err
+	}
+
+	tr, _, err = checkSegmentTail(path, desc)
+	if err != nil {
+		return tr, err
+	}
+
+	return tr, nil
+}
+
+// RepairTail truncates the records and index files of the last segment of a
+// closed log back to its last valid record. Corruption found in previous
+// segments can't be repaired, and is still reported by Scan.
+func RepairTail(path string) (tr TailRepair, err error) {
+
+	desc, err := lastSegmentDescriptor(path)
+	if err != nil {
+		return tr, err
+	}
+
+	tr, indexSize, err := checkSegmentTail(path, desc)
+	if err != nil {
+		return tr, err
+	}
+
+	if !tr.Needed() {
+		return tr, nil
+	}
+
+	pathname := filepath.Join(path, desc.segmentName)
+
+	err = truncateFile(pathname+recordsSuffix, tr.Offset-desc.baseOffset)
+	if err != nil {
+		return tr, err
+	}
+
+	// Missing indexes are rebuilt by Scan.
+	if tr.TruncatedIndexBytes > 0 {
+		err = truncateFile(pathname+indexSuffix, indexSize)
+		if err != nil {
+			return tr, err
+		}
+	}
+
+	return tr, nil
+}
+
+func lastSegmentDescriptor(path string) (desc segmentDescriptor, err error) {
+
+	descriptors, err := listSegmentDescriptors(path)
+	if err != nil {
+		return desc, err
+	}
+
+	if len(descriptors) == 0 {
+		return desc, ErrCorrupt
+	}
+
+	return descriptors[len(descriptors)-1], nil
+}
+
+// checkSegmentTail reads a segment up to its first invalid record, and returns
+// the repair it needs along with the size of the valid part of its index. It
+// fails with ErrRepair when valid records follow the invalid one, since the
+// segment is then corrupt rather than torn.
+func checkSegmentTail(path string, desc segmentDescriptor) (tr TailRepair, indexSize int64, err error) {
+
+	// Compacted and compressed segments are written to temporary files
+	// before replacing segments, their tail can't be torn.
+	if desc.generation > 0 {
+		return tr, 0, ErrRepair
+	}
+
+	pathname := filepath.Join(path, desc.segmentName)
+
+	recordsFile, err := os.Open(pathname + recordsSuffix)
+	if err != nil {
+		return tr, 0, err
+	}
+	defer recordsFile.Close()
+
+	compressed, err := isCompressed(recordsFile)
+	if err != nil {
+		return tr, 0, err
+	}
+
+	if compressed {
+		return tr, 0, ErrRepair
+	}
+
+	rbr := recio.NewBufferedReader(recordsFile, recordSeekBufferSize, recio.ModeAuto)
+	recordsReader := recio.NewAtomicReader(rbr)
+
+	position := desc.basePosition
+	offset := desc.baseOffset
+
+	r := Record{}
+	for {
+		n, err := recordsReader.Read(&r)
+		if err == io.EOF || isTornRecord(err) {
+			break
+		}
+
+		if err != nil {
+			return tr, 0, err
+		}
+
+		position += 1
+		offset += int64(n)
+	}
+
+	fi, err := recordsFile.Stat()
+	if err != nil {
+		return tr, 0, err
+	}
+
+	config := Config{}
+
+	err = config.load(filepath.Join(path, configFilename))
+	if err != nil {
+		return tr, 0, err
+	}
+
+	found, err := hasValidRecord(recordsFile, offset-desc.baseOffset+1, fi.Size(), config.MaxRecordSize)
+	if err != nil {
+		return tr, 0, err
+	}
+
+	if found {
+		return tr, 0, ErrRepair
+	}
+
+	tr = TailRepair{
+		Position:            position,
+		Offset:              offset,
+		TruncatedBytes:      fi.Size() - (offset - desc.baseOffset),
+		TruncatedIndexBytes: 0,
+	}
+
+	indexFile, err := os.Open(pathname + indexSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return tr, 0, nil
+		}
+
+		return tr, 0, err
+	}
+	defer indexFile.Close()
+
+	ibr := recio.NewBufferedReader(indexFile, indexSeekBufferSize, recio.ModeAuto)
+	indexReader := recio.NewAtomicReader(ibr)
+
+	// Keep index entries up to the last valid record.
+	ie := indexEntry{}
+	for {
+		n, err := indexReader.Read(&ie)
+		if err == io.EOF || isTornRecord(err) {
+			break
+		}
+
+		if err != nil {
+			return tr, 0, err
+		}
+
+		if ie.position > position || ie.offset > offset {
+			break
+		}
+
+		indexSize += int64(n)
+	}
+
+	ifi, err := indexFile.Stat()
+	if err != nil {
+		return tr, 0, err
+	}
+
+	tr.TruncatedIndexBytes = ifi.Size() - indexSize
+
+	return tr, indexSize, nil
+}
+
+// RebuildIndexes rebuilds the index of the segments of a closed log from their
+// records file, when missing or damaged, or for all segments when all is set.
+// It returns the count of rebuilt indexes.
+func RebuildIndexes(path string, all bool) (count int, err error) {
+
+	config := Config{}
+
+	err = config.load(filepath.Join(path, configFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, ErrNotExist
+		}
+
+		return 0, err
+	}
+
+	// Make sure the log isn't opened meanwhile. Orphaned lock files are
+	// left in place, so that the log is still scanned when opened.
+	lockFile := lockfile.New(filepath.Join(path, lockFilename), os.FileMode(filePerm))
+
+	err = lockFile.Acquire()
+	if err == lockfile.ErrLocked {
+		return 0, ErrLocked
+	}
+
+	if err != nil && err != lockfile.ErrOrphaned {
+		return 0, err
+	}
+
+	if err == nil {
+		defer lockFile.Clear()
+	}
+
+	descriptors, err := listSegmentDescriptors(path)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, desc := range descriptors {
+
+		if !all {
+			err = checkIndex(filepath.Join(path, desc.segmentName) + indexSuffix)
+			if err == nil {
+				continue
+			}
+
+			if !isDamagedIndex(err) {
+				return count, err
+			}
+		}
+
+		err = rebuildIndex(path, desc, config)
+		if err != nil {
+			return count, err
+		}
+
+		count += 1
+	}
+
+	return count, nil
+}
+
+// checkIndex reads all entries of an index file.
+func checkIndex(pathname string) (err error) {
+
+	indexFile, err := os.Open(pathname)
+	if err != nil {
+		return err
+	}
+	defer indexFile.Close()
+
+	indexBufferedReader := recio.NewBufferedReader(indexFile, scanBufferSize, recio.ModeAuto)
+	indexAtomicReader := recio.NewAtomicReader(indexBufferedReader)
+
+	entry := indexEntry{}
+	for {
+		_, err = indexAtomicReader.Read(&entry)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isDamagedIndex(err error) (damaged bool) {
+
+	return os.IsNotExist(err) || isTornRecord(err)
+}
+
+// rebuildIndex writes the index of a segment again from its records file,
+// indexing records every IndexAfterSize bytes. Write times are only known from
+// the index, so rebuilt entries have no timestamp. Compacted segments may have
+// position gaps which are also only known from their index, and can't be
+// rebuilt.
+func rebuildIndex(path string, desc segmentDescriptor, config Config) (err error) {
+
+	if desc.generation > 0 {
+		return ErrCorrupt
+	}
+
+	pathname := filepath.Join(path, desc.segmentName)
+	indexFilename := pathname + indexSuffix
+
+	recordsReader, err := openRecordsFile(pathname + recordsSuffix)
+	if err != nil {
+		return err
+	}
+	defer recordsReader.Close()
+
+	// Write the index to a temporary file first, so that a failed rebuild
+	// leaves the previous one in place.
+	indexFile, err := os.OpenFile(indexFilename+tmpSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(filePerm))
+	if err != nil {
+		return err
+	}
+	defer indexFile.Close()
+
+	bufferSize := scanBufferSize
+	if config.MaxRecordSize > bufferSize {
+		bufferSize = config.MaxRecordSize
+	}
+
+	recordsBufferedReader := recio.NewBufferedReader(recordsReader, bufferSize, recio.ModeAuto)
+	recordsAtomicReader := recio.NewAtomicReader(recordsBufferedReader)
+
+	indexBufferedWriter := recio.NewBufferedWriter(indexFile, bufferSize, recio.ModeAuto)
+	indexAtomicWriter := recio.NewAtomicWriter(indexBufferedWriter)
+
+	position := desc.basePosition
+	offset := desc.baseOffset
+
+	lastIndexEntry := indexEntry{
+		position:  position,
+		offset:    offset,
+		timestamp: unknownTimestamp,
+	}
+
+	first := true
+
+	r := Record{}
+	for {
+		n, err := recordsAtomicReader.Read(&r)
+
+		// Torn tails are left to RepairTail, the index only covers
+		// valid records.
+		if err == io.EOF || isTornRecord(err) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if first || offset-lastIndexEntry.offset >= config.IndexAfterSize {
+
+			lastIndexEntry = indexEntry{
+				position:  position,
+				offset:    offset,
+				timestamp: unknownTimestamp,
+			}
+
+			_, err = indexAtomicWriter.Write(&lastIndexEntry)
+			if err != nil {
+				return err
+			}
+
+			first = false
+		}
+
+		position += 1
+		offset += int64(n)
+	}
+
+	err = indexBufferedWriter.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = indexFile.Sync()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(indexFilename+tmpSuffix, indexFilename)
+	if err != nil {
+		return err
+	}
+
+	err = syncDirectory(path)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// hasValidRecord reports whether a valid record of at most maxRecordSize bytes
+// starts at any offset of a records file between from and end.
+func hasValidRecord(f *os.File, from int64, end int64, maxRecordSize int) (found bool, err error) {
+
+	// Records are looked for in the first half of the buffer only, so
+	// that valid records always fit in it.
+	half := int64(maxRecordSize)
+	buffer := make([]byte, 2*half)
+
+	for base := from; base < end; base += half {
+
+		n, err := f.ReadAt(buffer, base)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+
+		for i := 0; i < n && int64(i) < half; i++ {
+
+			if isValidRecord(buffer[i:n], maxRecordSize) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// isValidRecord reports whether p starts with a valid record of at most
+// maxRecordSize bytes.
+func isValidRecord(p []byte, maxRecordSize int) (valid bool) {
+
+	if len(p) < 4 {
+		return false
+	}
+
+	size := int64(binary.BigEndian.Uint32(p) &^ recordMetadataFlag)
+
+	if 4+size+4 > int64(maxRecordSize) || 4+size+4 > int64(len(p)) {
+		return false
+	}
+
+	r := Record{}
+
+	n, err := r.Decode(p)
+	if err != nil || int64(n) != 4+size {
+		return false
+	}
+
+	crc := binary.BigEndian.Uint32(p[n:])
+
+	return crc == crc32.Checksum(p[:n], castagnoliTable)
+}
+
+func isTornRecord(err error) (torn bool) {
+
+	return err == io.ErrUnexpectedEOF || err == recio.ErrCorrupt || err == recio.ErrTooLarge || err == ErrCorrupt
+}
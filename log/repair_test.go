@@ -0,0 +1,321 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/dataptive/styx/recio"
+)
+
+// Tests that a record partially written at the end of the last segment is
+// truncated, and that the log can be opened again.
+func TestRepair_Tail(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	config.IndexAfterSize = 64
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []Record{}
+	for i := 0; i < 20; i++ {
+		records = append(records, Record{Payload: []byte("some record payload")})
+	}
+
+	testCompaction_Write(t, l, records)
+
+	stat := l.Stat()
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := CheckTail(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.Needed() || tr.Position != stat.EndPosition || tr.Offset != stat.EndOffset {
+		t.Fatalf("clean log should not need repair, got %+v", tr)
+	}
+
+	// Append a torn record and a torn index entry to the last segment.
+	desc, err := lastSegmentDescriptor(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pathname := filepath.Join(name, desc.segmentName)
+
+	buffer := make([]byte, 1024)
+	n, err := records[0].Encode(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRepair_Append(t, pathname+recordsSuffix, buffer[:n/2])
+	testRepair_Append(t, pathname+indexSuffix, []byte{0, 0, 0})
+
+	tr, err = CheckTail(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := TailRepair{
+		Position:            stat.EndPosition,
+		Offset:              stat.EndOffset,
+		TruncatedBytes:      int64(n / 2),
+		TruncatedIndexBytes: 3,
+	}
+
+	if tr != expected {
+		t.Fatalf("check should have returned %+v but got %+v", expected, tr)
+	}
+
+	// Scan rebuilds the torn index, and leaves the torn record.
+	err = Scan(name)
+	if err == nil {
+		t.Fatalf("scan should have failed")
+	}
+
+	expected.TruncatedIndexBytes = 0
+
+	tr, err = RepairTail(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tr != expected {
+		t.Fatalf("repair should have returned %+v but got %+v", expected, tr)
+	}
+
+	err = Scan(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(name, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if l.Stat() != stat {
+		t.Fatalf("repaired log stat should be %+v but got %+v", stat, l.Stat())
+	}
+}
+
+// Tests that a record corrupt in the middle of the last segment, followed by
+// valid records, is not taken for a torn tail.
+func TestRepair_CorruptTail(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []Record{}
+	for i := 0; i < 20; i++ {
+		records = append(records, Record{Payload: []byte("some record payload")})
+	}
+
+	testCompaction_Write(t, l, records)
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desc, err := lastSegmentDescriptor(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pathname := filepath.Join(name, desc.segmentName) + recordsSuffix
+
+	buffer, err := ioutil.ReadFile(pathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a payload byte of the 10th record.
+	buffer[len(buffer)/2] ^= 0xff
+
+	err = ioutil.WriteFile(pathname, buffer, os.FileMode(filePerm))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = CheckTail(name)
+	if err != ErrRepair {
+		t.Fatalf("check should have failed with err = %v but got err = %v", ErrRepair, err)
+	}
+
+	_, err = RepairTail(name)
+	if err != ErrRepair {
+		t.Fatalf("repair should have failed with err = %v but got err = %v", ErrRepair, err)
+	}
+
+	fi, err := os.Stat(pathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Size() != int64(len(buffer)) {
+		t.Fatalf("records file should not have been truncated")
+	}
+}
+
+// Tests that missing and damaged indexes are rebuilt from records files by
+// Scan, and that records stay readable at the same positions.
+func TestRepair_Index(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	config.SegmentMaxCount = 3000
+	config.IndexAfterSize = 1 << 10
+	config.Compression = CompressionGzip
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := int64(10000)
+
+	for i := int64(0); i < count; i++ {
+		_, err := lw.Write(&Record{Payload: testCompression_Payload(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.compress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat := l.Stat()
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptors, err := listSegmentDescriptors(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove the index of a compressed segment, corrupt the next one and
+	// tear the last one.
+	err = os.Remove(filepath.Join(name, descriptors[0].segmentName) + indexSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(name, descriptors[1].segmentName)+indexSuffix, os.O_WRONLY, os.FileMode(filePerm))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.WriteAt([]byte{0xff, 0xff}, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+
+	testRepair_Append(t, filepath.Join(name, descriptors[len(descriptors)-1].segmentName)+indexSuffix, []byte{0, 0, 0})
+
+	err = Scan(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilt, err := RebuildIndexes(name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rebuilt != 0 {
+		t.Fatalf("no index should be left to rebuild but %d were rebuilt", rebuilt)
+	}
+
+	rebuilt, err = RebuildIndexes(name, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rebuilt != len(descriptors) {
+		t.Fatalf("%d indexes should have been rebuilt but got %d", len(descriptors), rebuilt)
+	}
+
+	l, err = Open(name, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if l.Stat() != stat {
+		t.Fatalf("rebuilt log stat should be %+v but got %+v", stat, l.Stat())
+	}
+
+	testCompression_Check(t, l, count)
+}
+
+func testRepair_Append(t *testing.T, pathname string, p []byte) {
+
+	f, err := os.OpenFile(pathname, os.O_WRONLY|os.O_APPEND, os.FileMode(filePerm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
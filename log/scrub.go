@@ -0,0 +1,165 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// Delays shorter than this are accumulated rather than slept.
+	minThrottleDelay = 10 * time.Millisecond
+)
+
+var (
+	errScrubStopped = errors.New("log: scrub stopped")
+)
+
+// Scrub reads the closed segments of the log and checks the CRCs of their
+// records and index entries, so that corruption is noticed before consumers
+// read it. Reads are throttled to rate bytes per second, unless rate is not
+// positive, and stop early when stop is closed. Scrub returns the reports of
+// damaged segments, and the count of bytes read.
+func (l *Log) Scrub(rate int64, stop <-chan struct{}) (damaged []SegmentReport, size int64, err error) {
+
+	// Closed segments are never written again, and are only replaced or
+	// deleted by compaction and retention.
+	l.stateLock.RLock()
+	closed := make([]segmentDescriptor, len(l.segmentList)-1)
+	copy(closed, l.segmentList)
+	l.stateLock.RUnlock()
+
+	config := l.Config()
+
+	t := &throttle{
+		rate:  rate,
+		start: time.Now(),
+		size:  0,
+		stop:  stop,
+	}
+
+	damaged = []SegmentReport{}
+
+	for _, desc := range closed {
+
+		sr, err := l.scrubSegment(desc, config, t)
+		if err == errScrubStopped {
+			break
+		}
+
+		if err != nil {
+			return damaged, t.size, err
+		}
+
+		if !sr.OK() {
+			damaged = append(damaged, sr)
+		}
+	}
+
+	return damaged, t.size, nil
+}
+
+// scrubSegment checks a closed segment. Segments deleted in the meantime are
+// reported valid.
+func (l *Log) scrubSegment(desc segmentDescriptor, config Config, t *throttle) (sr SegmentReport, err error) {
+
+	pathname := filepath.Join(l.path, desc.segmentName)
+
+	sr = SegmentReport{
+		Segment:     desc.segmentName,
+		EndPosition: desc.basePosition,
+		EndOffset:   desc.baseOffset,
+	}
+
+	recordsReader, err := openRecordsFile(pathname + recordsSuffix)
+	if os.IsNotExist(err) {
+		return sr, nil
+	}
+
+	if err != nil {
+		sr.RecordsError = err
+		return sr, nil
+	}
+	defer recordsReader.Close()
+
+	entries, err := readIndex(pathname + indexSuffix)
+	if os.IsNotExist(err) {
+		_, statErr := os.Stat(pathname + recordsSuffix)
+		if os.IsNotExist(statErr) {
+			return sr, nil
+		}
+	}
+
+	sr.IndexEntries = int64(len(entries))
+	sr.IndexError = err
+
+	sr.EndPosition, sr.EndOffset, err = readRecords(recordsReader, desc, config, entries, func(position int64, offset int64, timestamp int64, r *Record) (err error) {
+
+		sr.Records += 1
+
+		if !t.wait(int64(r.Size())) {
+			return errScrubStopped
+		}
+
+		return nil
+	})
+
+	if err == errScrubStopped {
+		return sr, err
+	}
+
+	sr.RecordsError = err
+
+	return sr, nil
+}
+
+// throttle paces reads to rate bytes per second.
+type throttle struct {
+	rate  int64
+	start time.Time
+	size  int64
+	stop  <-chan struct{}
+}
+
+// wait accounts for n bytes read, and sleeps until the bytes read so far fit
+// the rate. It returns false when stopped.
+func (t *throttle) wait(n int64) (ok bool) {
+
+	t.size += n
+
+	delay := time.Duration(0)
+
+	if t.rate > 0 {
+		expected := time.Duration(float64(t.size) / float64(t.rate) * float64(time.Second))
+		delay = expected - time.Since(t.start)
+	}
+
+	if delay < minThrottleDelay {
+		select {
+		case <-t.stop:
+			return false
+		default:
+			return true
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-t.stop:
+		return false
+	}
+}
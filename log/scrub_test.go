@@ -0,0 +1,89 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that scrubbing reports corrupt closed segments of an opened log.
+func TestScrub_Corrupt(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	config := DefaultConfig
+	config.SegmentMaxCount = 10
+	options := DefaultOptions
+
+	l, err := Create(name, config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	records := []Record{}
+	for i := 0; i < 25; i++ {
+		records = append(records, Record{Payload: []byte("some record payload")})
+	}
+
+	testCompaction_Write(t, l, records)
+
+	damaged, size, err := l.Scrub(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last segment is still written, and isn't scrubbed.
+	segments, err := ListSegments(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(damaged) != 0 || size == 0 || size > segments[2].BaseOffset {
+		t.Fatalf("scrub should have read the 2 closed segments without errors, got %v after %d bytes", damaged, size)
+	}
+
+	f, err := os.OpenFile(filepath.Join(name, segments[1].Name)+recordsSuffix, os.O_WRONLY, os.FileMode(filePerm))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.WriteAt([]byte{0xff}, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+
+	damaged, _, err = l.Scrub(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(damaged) != 1 || damaged[0].Segment != segments[1].Name || damaged[0].RecordsError == nil {
+		t.Fatalf("scrub should have reported the second segment as damaged, got %+v", damaged)
+	}
+
+	// Stopped scrubs return early without reporting damaged segments.
+	stop := make(chan struct{})
+	close(stop)
+
+	damaged, _, err = l.Scrub(0, stop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(damaged) != 0 {
+		t.Fatalf("stopped scrub should not report damaged segments, got %+v", damaged)
+	}
+}
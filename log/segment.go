@@ -12,8 +12,12 @@ package log
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+
+	"gitlab.com/dataptive/styx/recio"
 )
 
 const (
@@ -22,6 +26,11 @@ const (
 	segmentNamePattern = "segment-%020d-%020d-%020d"
 	segmentGlobPattern = "segment-*"
 
+	// Compacted segments names also encode the number of times they were
+	// compacted, so that a compacted segment can be written alongside the
+	// segment it replaces.
+	compactedSegmentNamePattern = segmentNamePattern + "-%010d"
+
 	// Hardcoded buffer sizes for seeks. These provide good performance in
 	// most cases.
 	indexSeekBufferSize  = 1 << 10 // 1KB
@@ -29,6 +38,7 @@ const (
 
 	recordsSuffix = "-records"
 	indexSuffix   = "-index"
+	tmpSuffix     = ".tmp"
 )
 
 var (
@@ -42,6 +52,7 @@ type segmentDescriptor struct {
 	basePosition  int64
 	baseOffset    int64
 	baseTimestamp int64
+	generation    int64
 }
 
 func buildSegmentName(basePosition, baseOffset, baseTimestamp int64) (name string) {
@@ -49,11 +60,25 @@ func buildSegmentName(basePosition, baseOffset, baseTimestamp int64) (name strin
 	return name
 }
 
+func buildCompactedSegmentName(basePosition, baseOffset, baseTimestamp, generation int64) (name string) {
+	name = fmt.Sprintf(compactedSegmentNamePattern, basePosition, baseOffset, baseTimestamp, generation)
+	return name
+}
+
 func parseSegmentName(name string) (basePosition, baseOffset, baseTimestamp int64) {
 	fmt.Sscanf(name, segmentNamePattern, &basePosition, &baseOffset, &baseTimestamp)
 	return basePosition, baseOffset, baseTimestamp
 }
 
+// parseSegmentGeneration returns the number of times a segment was compacted.
+func parseSegmentGeneration(name string) (generation int64) {
+
+	var basePosition, baseOffset, baseTimestamp int64
+
+	fmt.Sscanf(name, compactedSegmentNamePattern, &basePosition, &baseOffset, &baseTimestamp, &generation)
+	return generation
+}
+
 func listSegments(path string) (names []string, err error) {
 
 	pattern := filepath.Join(path, segmentGlobPattern) + recordsSuffix
@@ -72,8 +97,33 @@ func listSegments(path string) (names []string, err error) {
 	return names, nil
 }
 
+// listSegmentDescriptors returns the descriptors of the log segments, ordered
+// by position. When a compacted segment and the segment it replaces are both
+// present, only the latest generation is returned.
 func listSegmentDescriptors(path string) (descriptors []segmentDescriptor, err error) {
 
+	all, err := listAllSegmentDescriptors(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, desc := range all {
+
+		last := len(descriptors) - 1
+
+		if last >= 0 && sameSegment(descriptors[last], desc) {
+			descriptors[last] = desc
+			continue
+		}
+
+		descriptors = append(descriptors, desc)
+	}
+
+	return descriptors, nil
+}
+
+func listAllSegmentDescriptors(path string) (descriptors []segmentDescriptor, err error) {
+
 	names, err := listSegments(path)
 	if err != nil {
 		return nil, err
@@ -86,13 +136,77 @@ func listSegmentDescriptors(path string) (descriptors []segmentDescriptor, err e
 			basePosition:  basePosition,
 			baseOffset:    baseOffset,
 			baseTimestamp: baseTimestamp,
+			generation:    parseSegmentGeneration(name),
 		}
 		descriptors = append(descriptors, desc)
 	}
 
+	// Compacted segments names don't sort after the names of the
+	// segments they replace, so order segments explicitly.
+	sort.Slice(descriptors, func(i, j int) bool {
+		a, b := descriptors[i], descriptors[j]
+
+		if a.basePosition != b.basePosition {
+			return a.basePosition < b.basePosition
+		}
+
+		if a.baseOffset != b.baseOffset {
+			return a.baseOffset < b.baseOffset
+		}
+
+		if a.baseTimestamp != b.baseTimestamp {
+			return a.baseTimestamp < b.baseTimestamp
+		}
+
+		return a.generation < b.generation
+	})
+
 	return descriptors, nil
 }
 
+func sameSegment(a, b segmentDescriptor) (same bool) {
+
+	return a.basePosition == b.basePosition && a.baseOffset == b.baseOffset && a.baseTimestamp == b.baseTimestamp
+}
+
+// deleteObsoleteSegments deletes the segments and temporary files left behind
+// by an interrupted compaction.
+func deleteObsoleteSegments(path string) (err error) {
+
+	all, err := listAllSegmentDescriptors(path)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i+1 < len(all); i++ {
+
+		if !sameSegment(all[i], all[i+1]) {
+			continue
+		}
+
+		err = deleteSegment(path, all[i].segmentName)
+		if err != nil {
+			return err
+		}
+	}
+
+	pattern := filepath.Join(path, segmentGlobPattern) + tmpSuffix
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		err = os.Remove(match)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func syncSegment(path, name string) (err error) {
 
 	pathname := filepath.Join(path, name) + recordsSuffix
@@ -133,3 +247,109 @@ func deleteSegment(path, name string) (err error) {
 
 	return nil
 }
+
+// truncateSegment removes the records following position from a segment,
+// along with their index entries.
+func truncateSegment(path string, desc segmentDescriptor, position int64) (err error) {
+
+	// Compacted segments have gaps, records can't be counted to find
+	// position.
+	if desc.generation > 0 {
+		return ErrRollback
+	}
+
+	pathname := filepath.Join(path, desc.segmentName)
+
+	recordsFile, err := os.OpenFile(pathname+recordsSuffix, os.O_RDONLY, os.FileMode(filePerm))
+	if err != nil {
+		return err
+	}
+	defer recordsFile.Close()
+
+	compressed, err := isCompressed(recordsFile)
+	if err != nil {
+		return err
+	}
+
+	if compressed {
+		return ErrRollback
+	}
+
+	indexFile, err := os.OpenFile(pathname+indexSuffix, os.O_RDONLY, os.FileMode(filePerm))
+	if err != nil {
+		return err
+	}
+	defer indexFile.Close()
+
+	// Keep index entries preceding position, and the size they span in
+	// the index file.
+	ibr := recio.NewBufferedReader(indexFile, indexSeekBufferSize, recio.ModeAuto)
+	indexReader := recio.NewAtomicReader(ibr)
+
+	lastIndexEntry := indexEntry{
+		position: desc.basePosition,
+		offset:   desc.baseOffset,
+	}
+
+	indexSize := int64(0)
+
+	ie := indexEntry{}
+	for {
+		n, err := indexReader.Read(&ie)
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF || err == recio.ErrCorrupt {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if ie.position >= position {
+			break
+		}
+
+		lastIndexEntry = ie
+		indexSize += int64(n)
+	}
+
+	// Count records from the last kept index entry up to position.
+	_, err = recordsFile.Seek(lastIndexEntry.offset-desc.baseOffset, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+
+	rbr := recio.NewBufferedReader(recordsFile, recordSeekBufferSize, recio.ModeAuto)
+	recordsReader := recio.NewAtomicReader(rbr)
+
+	current := lastIndexEntry.position
+	offset := lastIndexEntry.offset
+
+	r := Record{}
+	for current < position {
+		n, err := recordsReader.Read(&r)
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF || err == recio.ErrCorrupt || err == recio.ErrTooLarge {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		current += 1
+		offset += int64(n)
+	}
+
+	err = truncateFile(pathname+recordsSuffix, offset-desc.baseOffset)
+	if err != nil {
+		return err
+	}
+
+	err = truncateFile(pathname+indexSuffix, indexSize)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
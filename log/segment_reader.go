@@ -10,9 +10,12 @@
 package log
 
 import (
+	"encoding/binary"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"gitlab.com/dataptive/styx/recio"
 )
@@ -22,7 +25,7 @@ type segmentReader struct {
 	name                  string
 	config                Config
 	bufferSize            int
-	recordsFile           *os.File
+	recordsFile           recordsReader
 	indexFile             *os.File
 	recordsBufferedReader *recio.BufferedReader
 	indexBufferedReader   *recio.BufferedReader
@@ -33,6 +36,10 @@ type segmentReader struct {
 	baseTimestamp         int64
 	position              int64
 	offset                int64
+	timestamp             int64
+	tracksIndex           bool
+	nextIndexEntry        indexEntry
+	hasNextIndexEntry     bool
 }
 
 func newSegmentReader(path string, name string, config Config, bufferSize int) (sr *segmentReader, err error) {
@@ -41,7 +48,7 @@ func newSegmentReader(path string, name string, config Config, bufferSize int) (
 	recordsFilename := pathname + recordsSuffix
 	indexFilename := pathname + indexSuffix
 
-	recordsFile, err := os.OpenFile(recordsFilename, os.O_RDONLY, os.FileMode(0))
+	recordsFile, err := openRecordsFile(recordsFilename)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, errSegmentNotExist
@@ -86,6 +93,19 @@ func newSegmentReader(path string, name string, config Config, bufferSize int) (
 		baseTimestamp:         baseTimestamp,
 		position:              position,
 		offset:                offset,
+		timestamp:             unknownTimestamp,
+		tracksIndex:           false,
+		nextIndexEntry:        indexEntry{},
+		hasNextIndexEntry:     false,
+	}
+
+	// Compacted segments have gaps in positions, which are only recorded
+	// in their index.
+	if parseSegmentGeneration(name) > 0 {
+		err = sr.trackIndex()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return sr, nil
@@ -113,6 +133,11 @@ func (sr *segmentReader) Tell() (position, offset int64) {
 
 func (sr *segmentReader) Read(r *Record) (n int, err error) {
 
+	err = sr.applyIndexEntries()
+	if err != nil {
+		return 0, err
+	}
+
 	n, err = sr.recordsAtomicReader.Read(r)
 
 	if err == io.ErrUnexpectedEOF {
@@ -172,8 +197,9 @@ func (sr *segmentReader) SeekPosition(position int64) (err error) {
 
 	// Iterate over index entries until we're past the requested position
 	// or EOF. If an index entry is corrupt, we skip it in case the next
-	// one is usable.
+	// one is usable, unless positions depend on the index.
 	tmp := indexEntry{}
+	past := false
 	for {
 		_, err = sr.indexAtomicReader.Read(&tmp)
 
@@ -182,10 +208,16 @@ func (sr *segmentReader) SeekPosition(position int64) (err error) {
 		}
 
 		if err == io.ErrUnexpectedEOF {
+			if sr.tracksIndex {
+				return ErrCorrupt
+			}
 			break
 		}
 
 		if err == recio.ErrCorrupt {
+			if sr.tracksIndex {
+				return ErrCorrupt
+			}
 			continue
 		}
 
@@ -194,23 +226,33 @@ func (sr *segmentReader) SeekPosition(position int64) (err error) {
 		}
 
 		if tmp.position > position {
+			past = true
 			break
 		}
 
 		ie = tmp
 	}
 
+	if sr.tracksIndex {
+		sr.nextIndexEntry = tmp
+		sr.hasNextIndexEntry = past
+	}
+
 	// Compute the offset in the record file we should be seeking to, and
 	// check that it doesn't land after EOF. If it does, the index is not
 	// usable and we'll start iterating from the start of the record file.
-	fi, err := sr.recordsFile.Stat()
+	size, err := sr.recordsFile.Size()
 	if err != nil {
 		return err
 	}
 
 	relativeOffset := ie.offset - sr.baseOffset
 
-	if relativeOffset > fi.Size() {
+	if relativeOffset > size && sr.tracksIndex {
+		return ErrCorrupt
+	}
+
+	if relativeOffset > size {
 
 		_, err = sr.recordsFile.Seek(0, os.SEEK_SET)
 		if err != nil {
@@ -233,13 +275,20 @@ func (sr *segmentReader) SeekPosition(position int64) (err error) {
 
 		sr.position = ie.position
 		sr.offset = ie.offset
+		sr.timestamp = ie.timestamp
 	}
 
 	// Iterate over records until we've found the requested position or
-	// reached EOF.
+	// reached EOF. In compacted segments, the requested position may have
+	// been removed, in which case we stop at the next record.
 	r := Record{}
 	for {
-		if sr.position == position {
+		err = sr.applyIndexEntries()
+		if err != nil {
+			return err
+		}
+
+		if sr.position >= position {
 			break
 		}
 
@@ -253,6 +302,10 @@ func (sr *segmentReader) SeekPosition(position int64) (err error) {
 			continue
 		}
 
+		if err == io.EOF && sr.tracksIndex {
+			break
+		}
+
 		if err == io.EOF {
 			return ErrOutOfRange
 		}
@@ -279,3 +332,237 @@ func (sr *segmentReader) SeekPosition(position int64) (err error) {
 
 	return nil
 }
+
+// SearchTimestamp returns the position of the first record in the segment
+// written at or after timestamp. When the index does not allow to tell
+// precisely, as with entries written by earlier versions, it returns the
+// earliest position that may match. It fails with err == ErrOutOfRange if all
+// records in the segment were written before timestamp.
+func (sr *segmentReader) SearchTimestamp(timestamp int64) (position int64, err error) {
+
+	position, err = sr.bisectTimestamp(timestamp)
+	if err != errUnsearchableIndex {
+		return position, err
+	}
+
+	return sr.scanTimestamp(timestamp)
+}
+
+// bisectTimestamp does a binary search of timestamp over index entries, which
+// have a fixed size. It fails with err == errUnsearchableIndex when the index
+// holds entries it can't search, either corrupt, written by earlier versions or
+// without timestamp, as in rebuilt indexes.
+func (sr *segmentReader) bisectTimestamp(timestamp int64) (position int64, err error) {
+
+	fi, err := sr.indexFile.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// A partially written last entry is ignored, as when scanning.
+	count := int(fi.Size() / indexEntrySize)
+
+	if count == 0 {
+		return 0, errUnsearchableIndex
+	}
+
+	buffer := make([]byte, indexEntrySize)
+	ie := indexEntry{}
+
+	readEntry := func(i int) (err error) {
+
+		_, err = sr.indexFile.ReadAt(buffer, int64(i)*indexEntrySize)
+		if err != nil {
+			return err
+		}
+
+		n, err := ie.Decode(buffer)
+		if err != nil || n != indexEntrySize-4 {
+			return errUnsearchableIndex
+		}
+
+		crc := binary.BigEndian.Uint32(buffer[n:])
+		if crc != crc32.Checksum(buffer[:n], castagnoliTable) {
+			return errUnsearchableIndex
+		}
+
+		if ie.timestamp == unknownTimestamp {
+			return errUnsearchableIndex
+		}
+
+		return nil
+	}
+
+	// Entries written by earlier versions can only precede the others.
+	// Entries without timestamp are found in whole indexes.
+	err = readEntry(0)
+	if err != nil {
+		return 0, err
+	}
+
+	err = readEntry(count - 1)
+	if err != nil {
+		return 0, err
+	}
+
+	var searchErr error
+
+	found := sort.Search(count, func(i int) bool {
+
+		if searchErr != nil {
+			return true
+		}
+
+		searchErr = readEntry(i)
+		if searchErr != nil {
+			return true
+		}
+
+		return ie.timestamp >= timestamp
+	})
+
+	if searchErr != nil {
+		return 0, searchErr
+	}
+
+	// Records preceding the first entry may have been written at or
+	// after timestamp.
+	if found == 0 {
+		return sr.basePosition, nil
+	}
+
+	// Records covered by the last entry were all written before
+	// timestamp.
+	if found == count {
+		return 0, ErrOutOfRange
+	}
+
+	err = readEntry(found)
+	if err != nil {
+		return 0, err
+	}
+
+	return ie.position, nil
+}
+
+// scanTimestamp reads index entries one by one, skipping corrupt ones.
+func (sr *segmentReader) scanTimestamp(timestamp int64) (position int64, err error) {
+
+	// Position ourselves back to the start of the index.
+	_, err = sr.indexFile.Seek(0, os.SEEK_SET)
+	if err != nil {
+		return 0, err
+	}
+
+	sr.indexBufferedReader.Reset(sr.indexFile)
+
+	// Records covered by an index entry, up to the next entry, share the
+	// entry's timestamp. Records at the start of the segment or covered
+	// by an entry without timestamp are only known to have been written
+	// before the next entry.
+	ie := indexEntry{
+		position:  sr.basePosition,
+		offset:    sr.baseOffset,
+		timestamp: unknownTimestamp,
+	}
+
+	tmp := indexEntry{}
+	for {
+		_, err = sr.indexAtomicReader.Read(&tmp)
+
+		if err == io.EOF {
+			break
+		}
+
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+
+		if err == recio.ErrCorrupt {
+			// We can't tell where the records covered by the
+			// current entry end anymore.
+			ie.timestamp = unknownTimestamp
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		if ie.timestamp >= timestamp {
+			return ie.position, nil
+		}
+
+		if ie.timestamp == unknownTimestamp && tmp.position > ie.position {
+			if tmp.timestamp == unknownTimestamp || tmp.timestamp >= timestamp {
+				return ie.position, nil
+			}
+		}
+
+		ie = tmp
+	}
+
+	if ie.timestamp >= timestamp || ie.timestamp == unknownTimestamp {
+		return ie.position, nil
+	}
+
+	return 0, ErrOutOfRange
+}
+
+// trackIndex makes the reader take record positions and timestamps from the
+// index entries it encounters, rather than only counting records.
+func (sr *segmentReader) trackIndex() (err error) {
+
+	sr.tracksIndex = true
+
+	err = sr.readIndexEntry()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (sr *segmentReader) readIndexEntry() (err error) {
+
+	_, err = sr.indexAtomicReader.Read(&sr.nextIndexEntry)
+
+	if err == io.EOF {
+		sr.hasNextIndexEntry = false
+		return nil
+	}
+
+	if err == io.ErrUnexpectedEOF {
+		return ErrCorrupt
+	}
+
+	if err == recio.ErrCorrupt {
+		return ErrCorrupt
+	}
+
+	if err != nil {
+		return err
+	}
+
+	sr.hasNextIndexEntry = true
+
+	return nil
+}
+
+func (sr *segmentReader) applyIndexEntries() (err error) {
+
+	for sr.hasNextIndexEntry && sr.nextIndexEntry.offset <= sr.offset {
+
+		if sr.nextIndexEntry.offset == sr.offset {
+			sr.position = sr.nextIndexEntry.position
+			sr.timestamp = sr.nextIndexEntry.timestamp
+		}
+
+		err = sr.readIndexEntry()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
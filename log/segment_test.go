@@ -10,7 +10,10 @@
 package log
 
 import (
+	"encoding/binary"
+	"hash/crc32"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -66,7 +69,7 @@ func benchmarkSegment_Writer(b *testing.B, payloadSize int) {
 	defer sw.Close()
 
 	payload := make([]byte, payloadSize)
-	r := Record(payload)
+	r := Record{Payload: payload}
 
 	b.StartTimer()
 
@@ -134,7 +137,7 @@ func TestSegmentWriter_Write1(t *testing.T) {
 
 	path := t.TempDir()
 	expectedLog := int64(2112)
-	expectedIndex := int64(40)
+	expectedIndex := int64(84)
 
 	logFileSize, indexFileSize := testSegment_Write(t, path, true, 8, 256, 1<<10)
 
@@ -151,7 +154,7 @@ func TestSegmentWriter_Write2(t *testing.T) {
 
 	path := t.TempDir()
 	expectedLog := int64(2112)
-	expectedIndex := int64(160)
+	expectedIndex := int64(252)
 
 	logFileSize, indexFileSize := testSegment_Write(t, path, true, 8, 256, 0)
 
@@ -168,7 +171,7 @@ func TestSegmentWriter_Write3(t *testing.T) {
 
 	path := t.TempDir()
 	expectedLog := int64(2112)
-	expectedIndex := int64(160)
+	expectedIndex := int64(252)
 
 	logFileSize, indexFileSize := testSegment_Write(t, path, true, 8, 256, 256)
 
@@ -186,7 +189,7 @@ func TestSegmentWriter_Append(t *testing.T) {
 
 	path := t.TempDir()
 	expectedLog := int64(4224)
-	expectedIndex := int64(80)
+	expectedIndex := int64(140)
 
 	testSegment_Write(t, path, true, 8, 256, 1<<10)
 	logFileSize, indexFileSize := testSegment_Write(t, path, false, 8, 256, 1<<10)
@@ -220,7 +223,7 @@ func testSegment_Write(t *testing.T, path string, create bool, recordCount int,
 
 	payload := make([]byte, payloadSize)
 
-	r := Record(payload)
+	r := Record{Payload: payload}
 
 	for i := 0; i < recordCount; i++ {
 		_, err := sw.Write(&r)
@@ -284,7 +287,7 @@ func TestSegmentWriter_MaxRecordSize(t *testing.T) {
 	defer sw.Close()
 
 	payload := make([]byte, 1<<20)
-	r := Record(payload)
+	r := Record{Payload: payload}
 
 	_, err = sw.Write(&r)
 
@@ -314,7 +317,7 @@ func TestSegmentWriter_MaxCount(t *testing.T) {
 	defer sw.Close()
 
 	payload := make([]byte, 256)
-	r := Record(payload)
+	r := Record{Payload: payload}
 
 	for i := 0; i < 5; i++ {
 		_, err := sw.Write(&r)
@@ -351,7 +354,7 @@ func TestSegmentWriter_MaxSize(t *testing.T) {
 	defer sw.Close()
 
 	payload := make([]byte, 256)
-	r := Record(payload)
+	r := Record{Payload: payload}
 
 	for i := 0; i < 5; i++ {
 		_, err := sw.Write(&r)
@@ -388,7 +391,7 @@ func TestSegmentWriter_MaxAge(t *testing.T) {
 	defer sw.Close()
 
 	payload := make([]byte, 256)
-	r := Record(payload)
+	r := Record{Payload: payload}
 
 	time.Sleep(1 * time.Second)
 
@@ -755,3 +758,137 @@ func TestSegmentReader_CorruptRecord(t *testing.T) {
 		t.Fatalf("seek should have failed with error ErrCorrupt but got err = %s", err)
 	}
 }
+
+// testSegment_WriteIndex replaces the index of the segment with entries
+// carrying timestamps, or legacy entries without timestamp.
+func testSegment_WriteIndex(t *testing.T, path string, entries []indexEntry, legacy bool) {
+
+	name := buildSegmentName(0, 0, 0)
+	pathname := filepath.Join(path, name) + indexSuffix
+
+	buffer := []byte{}
+
+	for _, entry := range entries {
+
+		p := make([]byte, indexEntrySize)
+
+		n, err := entry.Encode(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if legacy {
+			binary.BigEndian.PutUint64(p, uint64(entry.position))
+			n = 8 + 8
+		}
+
+		crc := crc32.Checksum(p[:n], castagnoliTable)
+		binary.BigEndian.PutUint32(p[n:], crc)
+
+		buffer = append(buffer, p[:n+4]...)
+	}
+
+	err := ioutil.WriteFile(pathname, buffer, os.FileMode(0644))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that searching timestamps with a binary search over the index gives
+// the same positions as scanning it, and that indexes written by earlier
+// versions are still scanned.
+func TestSegmentReader_SearchTimestamp(t *testing.T) {
+
+	path := t.TempDir()
+	name := buildSegmentName(0, 0, 0)
+	config := DefaultConfig
+	bufferSize := 1 << 10
+
+	testSegment_Write(t, path, true, 8, 256, 0)
+
+	entries := []indexEntry{}
+	for i := int64(0); i < 100; i++ {
+		entries = append(entries, indexEntry{
+			position:  i*10 + 5,
+			offset:    i * 1000,
+			timestamp: 1000 + i/3,
+		})
+	}
+
+	tests := []struct {
+		name    string
+		entries []indexEntry
+		legacy  bool
+	}{
+		{name: "sorted", entries: entries, legacy: false},
+		{name: "single", entries: entries[:1], legacy: false},
+		{name: "legacy", entries: entries, legacy: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			testSegment_WriteIndex(t, path, test.entries, test.legacy)
+
+			sr, err := newSegmentReader(path, name, config, bufferSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sr.Close()
+
+			_, err = sr.bisectTimestamp(0)
+			if (err == errUnsearchableIndex) != test.legacy {
+				t.Fatalf("binary search should have failed = %v but got err = %v", test.legacy, err)
+			}
+
+			for timestamp := int64(990); timestamp < 1050; timestamp++ {
+
+				expected, expectedErr := sr.scanTimestamp(timestamp)
+
+				position, err := sr.SearchTimestamp(timestamp)
+				if position != expected || err != expectedErr {
+					t.Fatalf("search of %d should have returned %d (%v) but got %d (%v)", timestamp, expected, expectedErr, position, err)
+				}
+			}
+		})
+	}
+}
+
+// Tests that searching timestamps in a segment whose index was rebuilt, and
+// lost its timestamps, falls back to scanning the index.
+func TestSegmentReader_SearchTimestampRebuilt(t *testing.T) {
+
+	path := t.TempDir()
+	name := buildSegmentName(0, 0, 0)
+	config := DefaultConfig
+	bufferSize := 1 << 10
+
+	testSegment_Write(t, path, true, 8, 256, 0)
+
+	desc := segmentDescriptor{
+		segmentName:  name,
+		basePosition: 0,
+		baseOffset:   0,
+	}
+
+	err := rebuildIndex(path, desc, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sr, err := newSegmentReader(path, name, config, bufferSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+
+	_, err = sr.bisectTimestamp(0)
+	if err != errUnsearchableIndex {
+		t.Fatalf("binary search should have failed with err = %v but got err = %v", errUnsearchableIndex, err)
+	}
+
+	position, err := sr.SearchTimestamp(0)
+	if position != 0 || err != nil {
+		t.Fatalf("search should have returned 0 (<nil>) but got %d (%v)", position, err)
+	}
+}
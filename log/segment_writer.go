@@ -68,9 +68,12 @@ func newSegmentWriter(path string, name string, create bool, config Config, buff
 	position := basePosition
 	offset := baseOffset
 
+	// The first record written to the segment will always be indexed
+	// along with its timestamp.
 	lastIndexEntry := indexEntry{
-		position: position,
-		offset:   offset,
+		position:  position,
+		offset:    offset,
+		timestamp: unknownTimestamp,
 	}
 
 	sw = &segmentWriter{
@@ -154,6 +157,24 @@ func (sw *segmentWriter) Write(r *Record) (n int, err error) {
 		}
 	}
 
+	// Index the first record written each second, so that all records
+	// following an index entry up to the next one share its timestamp.
+	timestamp := now.Unix()
+
+	if timestamp != sw.lastIndexEntry.timestamp {
+
+		sw.lastIndexEntry = indexEntry{
+			position:  sw.position,
+			offset:    sw.offset,
+			timestamp: timestamp,
+		}
+
+		_, err := sw.indexAtomicWriter.Write(&sw.lastIndexEntry)
+		if err != nil {
+			return 0, err
+		}
+	}
+
 	n, err = sw.recordsAtomicWriter.Write(r)
 	if err != nil {
 		return 0, err
@@ -165,8 +186,9 @@ func (sw *segmentWriter) Write(r *Record) (n int, err error) {
 	if sw.offset-sw.lastIndexEntry.offset >= sw.config.IndexAfterSize {
 
 		sw.lastIndexEntry = indexEntry{
-			position: sw.position,
-			offset:   sw.offset,
+			position:  sw.position,
+			offset:    sw.offset,
+			timestamp: timestamp,
 		}
 
 		_, err := sw.indexAtomicWriter.Write(&sw.lastIndexEntry)
@@ -10,7 +10,10 @@
 package log
 
 import (
+	"io"
 	"os"
+	"path/filepath"
+	"syscall"
 )
 
 func syncFile(pathname string) (err error) {
@@ -44,3 +47,111 @@ func syncDirectory(path string) (err error) {
 
 	return nil
 }
+
+// linkFile creates a hard link to pathname at newPathname, or copies it when
+// hard links are not supported, e.g. across file systems.
+func linkFile(pathname string, newPathname string) (err error) {
+
+	err = os.Link(pathname, newPathname)
+	if err == nil {
+		return nil
+	}
+
+	f, err := os.Open(pathname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = copyFile(f, newPathname, fi.Size())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// copyFile copies size bytes of r to a new file at pathname and syncs it.
+func copyFile(r io.Reader, pathname string, size int64) (err error) {
+
+	f, err := os.OpenFile(pathname, os.O_WRONLY|os.O_CREATE|os.O_EXCL, os.FileMode(filePerm))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.CopyN(f, r, size)
+	if err != nil {
+		return err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// truncateFile truncates the file at pathname to size and syncs it. Files
+// linked to log copies are copied up to size and replaced instead, so that
+// copies are left untouched.
+func truncateFile(pathname string, size int64) (err error) {
+
+	f, err := os.OpenFile(pathname, os.O_RDWR, os.FileMode(filePerm))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink <= 1 {
+
+		err = f.Truncate(size)
+		if err != nil {
+			return err
+		}
+
+		err = f.Sync()
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	tmpPathname := pathname + tmpSuffix
+
+	// Remove a temporary file left behind by a crash.
+	err = os.Remove(tmpPathname)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = copyFile(f, tmpPathname, size)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPathname, pathname)
+	if err != nil {
+		return err
+	}
+
+	err = syncDirectory(filepath.Dir(pathname))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
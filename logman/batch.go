@@ -0,0 +1,423 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logman
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/recio"
+)
+
+const (
+	// The batch journal lives in the data directory and shares the groups
+	// file format, with log names in place of group names. Its name isn't
+	// a valid log name so that it is never listed as a log.
+	batchJournalFilename = ".batch"
+)
+
+var (
+	// Time allowed for the records of a batch to be synced once written.
+	batchSyncTimeout = 30 * time.Second
+)
+
+// WriteBatch writes records to several logs, all or none, and returns the
+// position following the written records of each log.
+//
+// Batches are written one at a time. Each log of the batch is locked, in name
+// order so that batches never deadlock, and its end position is recorded in
+// the batch journal before writing. Once all records are synced the batch is
+// removed from the journal, which commits it. Logs listed in a journal left
+// behind by a failure are rolled back to their recorded position, either right
+// away or when opening the log manager after a crash. Logs that can't be
+// rolled back stay in the journal and are left unavailable.
+//
+// The guarantee covers durability only: records are visible to readers as soon
+// as they are written, before the batch is committed, and may still be rolled
+// back afterwards.
+func (lm *LogManager) WriteBatch(batch map[string][]*log.Record) (positions map[string]int64, err error) {
+
+	lm.batchLock.Lock()
+	defer lm.batchLock.Unlock()
+
+	if lm.closed {
+		return nil, ErrClosed
+	}
+
+	names := []string{}
+	for name := range batch {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	positions = make(map[string]int64)
+
+	if len(names) == 0 {
+		return positions, nil
+	}
+
+	logs := []*Log{}
+	writers := []*log.FaninWriter{}
+	syncChans := []chan log.SyncProgress{}
+	starts := make(map[string]int64)
+
+	defer func() {
+		for _, fw := range writers {
+			fw.Close()
+		}
+	}()
+
+	// Logs waiting to be rolled back stay in the journal.
+	journal := &groupStore{
+		pathname:  filepath.Join(lm.config.DataDirectory, batchJournalFilename),
+		positions: make(map[string]int64),
+	}
+
+	for name, position := range lm.pendingRollbacks {
+		journal.positions[name] = position
+	}
+
+	for _, name := range names {
+
+		ml, err := lm.GetLog(name)
+		if err != nil {
+			return nil, err
+		}
+
+		logs = append(logs, ml)
+
+		fw, err := ml.NewWriter(recio.ModeAuto)
+		if err != nil {
+			return nil, err
+		}
+
+		writers = append(writers, fw)
+
+		syncChan := make(chan log.SyncProgress, 1)
+		syncChans = append(syncChans, syncChan)
+
+		fw.HandleSync(func(syncProgress log.SyncProgress) {
+			select {
+			case <-syncChan:
+			default:
+			}
+			syncChan <- syncProgress
+		})
+
+		position, err := fw.Acquire()
+		if err != nil {
+			return nil, err
+		}
+
+		// Keep the records of the batch from being compacted or
+		// compressed until it is committed, so that it can be rolled
+		// back.
+		ml.lock.RLock()
+		l := ml.log
+		ml.lock.RUnlock()
+
+		l.HoldRollback(position)
+		defer l.ReleaseRollback()
+
+		starts[name] = position
+		journal.positions[name] = position
+	}
+
+	err = journal.dump()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range names {
+
+		fw := writers[i]
+
+		for _, r := range batch[name] {
+			_, err = fw.Write(r)
+			if err == log.ErrDuplicate {
+				continue
+			}
+
+			if err != nil {
+				lm.rollbackBatch(starts)
+				return nil, err
+			}
+		}
+
+		err = fw.Flush()
+		if err != nil {
+			lm.rollbackBatch(starts)
+			return nil, err
+		}
+
+		positions[name] = fw.Tell()
+	}
+
+	// Wait for all records to be synced before committing.
+	timeout := time.NewTimer(batchSyncTimeout)
+	defer timeout.Stop()
+
+	for i, name := range names {
+
+		err = waitSynced(syncChans[i], positions[name], logs[i].done, timeout.C)
+		if err != nil {
+			lm.rollbackBatch(starts)
+			return nil, err
+		}
+	}
+
+	err = lm.dumpPendingRollbacks()
+	if err != nil {
+		lm.rollbackBatch(starts)
+		return nil, err
+	}
+
+	return positions, nil
+}
+
+// waitSynced waits for a log to be synced up to position. It fails with
+// ErrUnavailable when the log is closed in the meantime, and ErrSyncTimeout
+// once timeout fires.
+func waitSynced(syncChan chan log.SyncProgress, position int64, done chan struct{}, timeout <-chan time.Time) (err error) {
+
+	for {
+		select {
+		case syncProgress := <-syncChan:
+			if syncProgress.Position >= position {
+				return nil
+			}
+
+		case <-done:
+			return ErrUnavailable
+
+		case <-timeout:
+			return ErrSyncTimeout
+		}
+	}
+}
+
+// rollbackBatch rolls back the logs of a failed batch, which are still locked
+// by its writers, to their start position. Logs that can't be rolled back are
+// left unavailable so that nothing gets written after the batch records, and
+// stay in the journal to be rolled back again when opening the log manager.
+func (lm *LogManager) rollbackBatch(starts map[string]int64) {
+
+	lm.logsLock.Lock()
+	defer lm.logsLock.Unlock()
+
+	// Logs of a closed log manager are rolled back when opening it again.
+	if lm.closed {
+		return
+	}
+
+	for name, position := range starts {
+
+		pos := -1
+		for i, ml := range lm.logs {
+			if ml.name == name {
+				pos = i
+				break
+			}
+		}
+
+		if pos == -1 {
+			continue
+		}
+
+		err := lm.rollbackLog(pos, position)
+		if err != nil {
+			logger.Warn(err)
+			lm.pendingRollbacks[name] = position
+		}
+	}
+
+	err := lm.dumpPendingRollbacks()
+	if err != nil {
+		logger.Warn(err)
+	}
+}
+
+// rollbackLog rolls back the log at pos in the log list to position and opens
+// it again. The log is left unavailable when rollback fails.
+func (lm *LogManager) rollbackLog(pos int, position int64) (err error) {
+
+	ml := lm.logs[pos]
+
+	err = ml.markTainted()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(lm.config.DataDirectory, ml.name)
+
+	err = log.Rollback(path, position)
+	if err != nil {
+		return err
+	}
+
+	ml, err = openLog(lm.config.DataDirectory, ml.name, log.DefaultOptions, lm.config.ReadBufferSize, lm.config.WriteBufferSize, lm.reporter)
+	if err != nil {
+		return err
+	}
+
+	lm.logs[pos] = ml
+
+	return nil
+}
+
+// dumpPendingRollbacks writes the logs waiting to be rolled back to the batch
+// journal, or deletes it when there are none left.
+func (lm *LogManager) dumpPendingRollbacks() (err error) {
+
+	if len(lm.pendingRollbacks) == 0 {
+		return deleteBatchJournal(lm.config.DataDirectory)
+	}
+
+	journal := &groupStore{
+		pathname:  filepath.Join(lm.config.DataDirectory, batchJournalFilename),
+		positions: lm.pendingRollbacks,
+	}
+
+	err = journal.dump()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// clearPendingRollback removes a deleted or truncated log from the logs
+// waiting to be rolled back.
+func (lm *LogManager) clearPendingRollback(name string) (err error) {
+
+	_, found := lm.pendingRollbacks[name]
+	if !found {
+		return nil
+	}
+
+	delete(lm.pendingRollbacks, name)
+
+	err = lm.dumpPendingRollbacks()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// markTainted closes the log and leaves it unavailable.
+func (ml *Log) markTainted() (err error) {
+
+	err = ml.close()
+	if err != nil {
+		return err
+	}
+
+	ml.lock.Lock()
+	ml.status = StatusTainted
+	ml.lock.Unlock()
+
+	return nil
+}
+
+// recoverBatch rolls back the logs of a batch interrupted by a crash. It must
+// be called before opening logs. Logs that can't be rolled back are kept in
+// the journal and returned, so that they are left unavailable.
+func recoverBatch(path string) (pending map[string]int64, err error) {
+
+	journal := &groupStore{
+		pathname:  filepath.Join(path, batchJournalFilename),
+		positions: make(map[string]int64),
+	}
+
+	pending = make(map[string]int64)
+
+	err = journal.load()
+	if os.IsNotExist(err) {
+		return pending, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for name, position := range journal.positions {
+
+		pathname := filepath.Join(path, name)
+
+		// Deleted logs have nothing left to roll back.
+		_, err = os.Stat(pathname)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Debugf("logman: rolling back log %s to position %d", name, position)
+
+		err = log.Rollback(pathname, position)
+		if err != nil {
+			logger.Warnf("logman: failed to roll back log %s to position %d: %v", name, position, err)
+			pending[name] = position
+		}
+	}
+
+	if len(pending) != 0 {
+		journal.positions = pending
+
+		err = journal.dump()
+		if err != nil {
+			return nil, err
+		}
+
+		return pending, nil
+	}
+
+	err = deleteBatchJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+func deleteBatchJournal(path string) (err error) {
+
+	err = os.Remove(filepath.Join(path, batchJournalFilename))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Sync the data directory to persist the removal.
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if err != nil {
+		d.Close()
+		return err
+	}
+
+	err = d.Close()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,269 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logman
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/dataptive/styx/log"
+)
+
+// Tests that waiting for a batch to be synced stops when the log is closed or
+// on timeout.
+func TestBatch_WaitSynced(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		synced  []int64
+		closed  bool
+		timeout time.Duration
+		err     error
+	}{
+		{name: "synced", synced: []int64{5, 10}, timeout: time.Minute, err: nil},
+		{name: "closed", synced: []int64{5}, closed: true, timeout: time.Minute, err: ErrUnavailable},
+		{name: "timeout", synced: []int64{5}, timeout: 10 * time.Millisecond, err: ErrSyncTimeout},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			syncChan := make(chan log.SyncProgress, len(test.synced))
+			done := make(chan struct{})
+
+			for _, position := range test.synced {
+				syncChan <- log.SyncProgress{Position: position}
+			}
+
+			if test.closed {
+				close(done)
+			}
+
+			timeout := time.NewTimer(test.timeout)
+			defer timeout.Stop()
+
+			err := waitSynced(syncChan, 10, done, timeout.C)
+			if err != test.err {
+				t.Fatalf("wait should have returned err = %v but got err = %v", test.err, err)
+			}
+		})
+	}
+}
+
+// Tests that a batch is written to all its logs and committed.
+func TestBatch_Write(t *testing.T) {
+
+	lm := testBatch_NewLogManager(t, t.TempDir())
+	defer lm.Close()
+
+	testBatch_CreateLog(t, lm, "a", log.DefaultConfig)
+	testBatch_CreateLog(t, lm, "b", log.DefaultConfig)
+
+	positions, err := lm.WriteBatch(map[string][]*log.Record{
+		"a": testBatch_Records(3, 10),
+		"b": testBatch_Records(2, 10),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if positions["a"] != 3 || positions["b"] != 2 {
+		t.Fatalf("batch should end at positions a=3 b=2 but got %v", positions)
+	}
+
+	testBatch_CheckEnd(t, lm, "a", 3)
+	testBatch_CheckEnd(t, lm, "b", 2)
+	testBatch_CheckJournal(t, lm, false)
+}
+
+// Tests that logs are rolled back when writing a batch fails.
+func TestBatch_Rollback(t *testing.T) {
+
+	lm := testBatch_NewLogManager(t, t.TempDir())
+	defer lm.Close()
+
+	config := log.DefaultConfig
+	config.MaxRecordSize = 100
+
+	testBatch_CreateLog(t, lm, "a", config)
+	testBatch_CreateLog(t, lm, "b", config)
+
+	_, err := lm.WriteBatch(map[string][]*log.Record{
+		"a": testBatch_Records(3, 10),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Records of b are too large, records of a must be rolled back.
+	_, err = lm.WriteBatch(map[string][]*log.Record{
+		"a": testBatch_Records(3, 10),
+		"b": testBatch_Records(1, 1000),
+	})
+	if err == nil {
+		t.Fatalf("batch should have failed")
+	}
+
+	testBatch_CheckEnd(t, lm, "a", 3)
+	testBatch_CheckEnd(t, lm, "b", 0)
+	testBatch_CheckJournal(t, lm, false)
+}
+
+// Tests that logs listed in a journal left behind by a crash are rolled back
+// when opening the log manager, and that logs that can't be rolled back are
+// left unavailable without preventing others from opening.
+func TestBatch_Recover(t *testing.T) {
+
+	path := t.TempDir()
+
+	lm := testBatch_NewLogManager(t, path)
+
+	compressed := log.DefaultConfig
+	compressed.SegmentMaxCount = 5
+	compressed.Compression = log.CompressionGzip
+
+	testBatch_CreateLog(t, lm, "a", log.DefaultConfig)
+	testBatch_CreateLog(t, lm, "b", compressed)
+
+	_, err := lm.WriteBatch(map[string][]*log.Record{
+		"a": testBatch_Records(10, 10),
+		"b": testBatch_Records(10, 10),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := log.Open(filepath.Join(path, "b"), log.DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash in the middle of a batch. The first segment of b
+	// is compressed and can't be rolled back.
+	journal := &groupStore{
+		pathname:  filepath.Join(path, batchJournalFilename),
+		positions: map[string]int64{"a": 4, "b": 2},
+	}
+
+	err = journal.dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lm = testBatch_NewLogManager(t, path)
+	defer lm.Close()
+
+	testBatch_CheckEnd(t, lm, "a", 4)
+
+	ml, err := lm.GetLog("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ml.Status() != StatusTainted {
+		t.Fatalf("log b should be tainted but is %s", ml.Status())
+	}
+
+	testBatch_CheckJournal(t, lm, true)
+
+	journal.positions = make(map[string]int64)
+
+	err = journal.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(journal.positions) != 1 || journal.positions["b"] != 2 {
+		t.Fatalf("journal should only hold log b but holds %v", journal.positions)
+	}
+
+	// Deleting the log removes it from the journal.
+	err = lm.DeleteLog("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testBatch_CheckJournal(t, lm, false)
+}
+
+func testBatch_NewLogManager(t *testing.T, path string) (lm *LogManager) {
+
+	config := DefaultConfig
+	config.DataDirectory = path
+
+	lm, err := NewLogManager(config, testReporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return lm
+}
+
+func testBatch_CreateLog(t *testing.T, lm *LogManager, name string, config log.Config) {
+
+	_, err := lm.CreateLog(name, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testBatch_Records(count int, payloadSize int) (records []*log.Record) {
+
+	for i := 0; i < count; i++ {
+		payload := []byte(fmt.Sprintf("%0*d", payloadSize, i))
+		records = append(records, &log.Record{Payload: payload})
+	}
+
+	return records
+}
+
+func testBatch_CheckEnd(t *testing.T, lm *LogManager, name string, position int64) {
+
+	ml, err := lm.GetLog(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logInfo := ml.Stat()
+
+	if logInfo.Status != StatusOK {
+		t.Fatalf("log %s should be available but is %s", name, logInfo.Status)
+	}
+
+	if logInfo.EndPosition != position {
+		t.Fatalf("log %s should end at position %d but ends at %d", name, position, logInfo.EndPosition)
+	}
+}
+
+func testBatch_CheckJournal(t *testing.T, lm *LogManager, exists bool) {
+
+	_, err := os.Stat(filepath.Join(lm.config.DataDirectory, batchJournalFilename))
+	if os.IsNotExist(err) == exists {
+		t.Fatalf("batch journal should exist = %v, got err = %v", exists, err)
+	}
+}
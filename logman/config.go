@@ -14,6 +14,9 @@ var (
 		DataDirectory:   "./data",
 		ReadBufferSize:  1 << 20, // 1MB
 		WriteBufferSize: 1 << 20, // 1MB
+		RepairTail:      false,
+		ScrubInterval:   0,
+		ScrubRate:       10 << 20, // 10MB/s
 	}
 )
 
@@ -21,4 +24,7 @@ type Config struct {
 	DataDirectory   string
 	ReadBufferSize  int
 	WriteBufferSize int
+	RepairTail      bool  // Repair torn log tails when scanning logs on startup.
+	ScrubInterval   int64 // Seconds between scrubbing passes over closed segments, 0 to disable.
+	ScrubRate       int64 // Bytes read per second when scrubbing.
 }
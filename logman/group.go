@@ -0,0 +1,340 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logman
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"gitlab.com/dataptive/styx/logger"
+)
+
+const (
+	groupsFilename        = "groups"
+	corruptGroupsFilename = "groups.corrupt"
+	groupsVersion         = 0
+	groupsFilePerm        = 0644
+)
+
+var (
+	ErrGroupNotExist    = errors.New("logman: group does not exist")
+	ErrInvalidGroupName = errors.New("logman: invalid group name")
+	ErrInvalidPosition  = errors.New("logman: invalid position")
+	ErrCorruptGroups    = errors.New("logman: corrupt groups file")
+
+	castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type GroupInfo struct {
+	Name     string
+	Position int64
+}
+
+// groupStore persists the positions committed by the consumer groups of a
+// log.
+//
+// Positions are stored in the groups file of the log directory, which is
+// structured as follows.
+//
+//	+-------------------+-----------------+- - - - - - - - - -+----------------+
+//	|  version (int32)  |  count (int32)  |      groups       |  CRC (uint32)  |
+//	+-------------------+-----------------+- - - - - - - - - -+----------------+
+//
+// Each group is encoded as a name length (int32), the name and the committed
+// position (int64). The CRC32-C covers the whole file. The file is replaced
+// atomically on each commit.
+type groupStore struct {
+	pathname  string
+	positions map[string]int64
+	lock      sync.Mutex
+}
+
+func openGroupStore(path string) (gs *groupStore, err error) {
+
+	gs = &groupStore{
+		pathname:  filepath.Join(path, groupsFilename),
+		positions: make(map[string]int64),
+	}
+
+	err = gs.load()
+	if err == ErrCorruptGroups {
+		err = gs.reset()
+		if err != nil {
+			return nil, err
+		}
+
+		return gs, nil
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return gs, nil
+}
+
+func deleteGroupStore(path string) (err error) {
+
+	pathname := filepath.Join(path, groupsFilename)
+
+	err = os.Remove(pathname)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	corruptPathname := filepath.Join(path, corruptGroupsFilename)
+
+	err = os.Remove(corruptPathname)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// reset moves a corrupt groups file aside and starts over without groups, so
+// that the log itself stays available.
+func (gs *groupStore) reset() (err error) {
+
+	corruptPathname := filepath.Join(filepath.Dir(gs.pathname), corruptGroupsFilename)
+
+	logger.Warnf("logman: corrupt groups file %s, committed positions are reset (moved to %s)", gs.pathname, corruptPathname)
+
+	gs.positions = make(map[string]int64)
+
+	err = os.Rename(gs.pathname, corruptPathname)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (gs *groupStore) list() (groups []GroupInfo) {
+
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+
+	groups = []GroupInfo{}
+	for name, position := range gs.positions {
+		groups = append(groups, GroupInfo{
+			Name:     name,
+			Position: position,
+		})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+
+	return groups
+}
+
+func (gs *groupStore) get(name string) (position int64, err error) {
+
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+
+	position, exists := gs.positions[name]
+	if !exists {
+		return 0, ErrGroupNotExist
+	}
+
+	return position, nil
+}
+
+func (gs *groupStore) commit(name string, position int64) (err error) {
+
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+
+	previous, exists := gs.positions[name]
+
+	gs.positions[name] = position
+
+	err = gs.dump()
+	if err != nil {
+		// Keep memory consistent with the file.
+		if exists {
+			gs.positions[name] = previous
+		} else {
+			delete(gs.positions, name)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (gs *groupStore) delete(name string) (err error) {
+
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+
+	position, exists := gs.positions[name]
+	if !exists {
+		return ErrGroupNotExist
+	}
+
+	delete(gs.positions, name)
+
+	err = gs.dump()
+	if err != nil {
+		gs.positions[name] = position
+		return err
+	}
+
+	return nil
+}
+
+func (gs *groupStore) dump() (err error) {
+
+	size := 4 + 4
+	for name := range gs.positions {
+		size += 4 + len(name) + 8
+	}
+	size += 4
+
+	buffer := make([]byte, size)
+	n := 0
+
+	binary.BigEndian.PutUint32(buffer[n:n+4], uint32(groupsVersion))
+	n += 4
+
+	binary.BigEndian.PutUint32(buffer[n:n+4], uint32(len(gs.positions)))
+	n += 4
+
+	for name, position := range gs.positions {
+
+		binary.BigEndian.PutUint32(buffer[n:n+4], uint32(len(name)))
+		n += 4
+
+		n += copy(buffer[n:], name)
+
+		binary.BigEndian.PutUint64(buffer[n:n+8], uint64(position))
+		n += 8
+	}
+
+	crc := crc32.Checksum(buffer[:n], castagnoliTable)
+
+	binary.BigEndian.PutUint32(buffer[n:n+4], crc)
+
+	// Write to a temporary file first and rename it, so that a crash
+	// never leaves a partially written groups file behind.
+	tmpPathname := gs.pathname + ".tmp"
+
+	f, err := os.OpenFile(tmpPathname, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(groupsFilePerm))
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(buffer)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPathname, gs.pathname)
+	if err != nil {
+		return err
+	}
+
+	// Sync the log directory to persist the rename.
+	d, err := os.Open(filepath.Dir(gs.pathname))
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if err != nil {
+		d.Close()
+		return err
+	}
+
+	err = d.Close()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (gs *groupStore) load() (err error) {
+
+	buffer, err := ioutil.ReadFile(gs.pathname)
+	if err != nil {
+		return err
+	}
+
+	if len(buffer) < 4+4+4 {
+		return ErrCorruptGroups
+	}
+
+	end := len(buffer) - 4
+
+	crc := binary.BigEndian.Uint32(buffer[end:])
+	if crc != crc32.Checksum(buffer[:end], castagnoliTable) {
+		return ErrCorruptGroups
+	}
+
+	n := 0
+
+	version := int(binary.BigEndian.Uint32(buffer[n:]))
+	n += 4
+
+	if version != groupsVersion {
+		return ErrCorruptGroups
+	}
+
+	count := int(binary.BigEndian.Uint32(buffer[n:]))
+	n += 4
+
+	for i := 0; i < count; i++ {
+
+		if n+4 > end {
+			return ErrCorruptGroups
+		}
+
+		length := int(binary.BigEndian.Uint32(buffer[n:]))
+		n += 4
+
+		if length < 0 || n+length+8 > end {
+			return ErrCorruptGroups
+		}
+
+		name := string(buffer[n : n+length])
+		n += length
+
+		position := int64(binary.BigEndian.Uint64(buffer[n:]))
+		n += 8
+
+		gs.positions[name] = position
+	}
+
+	return nil
+}
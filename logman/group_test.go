@@ -0,0 +1,291 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logman
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/metrics"
+	"gitlab.com/dataptive/styx/recio"
+)
+
+var (
+	// Metrics collectors can only be registered once, so tests share a
+	// reporter.
+	testReporter, _ = metrics.NewMetricsReporter(metrics.Config{})
+)
+
+// Tests that committed positions are found back after reopening the groups
+// file.
+func TestGroupStore_DumpLoad(t *testing.T) {
+
+	path := t.TempDir()
+
+	gs, err := openGroupStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []GroupInfo{
+		{Name: "a", Position: 0},
+		{Name: "bb", Position: 42},
+		{Name: "ccc", Position: 1 << 40},
+	}
+
+	for _, group := range expected {
+		err = gs.commit(group.Name, group.Position)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = gs.commit("deleted", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = gs.delete("deleted")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gs, err = openGroupStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := gs.list()
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("groups should be %v but got %v", expected, got)
+	}
+}
+
+// Tests that a corrupt groups file is moved aside and groups are reset.
+func TestGroupStore_Corrupt(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		corrupt func(buffer []byte) []byte
+	}{
+		{
+			name: "truncated",
+			corrupt: func(buffer []byte) []byte {
+				return buffer[:len(buffer)-3]
+			},
+		},
+		{
+			name: "empty",
+			corrupt: func(buffer []byte) []byte {
+				return buffer[:0]
+			},
+		},
+		{
+			name: "flipped",
+			corrupt: func(buffer []byte) []byte {
+				buffer[9] ^= 0xff
+				return buffer
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			path := t.TempDir()
+
+			gs, err := openGroupStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = gs.commit("group", 42)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pathname := filepath.Join(path, groupsFilename)
+
+			buffer, err := ioutil.ReadFile(pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ioutil.WriteFile(pathname, test.corrupt(buffer), groupsFilePerm)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gs, err = openGroupStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			groups := gs.list()
+			if len(groups) != 0 {
+				t.Fatalf("groups should have been reset, got %v", groups)
+			}
+
+			_, err = os.Stat(filepath.Join(path, corruptGroupsFilename))
+			if err != nil {
+				t.Fatalf("corrupt groups file should have been kept: %v", err)
+			}
+
+			// Commits work again after the reset.
+			err = gs.commit("group", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gs, err = openGroupStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			position, err := gs.get("group")
+			if err != nil || position != 1 {
+				t.Fatalf("group should be at position 1, got %d (%v)", position, err)
+			}
+		})
+	}
+}
+
+// Tests that committed positions are kept within the log boundaries.
+func TestLog_CommittedPosition(t *testing.T) {
+
+	config := DefaultConfig
+	config.DataDirectory = t.TempDir()
+
+	lm, err := NewLogManager(config, testReporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ml, err := lm.CreateLog("test", log.DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fw, err := ml.NewWriter(recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, record := range testBatch_Records(10, 10) {
+		_, err = fw.Write(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = fw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ml.CommitGroup("group", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = log.Rollback(filepath.Join(config.DataDirectory, "test"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lm, err = NewLogManager(config, testReporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lm.Close()
+
+	ml, err = lm.GetLog("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	position, err := ml.CommittedPosition("group")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if position != 5 {
+		t.Fatalf("group should resume from the log end at position 5, got %d", position)
+	}
+}
+
+// Tests that a log with a corrupt groups file is still opened by the log
+// manager.
+func TestLogManager_CorruptGroups(t *testing.T) {
+
+	config := DefaultConfig
+	config.DataDirectory = t.TempDir()
+
+	lm, err := NewLogManager(config, testReporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ml, err := lm.CreateLog("test", log.DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ml.CommitGroup("group", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pathname := filepath.Join(config.DataDirectory, "test", groupsFilename)
+
+	err = ioutil.WriteFile(pathname, []byte("garbage"), groupsFilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lm, err = NewLogManager(config, testReporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lm.Close()
+
+	ml, err = lm.GetLog("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ml.Status() != StatusOK {
+		t.Fatalf("log should be available, got status %s", ml.Status())
+	}
+
+	groups := ml.ListGroups()
+	if len(groups) != 0 {
+		t.Fatalf("groups should have been reset, got %v", groups)
+	}
+}
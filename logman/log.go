@@ -13,9 +13,12 @@ import (
 	"io"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"sync"
+	"time"
 
 	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/metrics"
 	"gitlab.com/dataptive/styx/recio"
 )
@@ -32,8 +35,29 @@ const (
 
 var (
 	logNameRegexp = regexp.MustCompile(`^[a-zA-Z\d_\-]+$`)
+
+	lagReportInterval = 10 * time.Second
 )
 
+// repairTail truncates the torn tail of a log that failed to scan, and scans it
+// again once repaired. The scan error is returned when the tail isn't torn.
+func (ml *Log) repairTail(pathname string, scanErr error) (err error) {
+
+	tr, err := log.RepairTail(pathname)
+	if err != nil || !tr.Needed() {
+		return scanErr
+	}
+
+	logger.Warnf("logman: repaired log %s, truncated %d bytes after position %d", ml.name, tr.TruncatedBytes, tr.Position)
+
+	err = log.Scan(pathname)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 type LogInfo struct {
 	Name          string
 	Status        LogStatus
@@ -43,6 +67,21 @@ type LogInfo struct {
 	EndPosition   int64
 }
 
+// ReaderInfo describes a reader connected to a log. Lag is the count and size
+// of records between the reader position and the end of the log.
+type ReaderInfo struct {
+	Protocol      string
+	RemoteAddress string
+	Position      int64
+	Lag           int64
+	LagBytes      int64
+}
+
+type readerClient struct {
+	protocol      string
+	remoteAddress string
+}
+
 type Log struct {
 	path             string
 	name             string
@@ -53,10 +92,16 @@ type Log struct {
 	log              *log.Log
 	writer           *log.LogWriter
 	fanin            *log.Fanin
+	groups           *groupStore
 	lock             sync.RWMutex
 	reporter         metrics.Reporter
 	listenerChan	 chan log.Stat
 	listenerClose    chan struct{}
+	done             chan struct{}
+	lastStats        log.Stat
+	statsLock        sync.Mutex
+	readers          map[*log.LogReader]readerClient
+	readersLock      sync.Mutex
 }
 
 func (ml *Log) NewWriter(ioMode recio.IOMode) (fw *log.FaninWriter, err error) {
@@ -81,9 +126,86 @@ func (ml *Log) NewReader(follow bool, ioMode recio.IOMode) (lr *log.LogReader, e
 		return nil, err
 	}
 
+	lr.HandleRead(func(count int64, size int64) {
+		ml.reporter.ReportReads(ml.name, count, size)
+	})
+
 	return lr, nil
 }
 
+// TrackReader registers a reader serving a remote client, so that it is listed
+// with its lag until it is untracked.
+func (ml *Log) TrackReader(lr *log.LogReader, protocol string, remoteAddress string) {
+
+	ml.readersLock.Lock()
+	defer ml.readersLock.Unlock()
+
+	ml.readers[lr] = readerClient{
+		protocol:      protocol,
+		remoteAddress: remoteAddress,
+	}
+}
+
+func (ml *Log) UntrackReader(lr *log.LogReader) {
+
+	ml.readersLock.Lock()
+	defer ml.readersLock.Unlock()
+
+	delete(ml.readers, lr)
+}
+
+// ListReaders returns tracked readers ordered by remote address.
+func (ml *Log) ListReaders() (readerInfos []ReaderInfo, err error) {
+
+	if ml.Status() != StatusOK {
+		return nil, ErrUnavailable
+	}
+
+	stats := ml.log.Stat()
+
+	readerInfos = ml.readerInfos(stats)
+
+	sort.Slice(readerInfos, func(i, j int) bool {
+		return readerInfos[i].RemoteAddress < readerInfos[j].RemoteAddress
+	})
+
+	return readerInfos, nil
+}
+
+func (ml *Log) readerInfos(stats log.Stat) (readerInfos []ReaderInfo) {
+
+	ml.readersLock.Lock()
+	defer ml.readersLock.Unlock()
+
+	readerInfos = []ReaderInfo{}
+
+	for lr, client := range ml.readers {
+
+		position, offset := lr.Progress()
+
+		// Readers positioned after the end of a truncated log
+		// have no lag.
+		lag := stats.EndPosition - position
+		lagBytes := stats.EndOffset - offset
+		if lag < 0 || lagBytes < 0 {
+			lag = 0
+			lagBytes = 0
+		}
+
+		readerInfo := ReaderInfo{
+			Protocol:      client.protocol,
+			RemoteAddress: client.remoteAddress,
+			Position:      position,
+			Lag:           lag,
+			LagBytes:      lagBytes,
+		}
+
+		readerInfos = append(readerInfos, readerInfo)
+	}
+
+	return readerInfos
+}
+
 func (ml *Log) Status() (status LogStatus) {
 
 	ml.lock.RLock()
@@ -137,6 +259,177 @@ func (ml *Log) Backup(w io.Writer) (err error) {
 	return nil
 }
 
+// Copy creates a copy of the log at path.
+func (ml *Log) Copy(path string) (err error) {
+
+	if ml.Status() != StatusOK {
+		return ErrUnavailable
+	}
+
+	err = ml.log.Copy(path)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TruncateBefore deletes the segments of the log holding only records before
+// position.
+func (ml *Log) TruncateBefore(position int64) (err error) {
+
+	if ml.Status() != StatusOK {
+		return ErrUnavailable
+	}
+
+	err = ml.log.TruncateBefore(position)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TruncateBeforeTimestamp deletes the segments of the log holding only
+// records written before a Unix timestamp.
+func (ml *Log) TruncateBeforeTimestamp(timestamp int64) (err error) {
+
+	if ml.Status() != StatusOK {
+		return ErrUnavailable
+	}
+
+	err = ml.log.TruncateBeforeTimestamp(timestamp)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ml *Log) Config() (config log.Config, err error) {
+
+	if ml.Status() != StatusOK {
+		return config, ErrUnavailable
+	}
+
+	config = ml.log.Config()
+
+	return config, nil
+}
+
+// SetConfig replaces the log config without closing the log.
+func (ml *Log) SetConfig(config log.Config) (err error) {
+
+	if ml.Status() != StatusOK {
+		return ErrUnavailable
+	}
+
+	err = ml.log.SetConfig(config)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ml *Log) ListGroups() (groups []GroupInfo) {
+
+	groups = ml.groups.list()
+
+	return groups
+}
+
+func (ml *Log) GetGroup(name string) (groupInfo GroupInfo, err error) {
+
+	position, err := ml.groups.get(name)
+	if err != nil {
+		return groupInfo, err
+	}
+
+	groupInfo = GroupInfo{
+		Name:     name,
+		Position: position,
+	}
+
+	return groupInfo, nil
+}
+
+// CommitGroup stores position as the committed position of the group,
+// creating the group if needed. Position must lie between the log origin and
+// its end position.
+func (ml *Log) CommitGroup(name string, position int64) (groupInfo GroupInfo, err error) {
+
+	valid := logNameRegexp.MatchString(name)
+	if !valid {
+		return groupInfo, ErrInvalidGroupName
+	}
+
+	if ml.Status() != StatusOK {
+		return groupInfo, ErrUnavailable
+	}
+
+	stat := ml.log.Stat()
+
+	if position < 0 || position > stat.EndPosition {
+		return groupInfo, ErrInvalidPosition
+	}
+
+	err = ml.groups.commit(name, position)
+	if err != nil {
+		return groupInfo, err
+	}
+
+	groupInfo = GroupInfo{
+		Name:     name,
+		Position: position,
+	}
+
+	return groupInfo, nil
+}
+
+func (ml *Log) DeleteGroup(name string) (err error) {
+
+	err = ml.groups.delete(name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CommittedPosition returns the position readers of the group should resume
+// from. Groups without committed position, or whose committed position was
+// deleted by retention, resume from the first available record. Groups whose
+// committed position was rolled back, repaired or truncated away resume from
+// the log end.
+func (ml *Log) CommittedPosition(name string) (position int64, err error) {
+
+	if ml.Status() != StatusOK {
+		return 0, ErrUnavailable
+	}
+
+	stat := ml.log.Stat()
+
+	position, err = ml.groups.get(name)
+	if err == ErrGroupNotExist {
+		return stat.StartPosition, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if position < stat.StartPosition {
+		position = stat.StartPosition
+	}
+
+	if position > stat.EndPosition {
+		position = stat.EndPosition
+	}
+
+	return position, nil
+}
+
 func createLog(path, name string, config log.Config, options log.Options, readBufferSize int, writerBufferSize int, reporter metrics.Reporter) (ml *Log, err error) {
 
 	valid := logNameRegexp.MatchString(name)
@@ -154,6 +447,8 @@ func createLog(path, name string, config log.Config, options log.Options, readBu
 		reporter:         reporter,
 		listenerChan:     make(chan log.Stat, 1),
 		listenerClose:    make(chan struct{}),
+		done:             make(chan struct{}),
+		readers:          make(map[*log.LogReader]readerClient),
 	}
 
 	pathname := filepath.Join(path, name)
@@ -163,18 +458,33 @@ func createLog(path, name string, config log.Config, options log.Options, readBu
 		return nil, err
 	}
 
+	groups, err := openGroupStore(pathname)
+	if err != nil {
+		return nil, err
+	}
+
+	ml.groups = groups
+
 	writer, err := l.NewWriter(ml.writerBufferSize, recio.ModeAuto)
 	if err != nil {
 		return nil, err
 	}
 
+	writer.HandleSyncDuration(ml.reportSyncDuration)
+
+	fanin, err := log.NewFanin(writer)
+	if err != nil {
+		return nil, err
+	}
+
 	ml.status = StatusOK
 	ml.log = l
 	ml.writer = writer
-	ml.fanin = log.NewFanin(writer)
+	ml.fanin = fanin
 
 	stats := ml.log.Stat()
 	ml.reporter.ReportLogStats(ml.name, stats)
+	ml.resetStats(stats)
 
 	ml.log.Subscribe(ml.listenerChan)
 
@@ -200,10 +510,19 @@ func openLog(path, name string, options log.Options, readBufferSize int, writerB
 		reporter:         reporter,
 		listenerChan:     make(chan log.Stat, 1),
 		listenerClose:    make(chan struct{}),
+		done:             make(chan struct{}),
+		readers:          make(map[*log.LogReader]readerClient),
 	}
 
 	pathname := filepath.Join(path, name)
 
+	groups, err := openGroupStore(pathname)
+	if err != nil {
+		return nil, err
+	}
+
+	ml.groups = groups
+
 	l, err := log.Open(pathname, options)
 	if err != nil {
 
@@ -223,13 +542,31 @@ func openLog(path, name string, options log.Options, readBufferSize int, writerB
 		return nil, err
 	}
 
+	writer.HandleSyncDuration(ml.reportSyncDuration)
+
+	fanin, err := log.NewFanin(writer)
+	if err != nil {
+
+		writer.Close()
+		l.Close()
+
+		ml.status = StatusTainted
+
+		if err == log.ErrCorruptProducers {
+			ml.status = StatusCorrupt
+		}
+
+		return ml, nil
+	}
+
 	ml.status = StatusOK
 	ml.log = l
 	ml.writer = writer
-	ml.fanin = log.NewFanin(writer)
+	ml.fanin = fanin
 
 	stats := ml.log.Stat()
 	ml.reporter.ReportLogStats(ml.name, stats)
+	ml.resetStats(stats)
 
 	ml.log.Subscribe(ml.listenerChan)
 
@@ -264,21 +601,89 @@ func (ml *Log) close() (err error) {
 	ml.log.Unsubscribe(ml.listenerChan)
 	ml.listenerClose <- struct{}{}
 
+	close(ml.done)
+
 	return nil
 }
 
 func (ml *Log) metricsListener() {
 
+	ticker := time.NewTicker(lagReportInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ml.listenerClose:
+			return
 		case stats := <-ml.listenerChan:
-			ml.reporter.ReportLogStats(ml.name, stats)
+			ml.reportStats(stats)
+		case <-ticker.C:
+			ml.reportLag()
+		}
+	}
+}
+
+// reportLag reports the lag of the most lagging reader, against the last
+// reported stats.
+func (ml *Log) reportLag() {
+
+	ml.statsLock.Lock()
+	stats := ml.lastStats
+	ml.statsLock.Unlock()
+
+	lag := int64(0)
+	lagBytes := int64(0)
+
+	for _, readerInfo := range ml.readerInfos(stats) {
+
+		if readerInfo.Lag > lag {
+			lag = readerInfo.Lag
+		}
+
+		if readerInfo.LagBytes > lagBytes {
+			lagBytes = readerInfo.LagBytes
 		}
 	}
+
+	ml.reporter.ReportConsumerLag(ml.name, lag, lagBytes)
 }
 
-func (ml *Log) scan() {
+// reportStats reports stats, and the records written since the last reported
+// stats. Records are only appended, so the end position only moves back when
+// the log is truncated or rolled back.
+func (ml *Log) reportStats(stats log.Stat) {
+
+	ml.reporter.ReportLogStats(ml.name, stats)
+
+	ml.statsLock.Lock()
+	previous := ml.lastStats
+	ml.lastStats = stats
+	ml.statsLock.Unlock()
+
+	count := stats.EndPosition - previous.EndPosition
+	size := stats.EndOffset - previous.EndOffset
+
+	if count > 0 && size > 0 {
+		ml.reporter.ReportWrites(ml.name, count, size)
+	}
+}
+
+func (ml *Log) resetStats(stats log.Stat) {
+
+	ml.statsLock.Lock()
+	defer ml.statsLock.Unlock()
+
+	ml.lastStats = stats
+}
+
+func (ml *Log) reportSyncDuration(duration time.Duration) {
+
+	ml.reporter.ReportSyncDuration(ml.name, duration)
+}
+
+// scan checks the log after a crash and opens it again, truncating its torn
+// tail first when repairTail is set.
+func (ml *Log) scan(repairTail bool) {
 
 	pathname := filepath.Join(ml.path, ml.name)
 
@@ -314,6 +719,9 @@ func (ml *Log) scan() {
 
 	// Perform log scan.
 	err := log.Scan(pathname)
+	if err != nil && repairTail {
+		err = ml.repairTail(pathname, err)
+	}
 
 	ml.lock.Lock()
 	defer ml.lock.Unlock()
@@ -348,13 +756,31 @@ func (ml *Log) scan() {
 		return
 	}
 
+	writer.HandleSyncDuration(ml.reportSyncDuration)
+
+	fanin, err := log.NewFanin(writer)
+	if err != nil {
+
+		writer.Close()
+		l.Close()
+
+		ml.status = StatusTainted
+
+		if err == log.ErrCorruptProducers {
+			ml.status = StatusCorrupt
+		}
+
+		return
+	}
+
 	ml.status = StatusOK
 	ml.log = l
 	ml.writer = writer
-	ml.fanin = log.NewFanin(writer)
+	ml.fanin = fanin
 
 	stats := ml.log.Stat()
 	ml.reporter.ReportLogStats(ml.name, stats)
+	ml.resetStats(stats)
 
 	ml.log.Subscribe(ml.listenerChan)
 
@@ -25,14 +25,21 @@ var (
 	ErrNotExist    = errors.New("logman: log does not exist")
 	ErrUnavailable = errors.New("logman: log unavailable")
 	ErrInvalidName = errors.New("logman: invalid log name")
+	ErrSyncTimeout = errors.New("logman: sync timed out")
 )
 
 type LogManager struct {
-	config   Config
-	logs     []*Log
-	logsLock sync.Mutex
-	reporter metrics.Reporter
-	closed   bool
+	config       Config
+	logs         []*Log
+	logsLock     sync.Mutex
+	batchLock    sync.Mutex
+	reporter     metrics.Reporter
+	scrubberStop chan struct{}
+	closed       bool
+
+	// Start positions of the logs of failed batches that could not be
+	// rolled back, guarded by batchLock.
+	pendingRollbacks map[string]int64
 }
 
 func NewLogManager(config Config, reporter metrics.Reporter) (lm *LogManager, err error) {
@@ -42,9 +49,17 @@ func NewLogManager(config Config, reporter metrics.Reporter) (lm *LogManager, er
 	lm = &LogManager{
 		config: config,
 		reporter: reporter,
+		scrubberStop: make(chan struct{}),
 	}
 
-	names, err := listLogs(lm.config.DataDirectory)
+	pending, err := recoverBatch(lm.config.DataDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	lm.pendingRollbacks = pending
+
+	names, err := ListLogs(lm.config.DataDirectory)
 	if err != nil {
 		return nil, err
 	}
@@ -55,19 +70,37 @@ func NewLogManager(config Config, reporter metrics.Reporter) (lm *LogManager, er
 
 		ml, err := openLog(lm.config.DataDirectory, name, log.DefaultOptions, lm.config.ReadBufferSize, lm.config.WriteBufferSize, lm.reporter)
 		if err != nil {
-			return lm, err
+			// Release the logs opened so far.
+			lm.Close()
+			return nil, err
 		}
 
 		lm.logs = append(lm.logs, ml)
 
+		// Logs that could not be rolled back must not be written to.
+		_, found := pending[name]
+		if found {
+			err = ml.markTainted()
+			if err != nil {
+				lm.Close()
+				return nil, err
+			}
+
+			continue
+		}
+
 		if ml.Status() != StatusOK {
 
 			logger.Debugf("logman: scanning log %s", name)
 
-			go ml.scan()
+			go ml.scan(lm.config.RepairTail)
 		}
 	}
 
+	if lm.config.ScrubInterval > 0 {
+		go lm.scrubber()
+	}
+
 	return lm, nil
 }
 
@@ -82,6 +115,8 @@ func (lm *LogManager) Close() (err error) {
 		return nil
 	}
 
+	close(lm.scrubberStop)
+
 	for _, ml := range lm.logs {
 
 		err = ml.close()
@@ -95,6 +130,27 @@ func (lm *LogManager) Close() (err error) {
 	return nil
 }
 
+// Ready reports whether the log manager is open and no log is being scanned
+// after a crash.
+func (lm *LogManager) Ready() (ready bool) {
+
+	lm.logsLock.Lock()
+	defer lm.logsLock.Unlock()
+
+	if lm.closed {
+		return false
+	}
+
+	for _, ml := range lm.logs {
+
+		if ml.Status() == StatusScanning {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (lm *LogManager) ListLogs() (logs []*Log) {
 
 	lm.logsLock.Lock()
@@ -148,6 +204,9 @@ func (lm *LogManager) GetLog(name string) (ml *Log, err error) {
 
 func (lm *LogManager) DeleteLog(name string) (err error) {
 
+	lm.batchLock.Lock()
+	defer lm.batchLock.Unlock()
+
 	lm.logsLock.Lock()
 	defer lm.logsLock.Unlock()
 
@@ -189,11 +248,19 @@ func (lm *LogManager) DeleteLog(name string) (err error) {
 		return err
 	}
 
+	err = lm.clearPendingRollback(name)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func (lm *LogManager) TruncateLog(name string) (err error) {
 
+	lm.batchLock.Lock()
+	defer lm.batchLock.Unlock()
+
 	lm.logsLock.Lock()
 	defer lm.logsLock.Unlock()
 
@@ -235,6 +302,18 @@ func (lm *LogManager) TruncateLog(name string) (err error) {
 		return err
 	}
 
+	// Committed positions are meaningless once the log restarts from
+	// scratch.
+	err = deleteGroupStore(path)
+	if err != nil {
+		return err
+	}
+
+	err = lm.clearPendingRollback(name)
+	if err != nil {
+		return err
+	}
+
 	ml, err = openLog(lm.config.DataDirectory, name, log.DefaultOptions, lm.config.ReadBufferSize, lm.config.WriteBufferSize, lm.reporter)
 	if err != nil {
 		return err
@@ -245,6 +324,92 @@ func (lm *LogManager) TruncateLog(name string) (err error) {
 	return nil
 }
 
+// RepairLog truncates the torn tail of an unavailable log, rebuilds its damaged
+// indexes and opens it again.
+// With dryRun, the log is left untouched and the repair it needs is returned.
+// Available logs never need to be repaired.
+func (lm *LogManager) RepairLog(name string, dryRun bool) (tr log.TailRepair, err error) {
+
+	lm.batchLock.Lock()
+	defer lm.batchLock.Unlock()
+
+	lm.logsLock.Lock()
+	defer lm.logsLock.Unlock()
+
+	if lm.closed {
+		return tr, ErrClosed
+	}
+
+	// Repairing would make records of a failed batch available again.
+	_, found := lm.pendingRollbacks[name]
+	if found {
+		return tr, ErrUnavailable
+	}
+
+	pos := -1
+	for i, ml := range lm.logs {
+		if ml.name == name {
+			pos = i
+			break
+		}
+	}
+
+	if pos == -1 {
+		return tr, ErrNotExist
+	}
+
+	ml := lm.logs[pos]
+
+	status := ml.Status()
+
+	if status == StatusScanning {
+		return tr, ErrUnavailable
+	}
+
+	if status == StatusOK {
+		stat := ml.log.Stat()
+
+		tr = log.TailRepair{
+			Position: stat.EndPosition,
+			Offset:   stat.EndOffset,
+		}
+
+		return tr, nil
+	}
+
+	path := filepath.Join(lm.config.DataDirectory, name)
+
+	if dryRun {
+		return log.CheckTail(path)
+	}
+
+	tr, err = log.RepairTail(path)
+	if err != nil {
+		return tr, err
+	}
+
+	// Scanning rebuilds damaged indexes, and fails for logs corrupt
+	// elsewhere than in their tail, which must not be opened again.
+	err = log.Scan(path)
+	if err != nil {
+		return tr, log.ErrRepair
+	}
+
+	err = ml.close()
+	if err != nil {
+		return tr, err
+	}
+
+	ml, err = openLog(lm.config.DataDirectory, name, log.DefaultOptions, lm.config.ReadBufferSize, lm.config.WriteBufferSize, lm.reporter)
+	if err != nil {
+		return tr, err
+	}
+
+	lm.logs[pos] = ml
+
+	return tr, nil
+}
+
 func (lm *LogManager) RestoreLog(name string, r io.Reader) (err error) {
 
 	if lm.closed {
@@ -260,6 +425,11 @@ func (lm *LogManager) RestoreLog(name string, r io.Reader) (err error) {
 
 	err = log.Restore(pathname, r)
 	if err != nil {
+		// Don't leave a partially restored log behind.
+		if err != log.ErrExist {
+			log.Delete(pathname)
+		}
+
 		return err
 	}
 
@@ -280,7 +450,122 @@ func (lm *LogManager) RestoreLog(name string, r io.Reader) (err error) {
 	return nil
 }
 
-func listLogs(path string) (names []string, err error) {
+// RenameLog closes the log, moves it along with its consumer groups under
+// newName and opens it again.
+func (lm *LogManager) RenameLog(name string, newName string) (ml *Log, err error) {
+
+	lm.logsLock.Lock()
+	defer lm.logsLock.Unlock()
+
+	if lm.closed {
+		return nil, ErrClosed
+	}
+
+	valid := logNameRegexp.MatchString(newName)
+	if !valid {
+		return nil, ErrInvalidName
+	}
+
+	pos := -1
+	for i, current := range lm.logs {
+		if current.name == name {
+			pos = i
+			break
+		}
+	}
+
+	if pos == -1 {
+		return nil, ErrNotExist
+	}
+
+	ml = lm.logs[pos]
+
+	if ml.Status() != StatusOK {
+		return nil, ErrUnavailable
+	}
+
+	for _, current := range lm.logs {
+		if current.name == newName {
+			return nil, log.ErrExist
+		}
+	}
+
+	err = ml.close()
+	if err != nil {
+		return nil, err
+	}
+
+	pathname := filepath.Join(lm.config.DataDirectory, name)
+	newPathname := filepath.Join(lm.config.DataDirectory, newName)
+
+	err = log.Rename(pathname, newPathname)
+	if err != nil {
+		// Keep on serving the log under its current name.
+		previous, openErr := openLog(lm.config.DataDirectory, name, log.DefaultOptions, lm.config.ReadBufferSize, lm.config.WriteBufferSize, lm.reporter)
+		if openErr != nil {
+			return nil, openErr
+		}
+
+		lm.logs[pos] = previous
+
+		return nil, err
+	}
+
+	ml, err = openLog(lm.config.DataDirectory, newName, log.DefaultOptions, lm.config.ReadBufferSize, lm.config.WriteBufferSize, lm.reporter)
+	if err != nil {
+		return nil, err
+	}
+
+	lm.logs[pos] = ml
+
+	return ml, nil
+}
+
+// CopyLog creates a copy of the log under newName. Consumer groups are not
+// copied.
+func (lm *LogManager) CopyLog(name string, newName string) (ml *Log, err error) {
+
+	valid := logNameRegexp.MatchString(newName)
+	if !valid {
+		return nil, ErrInvalidName
+	}
+
+	source, err := lm.GetLog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	pathname := filepath.Join(lm.config.DataDirectory, newName)
+
+	err = source.Copy(pathname)
+	if err != nil {
+		// Don't leave a partial copy behind.
+		if err != log.ErrExist {
+			log.Delete(pathname)
+		}
+
+		return nil, err
+	}
+
+	lm.logsLock.Lock()
+	defer lm.logsLock.Unlock()
+
+	if lm.closed {
+		return nil, ErrClosed
+	}
+
+	ml, err = openLog(lm.config.DataDirectory, newName, log.DefaultOptions, lm.config.ReadBufferSize, lm.config.WriteBufferSize, lm.reporter)
+	if err != nil {
+		return nil, err
+	}
+
+	lm.logs = append(lm.logs, ml)
+
+	return ml, nil
+}
+
+// ListLogs returns the names of the logs stored in a data directory.
+func ListLogs(path string) (names []string, err error) {
 
 	pattern := path + "/*"
 
@@ -291,6 +576,12 @@ func listLogs(path string) (names []string, err error) {
 
 	for _, match := range matches {
 		_, filename := filepath.Split(match)
+
+		// Skip files that aren't logs, such as the batch journal.
+		if !logNameRegexp.MatchString(filename) {
+			continue
+		}
+
 		names = append(names, filename)
 	}
 
@@ -0,0 +1,127 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logman
+
+import (
+	"time"
+
+	"gitlab.com/dataptive/styx/logger"
+)
+
+// scrubber periodically scrubs the closed segments of all logs, one log after
+// the other, until the log manager is closed.
+func (lm *LogManager) scrubber() {
+
+	ticker := time.NewTicker(time.Duration(lm.config.ScrubInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			lm.scrub()
+		case <-lm.scrubberStop:
+			return
+		}
+	}
+}
+
+// scrub runs a scrubbing pass over all available logs, and makes the logs
+// found corrupt unavailable.
+func (lm *LogManager) scrub() {
+
+	lm.logsLock.Lock()
+	logs := make([]*Log, len(lm.logs))
+	copy(logs, lm.logs)
+	lm.logsLock.Unlock()
+
+	for _, ml := range logs {
+
+		corrupt := ml.scrub(lm.config.ScrubRate, lm.scrubberStop)
+		if !corrupt {
+			continue
+		}
+
+		lm.logsLock.Lock()
+
+		// Logs may have been closed, deleted or replaced in the
+		// meantime.
+		if !lm.closed && lm.contains(ml) {
+			err := ml.markCorrupt()
+			if err != nil {
+				logger.Warn(err)
+			}
+		}
+
+		lm.logsLock.Unlock()
+	}
+}
+
+func (lm *LogManager) contains(ml *Log) (contains bool) {
+
+	for _, other := range lm.logs {
+		if other == ml {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scrub checks the closed segments of the log, and reports whether corrupt
+// segments were found.
+func (ml *Log) scrub(rate int64, stop <-chan struct{}) (corrupt bool) {
+
+	ml.lock.RLock()
+	status := ml.status
+	l := ml.log
+	ml.lock.RUnlock()
+
+	if status != StatusOK {
+		return false
+	}
+
+	logger.Debugf("logman: scrubbing log %s", ml.name)
+
+	damaged, size, err := l.Scrub(rate, stop)
+	if err != nil {
+		logger.Warnf("logman: failed to scrub log %s (%s)", ml.name, err)
+		return false
+	}
+
+	ml.reporter.ReportScrub(ml.name, size, int64(len(damaged)))
+
+	for _, sr := range damaged {
+
+		err := sr.RecordsError
+		if err == nil {
+			err = sr.IndexError
+		}
+
+		logger.Errorf("logman: log %s has corrupt segment %s after position %d (%s)", ml.name, sr.Segment, sr.EndPosition, err)
+	}
+
+	return len(damaged) > 0
+}
+
+// markCorrupt closes the log and flags it corrupt, so that it isn't used
+// before being restored.
+func (ml *Log) markCorrupt() (err error) {
+
+	err = ml.close()
+	if err != nil {
+		return err
+	}
+
+	ml.lock.Lock()
+	ml.status = StatusCorrupt
+	ml.lock.Unlock()
+
+	return nil
+}
@@ -10,15 +10,45 @@
 package metrics
 
 import (
+	"time"
+
 	"gitlab.com/dataptive/styx/log"
 
 	"gitlab.com/dataptive/styx/metrics/prometheus"
 	"gitlab.com/dataptive/styx/metrics/statsd"
 )
 
+const (
+	ProtocolHTTP = "http"
+	ProtocolWS   = "ws"
+	ProtocolTCP  = "tcp"
+)
+
 type Reporter interface {
 	ReportLogStats(string, log.Stat) error
 
+	// ReportWrites and ReportReads report the count and size of records
+	// written to or read from a log since the last call.
+	ReportWrites(name string, count int64, size int64) error
+	ReportReads(name string, count int64, size int64) error
+
+	ReportSyncDuration(name string, duration time.Duration) error
+
+	// ReportConsumerLag reports the count and size of records the most
+	// lagging reader of a log has left to read.
+	ReportConsumerLag(name string, lag int64, lagBytes int64) error
+
+	// ReportProducers and ReportConsumers add delta to the count of
+	// connected producers or consumers using protocol.
+	ReportProducers(protocol string, delta int64) error
+	ReportConsumers(protocol string, delta int64) error
+
+	ReportRequest(route string, method string, status int) error
+
+	// ReportScrub reports the size of the closed segments of a log read by
+	// a scrubbing pass, and the count of corrupt segments it found.
+	ReportScrub(name string, size int64, corruptSegments int64) error
+
 	Close() error
 }
 
@@ -58,6 +88,78 @@ func (mp *MetricsReporter) ReportLogStats(name string, stats log.Stat) (err erro
 	return nil
 }
 
+func (mp *MetricsReporter) ReportWrites(name string, count int64, size int64) (err error) {
+
+	for _, reporter := range mp.reporters {
+		reporter.ReportWrites(name, count, size)
+	}
+
+	return nil
+}
+
+func (mp *MetricsReporter) ReportReads(name string, count int64, size int64) (err error) {
+
+	for _, reporter := range mp.reporters {
+		reporter.ReportReads(name, count, size)
+	}
+
+	return nil
+}
+
+func (mp *MetricsReporter) ReportSyncDuration(name string, duration time.Duration) (err error) {
+
+	for _, reporter := range mp.reporters {
+		reporter.ReportSyncDuration(name, duration)
+	}
+
+	return nil
+}
+
+func (mp *MetricsReporter) ReportConsumerLag(name string, lag int64, lagBytes int64) (err error) {
+
+	for _, reporter := range mp.reporters {
+		reporter.ReportConsumerLag(name, lag, lagBytes)
+	}
+
+	return nil
+}
+
+func (mp *MetricsReporter) ReportProducers(protocol string, delta int64) (err error) {
+
+	for _, reporter := range mp.reporters {
+		reporter.ReportProducers(protocol, delta)
+	}
+
+	return nil
+}
+
+func (mp *MetricsReporter) ReportConsumers(protocol string, delta int64) (err error) {
+
+	for _, reporter := range mp.reporters {
+		reporter.ReportConsumers(protocol, delta)
+	}
+
+	return nil
+}
+
+func (mp *MetricsReporter) ReportRequest(route string, method string, status int) (err error) {
+
+	for _, reporter := range mp.reporters {
+		reporter.ReportRequest(route, method, status)
+	}
+
+	return nil
+}
+
+func (mp *MetricsReporter) ReportScrub(name string, size int64, corruptSegments int64) (err error) {
+
+	for _, reporter := range mp.reporters {
+		reporter.ReportScrub(name, size, corruptSegments)
+	}
+
+	return nil
+}
+
 func (mp *MetricsReporter) Close() (err error) {
 
 	for _, reporter := range mp.reporters {
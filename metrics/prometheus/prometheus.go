@@ -10,14 +10,29 @@
 package prometheus
 
 import (
+	"strconv"
+	"time"
+
 	"gitlab.com/dataptive/styx/log"
 
 	prom "github.com/prometheus/client_golang/prometheus"
 )
 
 type PrometheusReporter struct {
-	logRecordCount *prom.GaugeVec
-	logFileSize    *prom.GaugeVec
+	logRecordCount      *prom.GaugeVec
+	logFileSize         *prom.GaugeVec
+	logWrittenRecords   *prom.CounterVec
+	logWrittenBytes     *prom.CounterVec
+	logReadRecords      *prom.CounterVec
+	logReadBytes        *prom.CounterVec
+	logSyncDuration     *prom.HistogramVec
+	logConsumerLag      *prom.GaugeVec
+	logConsumerLagBytes *prom.GaugeVec
+	activeProducers     *prom.GaugeVec
+	activeConsumers     *prom.GaugeVec
+	httpRequests        *prom.CounterVec
+	logScrubbedBytes    *prom.CounterVec
+	logCorruptSegments  *prom.GaugeVec
 }
 
 func NewPrometheusReporter() (pp *PrometheusReporter) {
@@ -38,12 +53,134 @@ func NewPrometheusReporter() (pp *PrometheusReporter) {
 		[]string{"log"},
 	)
 
+	logWrittenRecords := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "log_written_records_total",
+			Help: "Records written to the log",
+		},
+		[]string{"log"},
+	)
+
+	logWrittenBytes := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "log_written_bytes_total",
+			Help: "Bytes written to the log",
+		},
+		[]string{"log"},
+	)
+
+	logReadRecords := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "log_read_records_total",
+			Help: "Records read from the log",
+		},
+		[]string{"log"},
+	)
+
+	logReadBytes := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "log_read_bytes_total",
+			Help: "Bytes read from the log",
+		},
+		[]string{"log"},
+	)
+
+	logSyncDuration := prom.NewHistogramVec(
+		prom.HistogramOpts{
+			Name: "log_sync_duration_seconds",
+			Help: "Time spent syncing the log to disk",
+			// From 100µs to about 3s.
+			Buckets: prom.ExponentialBuckets(0.0001, 2, 16),
+		},
+		[]string{"log"},
+	)
+
+	logConsumerLag := prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Name: "log_consumer_lag_records",
+			Help: "Records left to read by the most lagging reader",
+		},
+		[]string{"log"},
+	)
+
+	logConsumerLagBytes := prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Name: "log_consumer_lag_bytes",
+			Help: "Bytes left to read by the most lagging reader",
+		},
+		[]string{"log"},
+	)
+
+	activeProducers := prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Name: "active_producers",
+			Help: "Current count of connected producers",
+		},
+		[]string{"protocol"},
+	)
+
+	activeConsumers := prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Name: "active_consumers",
+			Help: "Current count of connected consumers",
+		},
+		[]string{"protocol"},
+	)
+
+	httpRequests := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route, method and status code",
+		},
+		[]string{"route", "method", "status"},
+	)
+
+	logScrubbedBytes := prom.NewCounterVec(
+		prom.CounterOpts{
+			Name: "log_scrubbed_bytes_total",
+			Help: "Bytes of closed segments checked by scrubbing",
+		},
+		[]string{"log"},
+	)
+
+	logCorruptSegments := prom.NewGaugeVec(
+		prom.GaugeOpts{
+			Name: "log_corrupt_segments",
+			Help: "Corrupt segments found by the last scrubbing pass",
+		},
+		[]string{"log"},
+	)
+
 	prom.MustRegister(logRecordCount)
 	prom.MustRegister(logFileSize)
+	prom.MustRegister(logWrittenRecords)
+	prom.MustRegister(logWrittenBytes)
+	prom.MustRegister(logReadRecords)
+	prom.MustRegister(logReadBytes)
+	prom.MustRegister(logSyncDuration)
+	prom.MustRegister(logConsumerLag)
+	prom.MustRegister(logConsumerLagBytes)
+	prom.MustRegister(activeProducers)
+	prom.MustRegister(activeConsumers)
+	prom.MustRegister(httpRequests)
+	prom.MustRegister(logScrubbedBytes)
+	prom.MustRegister(logCorruptSegments)
 
 	pp = &PrometheusReporter{
-		logRecordCount: logRecordCount,
-		logFileSize:    logFileSize,
+		logRecordCount:      logRecordCount,
+		logFileSize:         logFileSize,
+		logWrittenRecords:   logWrittenRecords,
+		logWrittenBytes:     logWrittenBytes,
+		logReadRecords:      logReadRecords,
+		logReadBytes:        logReadBytes,
+		logSyncDuration:     logSyncDuration,
+		logConsumerLag:      logConsumerLag,
+		logConsumerLagBytes: logConsumerLagBytes,
+		activeProducers:     activeProducers,
+		activeConsumers:     activeConsumers,
+		httpRequests:        httpRequests,
+		logScrubbedBytes:    logScrubbedBytes,
+		logCorruptSegments:  logCorruptSegments,
 	}
 
 	return pp
@@ -68,3 +205,91 @@ func (pp *PrometheusReporter) ReportLogStats(name string, stats log.Stat) (err e
 
 	return nil
 }
+
+func (pp *PrometheusReporter) ReportWrites(name string, count int64, size int64) (err error) {
+
+	pp.logWrittenRecords.
+		With(prom.Labels{"log": name}).
+		Add(float64(count))
+
+	pp.logWrittenBytes.
+		With(prom.Labels{"log": name}).
+		Add(float64(size))
+
+	return nil
+}
+
+func (pp *PrometheusReporter) ReportReads(name string, count int64, size int64) (err error) {
+
+	pp.logReadRecords.
+		With(prom.Labels{"log": name}).
+		Add(float64(count))
+
+	pp.logReadBytes.
+		With(prom.Labels{"log": name}).
+		Add(float64(size))
+
+	return nil
+}
+
+func (pp *PrometheusReporter) ReportSyncDuration(name string, duration time.Duration) (err error) {
+
+	pp.logSyncDuration.
+		With(prom.Labels{"log": name}).
+		Observe(duration.Seconds())
+
+	return nil
+}
+
+func (pp *PrometheusReporter) ReportConsumerLag(name string, lag int64, lagBytes int64) (err error) {
+
+	pp.logConsumerLag.
+		With(prom.Labels{"log": name}).
+		Set(float64(lag))
+
+	pp.logConsumerLagBytes.
+		With(prom.Labels{"log": name}).
+		Set(float64(lagBytes))
+
+	return nil
+}
+
+func (pp *PrometheusReporter) ReportProducers(protocol string, delta int64) (err error) {
+
+	pp.activeProducers.
+		With(prom.Labels{"protocol": protocol}).
+		Add(float64(delta))
+
+	return nil
+}
+
+func (pp *PrometheusReporter) ReportConsumers(protocol string, delta int64) (err error) {
+
+	pp.activeConsumers.
+		With(prom.Labels{"protocol": protocol}).
+		Add(float64(delta))
+
+	return nil
+}
+
+func (pp *PrometheusReporter) ReportRequest(route string, method string, status int) (err error) {
+
+	pp.httpRequests.
+		With(prom.Labels{"route": route, "method": method, "status": strconv.Itoa(status)}).
+		Inc()
+
+	return nil
+}
+
+func (pp *PrometheusReporter) ReportScrub(name string, size int64, corruptSegments int64) (err error) {
+
+	pp.logScrubbedBytes.
+		With(prom.Labels{"log": name}).
+		Add(float64(size))
+
+	pp.logCorruptSegments.
+		With(prom.Labels{"log": name}).
+		Set(float64(corruptSegments))
+
+	return nil
+}
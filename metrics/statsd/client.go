@@ -56,10 +56,12 @@ func (c *Client) DecrCounter(name string, count int64) (err error) {
 	return nil
 }
 
-// Time send a statsd timing
+// Time send a statsd timing, with sub millisecond precision
 func (c *Client) Time(name string, duration time.Duration) (err error) {
 
-	return c.send(name, "%d|ms", int64(duration.Seconds() * 1000))
+	milliseconds := float64(duration) / float64(time.Millisecond)
+
+	err = c.send(name, "%g|ms", milliseconds)
 	if err != nil {
 		return err
 	}
@@ -78,6 +80,17 @@ func (c *Client) SetGauge(name string, value int64) (err error) {
 	return nil
 }
 
+// AddGauge add delta to a statsd gauge value
+func (c *Client) AddGauge(name string, delta int64) (err error) {
+
+	err = c.send(name, "%+d|g", delta)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (c *Client) send(stat string, format string, args ...interface{}) (err error) {
 
 	format = fmt.Sprintf("%s%s:%s\n", c.prefix, stat, format)
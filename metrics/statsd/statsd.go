@@ -11,14 +11,34 @@ package statsd
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/logger"
 )
 
 const (
-	recordCountPattern = "log.%s.record.count"
-	fileSizePattern    = "log.%s.file.size"
+	recordCountPattern      = "log.%s.record.count"
+	fileSizePattern         = "log.%s.file.size"
+	writtenRecordsPattern   = "log.%s.written.records"
+	writtenBytesPattern     = "log.%s.written.bytes"
+	readRecordsPattern      = "log.%s.read.records"
+	readBytesPattern        = "log.%s.read.bytes"
+	syncDurationPattern     = "log.%s.sync.duration"
+	consumerLagPattern      = "log.%s.consumer.lag.records"
+	consumerLagBytesPattern = "log.%s.consumer.lag.bytes"
+	producersPattern        = "producers.%s"
+	consumersPattern        = "consumers.%s"
+	requestsPattern         = "http.requests.%s.%s.%d"
+	scrubbedBytesPattern    = "log.%s.scrubbed.bytes"
+	corruptSegmentsPattern  = "log.%s.corrupt.segments"
+)
+
+var (
+	// routeReplacer turns route templates such as /logs/{name}/records
+	// into statsd name segments such as logs_name_records.
+	routeReplacer = strings.NewReplacer("/", "_", ".", "_", "{", "", "}", "")
 )
 
 type StatsdReporter struct {
@@ -71,3 +91,106 @@ func (sp *StatsdReporter) ReportLogStats(name string, stats log.Stat) (err error
 
 	return nil
 }
+
+func (sp *StatsdReporter) ReportWrites(name string, count int64, size int64) (err error) {
+
+	err = sp.client.IncrCounter(fmt.Sprintf(writtenRecordsPattern, name), count)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	err = sp.client.IncrCounter(fmt.Sprintf(writtenBytesPattern, name), size)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	return nil
+}
+
+func (sp *StatsdReporter) ReportReads(name string, count int64, size int64) (err error) {
+
+	err = sp.client.IncrCounter(fmt.Sprintf(readRecordsPattern, name), count)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	err = sp.client.IncrCounter(fmt.Sprintf(readBytesPattern, name), size)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	return nil
+}
+
+func (sp *StatsdReporter) ReportSyncDuration(name string, duration time.Duration) (err error) {
+
+	err = sp.client.Time(fmt.Sprintf(syncDurationPattern, name), duration)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	return nil
+}
+
+func (sp *StatsdReporter) ReportConsumerLag(name string, lag int64, lagBytes int64) (err error) {
+
+	err = sp.client.SetGauge(fmt.Sprintf(consumerLagPattern, name), lag)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	err = sp.client.SetGauge(fmt.Sprintf(consumerLagBytesPattern, name), lagBytes)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	return nil
+}
+
+func (sp *StatsdReporter) ReportProducers(protocol string, delta int64) (err error) {
+
+	err = sp.client.AddGauge(fmt.Sprintf(producersPattern, protocol), delta)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	return nil
+}
+
+func (sp *StatsdReporter) ReportConsumers(protocol string, delta int64) (err error) {
+
+	err = sp.client.AddGauge(fmt.Sprintf(consumersPattern, protocol), delta)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	return nil
+}
+
+func (sp *StatsdReporter) ReportRequest(route string, method string, status int) (err error) {
+
+	route = strings.Trim(routeReplacer.Replace(route), "_")
+	method = strings.ToLower(method)
+
+	err = sp.client.IncrCounter(fmt.Sprintf(requestsPattern, route, method, status), 1)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	return nil
+}
+
+func (sp *StatsdReporter) ReportScrub(name string, size int64, corruptSegments int64) (err error) {
+
+	err = sp.client.IncrCounter(fmt.Sprintf(scrubbedBytesPattern, name), size)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	err = sp.client.SetGauge(fmt.Sprintf(corruptSegmentsPattern, name), corruptSegments)
+	if err != nil {
+		logger.Warn("statsd:", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package pipeline
+
+import (
+	"regexp"
+)
+
+type Transform string
+
+const (
+	TransformFilter  Transform = "filter"  // Keep JSON records whose field at Path equals Value.
+	TransformProject Transform = "project" // Replace JSON records with their value at Path.
+	TransformMatch   Transform = "match"   // Keep records matching the Pattern regular expression.
+	TransformSample  Transform = "sample"  // Keep a Rate fraction of records.
+)
+
+var (
+	nameRegexp = regexp.MustCompile(`^[a-zA-Z\d_\-]+$`)
+)
+
+// Config describes a pipeline, which copies the records of the Source log
+// transformed by Transform to the Destination log. Only the settings of the
+// selected transform are used.
+type Config struct {
+	Name        string
+	Source      string
+	Destination string
+	Transform   Transform
+	Path        string
+	Value       string
+	Pattern     string
+	Rate        float64
+}
+
+// Validate checks the pipeline config and the settings of its transform.
+func (c Config) Validate() (err error) {
+
+	if !nameRegexp.MatchString(c.Name) {
+		return ErrInvalidName
+	}
+
+	if !nameRegexp.MatchString(c.Source) || !nameRegexp.MatchString(c.Destination) {
+		return ErrInvalidLog
+	}
+
+	if c.Source == c.Destination {
+		return ErrSameLog
+	}
+
+	_, err = newTransformer(c)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
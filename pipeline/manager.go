@@ -0,0 +1,252 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package pipeline
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/logman"
+)
+
+var (
+	ErrExist            = errors.New("pipeline: already exists")
+	ErrNotExist         = errors.New("pipeline: does not exist")
+	ErrStatic           = errors.New("pipeline: configured pipelines can't be deleted")
+	ErrInvalidName      = errors.New("pipeline: invalid name")
+	ErrInvalidLog       = errors.New("pipeline: invalid source or destination log")
+	ErrSameLog          = errors.New("pipeline: source and destination logs must differ")
+	ErrInvalidTransform = errors.New("pipeline: invalid transform")
+	ErrMissingPath      = errors.New("pipeline: missing path")
+	ErrInvalidPattern   = errors.New("pipeline: invalid pattern")
+	ErrInvalidRate      = errors.New("pipeline: invalid rate")
+	ErrCorruptPipelines = errors.New("pipeline: corrupt pipelines file")
+	ErrClosed           = errors.New("pipeline: closed")
+)
+
+type State string
+
+const (
+	StateStarting State = "starting"
+	StateRunning  State = "running"
+	StateFailed   State = "failed"
+)
+
+type PipelineInfo struct {
+	Config
+	Static   bool
+	State    State
+	Position int64
+	Lag      int64
+}
+
+// Manager runs pipelines, which continuously copy the records of a log to
+// another log through a transform. Pipelines are either configured statically
+// or created through the API, in which case they are persisted in the data
+// directory.
+type Manager struct {
+	manager   *logman.LogManager
+	store     *pipelineStore
+	pipelines map[string]*pipeline
+	closed    bool
+	lock      sync.Mutex
+}
+
+func NewManager(configs []Config, path string, manager *logman.LogManager) (m *Manager, err error) {
+
+	store, err := openPipelineStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m = &Manager{
+		manager:   manager,
+		store:     store,
+		pipelines: make(map[string]*pipeline),
+		closed:    false,
+		lock:      sync.Mutex{},
+	}
+
+	for _, config := range configs {
+
+		err = config.Validate()
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+
+		err = m.start(config, true)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+	}
+
+	for _, config := range store.list() {
+
+		err = m.start(config, false)
+		if err == ErrExist {
+			logger.Warnf("pipeline: pipeline %s is also configured, ignoring it", config.Name)
+			continue
+		}
+
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Manager) Close() (err error) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.closed {
+		return nil
+	}
+
+	m.closed = true
+
+	for _, p := range m.pipelines {
+		p.close()
+	}
+
+	return nil
+}
+
+// ListPipelines returns pipelines ordered by name.
+func (m *Manager) ListPipelines() (pipelineInfos []PipelineInfo) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	pipelineInfos = []PipelineInfo{}
+	for _, p := range m.pipelines {
+		pipelineInfos = append(pipelineInfos, p.stat())
+	}
+
+	sort.Slice(pipelineInfos, func(i, j int) bool {
+		return pipelineInfos[i].Name < pipelineInfos[j].Name
+	})
+
+	return pipelineInfos
+}
+
+func (m *Manager) GetPipeline(name string) (pipelineInfo PipelineInfo, err error) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	p, exists := m.pipelines[name]
+	if !exists {
+		return pipelineInfo, ErrNotExist
+	}
+
+	return p.stat(), nil
+}
+
+// CreatePipeline persists and starts a pipeline. Source and destination logs
+// don't need to exist yet, the pipeline waits for them.
+func (m *Manager) CreatePipeline(config Config) (pipelineInfo PipelineInfo, err error) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.closed {
+		return pipelineInfo, ErrClosed
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return pipelineInfo, err
+	}
+
+	_, exists := m.pipelines[config.Name]
+	if exists {
+		return pipelineInfo, ErrExist
+	}
+
+	err = m.store.add(config)
+	if err != nil {
+		return pipelineInfo, err
+	}
+
+	err = m.start(config, false)
+	if err != nil {
+		return pipelineInfo, err
+	}
+
+	return m.pipelines[config.Name].stat(), nil
+}
+
+// DeletePipeline stops a pipeline created through the API and deletes the
+// consumer group holding its position.
+func (m *Manager) DeletePipeline(name string) (err error) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	p, exists := m.pipelines[name]
+	if !exists {
+		return ErrNotExist
+	}
+
+	if p.static {
+		return ErrStatic
+	}
+
+	err = m.store.remove(name)
+	if err != nil {
+		return err
+	}
+
+	p.close()
+	delete(m.pipelines, name)
+
+	ml, err := m.manager.GetLog(p.config.Source)
+	if err != nil {
+		return nil
+	}
+
+	err = ml.DeleteGroup(p.groupName())
+	if err != nil && err != logman.ErrGroupNotExist {
+		logger.Warnf("pipeline: failed to delete pipeline %s position (%s)", name, err)
+	}
+
+	return nil
+}
+
+func (m *Manager) start(config Config, static bool) (err error) {
+
+	_, exists := m.pipelines[config.Name]
+	if exists {
+		return ErrExist
+	}
+
+	logger.Debugf("pipeline: starting pipeline %s (source=%s, destination=%s, transform=%s)", config.Name, config.Source, config.Destination, config.Transform)
+
+	p, err := newPipeline(config, static, m.manager)
+	if err != nil {
+		return err
+	}
+
+	m.pipelines[config.Name] = p
+
+	return nil
+}
@@ -0,0 +1,356 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package pipeline
+
+import (
+	"sync"
+	"time"
+
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/recio"
+)
+
+const (
+	// Pipelines commit their position as a consumer group of their source
+	// log, named after the pipeline.
+	groupPrefix = "pipeline-"
+
+	retryInterval = 5 * time.Second
+
+	// Pipeline readers are listed among source log readers with this
+	// protocol and the pipeline name as remote address.
+	readerProtocol = "pipeline"
+)
+
+// checkpoint is a source position whose records are written once the
+// destination writer synced count records.
+type checkpoint struct {
+	count    int64
+	position int64
+}
+
+// pipeline runs a single pipeline.
+type pipeline struct {
+	config      Config
+	static      bool
+	manager     *logman.LogManager
+	transform   transformer
+	state       State
+	reader      *log.LogReader
+	checkpoints []checkpoint
+	syncedCount int64
+	committed   int64
+	closed      bool
+	lock        sync.Mutex
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+func newPipeline(config Config, static bool, manager *logman.LogManager) (p *pipeline, err error) {
+
+	transform, err := newTransformer(config)
+	if err != nil {
+		return nil, err
+	}
+
+	p = &pipeline{
+		config:      config,
+		static:      static,
+		manager:     manager,
+		transform:   transform,
+		state:       StateStarting,
+		reader:      nil,
+		checkpoints: []checkpoint{},
+		syncedCount: 0,
+		committed:   -1,
+		closed:      false,
+		lock:        sync.Mutex{},
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p, nil
+}
+
+func (p *pipeline) close() {
+
+	p.lock.Lock()
+
+	p.closed = true
+	close(p.stop)
+
+	// Unblock a pending read.
+	if p.reader != nil {
+		p.reader.Close()
+	}
+
+	p.lock.Unlock()
+
+	<-p.done
+}
+
+func (p *pipeline) groupName() (name string) {
+
+	return groupPrefix + p.config.Name
+}
+
+func (p *pipeline) stat() (pipelineInfo PipelineInfo) {
+
+	p.lock.Lock()
+	state := p.state
+	p.lock.Unlock()
+
+	var position int64
+	var lag int64
+
+	ml, err := p.manager.GetLog(p.config.Source)
+	if err == nil {
+		position, err = ml.CommittedPosition(p.groupName())
+		if err == nil {
+			lag = ml.Stat().EndPosition - position
+		}
+	}
+
+	if lag < 0 {
+		lag = 0
+	}
+
+	pipelineInfo = PipelineInfo{
+		Config:   p.config,
+		Static:   p.static,
+		State:    state,
+		Position: position,
+		Lag:      lag,
+	}
+
+	return pipelineInfo
+}
+
+func (p *pipeline) setState(state State) {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.state = state
+}
+
+func (p *pipeline) setReader(reader *log.LogReader) (ok bool) {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		return false
+	}
+
+	p.reader = reader
+
+	return true
+}
+
+func (p *pipeline) run() {
+
+	for {
+		err := p.process()
+
+		select {
+		case <-p.stop:
+			close(p.done)
+			return
+		default:
+		}
+
+		p.setState(StateFailed)
+		logger.Warnf("pipeline: failed to run pipeline %s (%s)", p.config.Name, err)
+
+		select {
+		case <-p.stop:
+			close(p.done)
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// process copies transformed records from the committed position of the
+// source log to the destination log until an error occurs.
+func (p *pipeline) process() (err error) {
+
+	source, err := p.manager.GetLog(p.config.Source)
+	if err != nil {
+		return err
+	}
+
+	destination, err := p.manager.GetLog(p.config.Destination)
+	if err != nil {
+		return err
+	}
+
+	// The source log may have been rolled back behind the committed
+	// position, which then resumes from the log end.
+	position, err := source.CommittedPosition(p.groupName())
+	if err != nil {
+		return err
+	}
+
+	lr, err := source.NewReader(true, recio.ModeManual)
+	if err != nil {
+		return err
+	}
+	defer lr.Close()
+
+	source.TrackReader(lr, readerProtocol, p.config.Name)
+	defer source.UntrackReader(lr)
+
+	err = lr.Seek(position, log.SeekOrigin)
+	if err != nil {
+		return err
+	}
+
+	fw, err := destination.NewWriter(recio.ModeAuto)
+	if err != nil {
+		return err
+	}
+	// Closing waits for written records to be synced, which commits the
+	// last checkpoint.
+	defer fw.Close()
+
+	p.resetCheckpoints()
+
+	fw.HandleSync(func(syncProgress log.SyncProgress) {
+		p.synced(source, syncProgress.Count)
+	})
+
+	if !p.setReader(lr) {
+		return ErrClosed
+	}
+	defer p.setReader(nil)
+
+	p.setState(StateRunning)
+
+	written := int64(0)
+	record := &log.Record{}
+
+	for {
+		_, err = lr.Read(record)
+
+		if err == recio.ErrMustFill {
+
+			// Flush records before waiting for the next ones.
+			err = fw.Flush()
+			if err != nil {
+				return err
+			}
+
+			position, _ = lr.Tell()
+			p.addCheckpoint(source, written, position)
+
+			err = lr.Fill()
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		position, _ = lr.Tell()
+
+		if !p.transform(record, position-1) {
+			continue
+		}
+
+		_, err = fw.Write(record)
+
+		// Records dropped as duplicates by the destination are not
+		// synced.
+		if err == log.ErrDuplicate {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		written += 1
+	}
+}
+
+func (p *pipeline) resetCheckpoints() {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.checkpoints = []checkpoint{}
+	p.syncedCount = 0
+	p.committed = -1
+}
+
+func (p *pipeline) addCheckpoint(source *logman.Log, count int64, position int64) {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.checkpoints = append(p.checkpoints, checkpoint{
+		count:    count,
+		position: position,
+	})
+
+	p.commit(source)
+}
+
+func (p *pipeline) synced(source *logman.Log, count int64) {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.syncedCount = count
+
+	p.commit(source)
+}
+
+// commit commits the latest checkpoint whose records are synced to the
+// destination log.
+func (p *pipeline) commit(source *logman.Log) {
+
+	pos := -1
+	for i, c := range p.checkpoints {
+		if c.count > p.syncedCount {
+			break
+		}
+		pos = i
+	}
+
+	if pos == -1 {
+		return
+	}
+
+	position := p.checkpoints[pos].position
+	p.checkpoints = p.checkpoints[pos+1:]
+
+	if position == p.committed {
+		return
+	}
+
+	_, err := source.CommitGroup(p.groupName(), position)
+	if err != nil {
+		logger.Warnf("pipeline: failed to commit pipeline %s position (%s)", p.config.Name, err)
+		return
+	}
+
+	p.committed = position
+}
@@ -0,0 +1,289 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package pipeline
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// The pipelines file lives in the data directory. Its name isn't a
+	// valid log name so that it is never listed as a log.
+	pipelinesFilename = ".pipelines"
+	pipelinesVersion  = 0
+	pipelinesFilePerm = 0644
+)
+
+var (
+	castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// pipelineStore persists the pipelines created through the API.
+//
+// Pipelines are stored in the pipelines file of the data directory, which is
+// structured as follows.
+//
+//	+-------------------+-----------------+- - - - - - - - - -+----------------+
+//	|  version (int32)  |  count (int32)  |     pipelines     |  CRC (uint32)  |
+//	+-------------------+-----------------+- - - - - - - - - -+----------------+
+//
+// Each pipeline is encoded as its name, source, destination, transform, path,
+// value and pattern, each prefixed by its length (int32), followed by its rate
+// (float64 bits). The CRC32-C covers the whole file. The file is replaced
+// atomically on each change.
+type pipelineStore struct {
+	pathname string
+	configs  map[string]Config
+}
+
+func openPipelineStore(path string) (ps *pipelineStore, err error) {
+
+	ps = &pipelineStore{
+		pathname: filepath.Join(path, pipelinesFilename),
+		configs:  make(map[string]Config),
+	}
+
+	err = ps.load()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+func (ps *pipelineStore) list() (configs []Config) {
+
+	configs = []Config{}
+	for _, config := range ps.configs {
+		configs = append(configs, config)
+	}
+
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].Name < configs[j].Name
+	})
+
+	return configs
+}
+
+func (ps *pipelineStore) add(config Config) (err error) {
+
+	ps.configs[config.Name] = config
+
+	err = ps.dump()
+	if err != nil {
+		delete(ps.configs, config.Name)
+		return err
+	}
+
+	return nil
+}
+
+func (ps *pipelineStore) remove(name string) (err error) {
+
+	config, exists := ps.configs[name]
+	if !exists {
+		return nil
+	}
+
+	delete(ps.configs, name)
+
+	err = ps.dump()
+	if err != nil {
+		ps.configs[name] = config
+		return err
+	}
+
+	return nil
+}
+
+func configStrings(config Config) (values []string) {
+
+	values = []string{
+		config.Name,
+		config.Source,
+		config.Destination,
+		string(config.Transform),
+		config.Path,
+		config.Value,
+		config.Pattern,
+	}
+
+	return values
+}
+
+func (ps *pipelineStore) dump() (err error) {
+
+	size := 4 + 4
+	for _, config := range ps.configs {
+		for _, value := range configStrings(config) {
+			size += 4 + len(value)
+		}
+		size += 8
+	}
+	size += 4
+
+	buffer := make([]byte, size)
+	n := 0
+
+	binary.BigEndian.PutUint32(buffer[n:n+4], uint32(pipelinesVersion))
+	n += 4
+
+	binary.BigEndian.PutUint32(buffer[n:n+4], uint32(len(ps.configs)))
+	n += 4
+
+	for _, config := range ps.configs {
+
+		for _, value := range configStrings(config) {
+
+			binary.BigEndian.PutUint32(buffer[n:n+4], uint32(len(value)))
+			n += 4
+
+			n += copy(buffer[n:], value)
+		}
+
+		binary.BigEndian.PutUint64(buffer[n:n+8], math.Float64bits(config.Rate))
+		n += 8
+	}
+
+	crc := crc32.Checksum(buffer[:n], castagnoliTable)
+
+	binary.BigEndian.PutUint32(buffer[n:n+4], crc)
+
+	// Write to a temporary file first and rename it, so that a crash
+	// never leaves a partially written pipelines file behind.
+	tmpPathname := ps.pathname + ".tmp"
+
+	f, err := os.OpenFile(tmpPathname, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(pipelinesFilePerm))
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(buffer)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPathname, ps.pathname)
+	if err != nil {
+		return err
+	}
+
+	// Sync the data directory to persist the rename.
+	d, err := os.Open(filepath.Dir(ps.pathname))
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if err != nil {
+		d.Close()
+		return err
+	}
+
+	err = d.Close()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ps *pipelineStore) load() (err error) {
+
+	buffer, err := ioutil.ReadFile(ps.pathname)
+	if err != nil {
+		return err
+	}
+
+	if len(buffer) < 4+4+4 {
+		return ErrCorruptPipelines
+	}
+
+	end := len(buffer) - 4
+
+	crc := binary.BigEndian.Uint32(buffer[end:])
+	if crc != crc32.Checksum(buffer[:end], castagnoliTable) {
+		return ErrCorruptPipelines
+	}
+
+	n := 0
+
+	version := int(binary.BigEndian.Uint32(buffer[n:]))
+	n += 4
+
+	if version != pipelinesVersion {
+		return ErrCorruptPipelines
+	}
+
+	count := int(binary.BigEndian.Uint32(buffer[n:]))
+	n += 4
+
+	for i := 0; i < count; i++ {
+
+		values := make([]string, 7)
+
+		for j := range values {
+
+			if n+4 > end {
+				return ErrCorruptPipelines
+			}
+
+			length := int(binary.BigEndian.Uint32(buffer[n:]))
+			n += 4
+
+			if length < 0 || n+length > end {
+				return ErrCorruptPipelines
+			}
+
+			values[j] = string(buffer[n : n+length])
+			n += length
+		}
+
+		if n+8 > end {
+			return ErrCorruptPipelines
+		}
+
+		rate := math.Float64frombits(binary.BigEndian.Uint64(buffer[n:]))
+		n += 8
+
+		config := Config{
+			Name:        values[0],
+			Source:      values[1],
+			Destination: values[2],
+			Transform:   Transform(values[3]),
+			Path:        values[4],
+			Value:       values[5],
+			Pattern:     values[6],
+			Rate:        rate,
+		}
+
+		ps.configs[config.Name] = config
+	}
+
+	return nil
+}
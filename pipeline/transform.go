@@ -0,0 +1,118 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package pipeline
+
+import (
+	"encoding/json"
+	"math"
+
+	"gitlab.com/dataptive/styx/filter"
+	"gitlab.com/dataptive/styx/log"
+)
+
+// transformer transforms the record at position in place, and reports
+// whether it should be written to the destination log.
+type transformer func(r *log.Record, position int64) (keep bool)
+
+func newTransformer(config Config) (t transformer, err error) {
+
+	switch config.Transform {
+	case TransformFilter:
+		if config.Path == "" {
+			return nil, ErrMissingPath
+		}
+
+		f, err := filter.New(filter.Config{Path: config.Path, Value: config.Value})
+		if err != nil {
+			return nil, err
+		}
+
+		t = filterTransformer(f)
+
+	case TransformProject:
+		if config.Path == "" {
+			return nil, ErrMissingPath
+		}
+
+		t = projectTransformer(filter.SplitPath(config.Path))
+
+	case TransformMatch:
+		f, err := filter.New(filter.Config{Pattern: config.Pattern})
+		if err == filter.ErrInvalidPattern {
+			return nil, ErrInvalidPattern
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		t = filterTransformer(f)
+
+	case TransformSample:
+		if config.Rate <= 0 || config.Rate > 1 {
+			return nil, ErrInvalidRate
+		}
+
+		t = sampleTransformer(config.Rate)
+
+	default:
+		return nil, ErrInvalidTransform
+	}
+
+	return t, nil
+}
+
+func filterTransformer(f *filter.Filter) (t transformer) {
+
+	t = func(r *log.Record, position int64) (keep bool) {
+
+		return f.Match(r)
+	}
+
+	return t
+}
+
+func projectTransformer(path []string) (t transformer) {
+
+	t = func(r *log.Record, position int64) (keep bool) {
+
+		v, found := filter.Lookup(r.Payload, path)
+		if !found {
+			return false
+		}
+
+		payload, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+
+		r.Payload = payload
+
+		return true
+	}
+
+	return t
+}
+
+// sampleTransformer keeps records whose position crosses a multiple of
+// 1/rate, so that the same records are kept when a pipeline reads records
+// again after a restart.
+func sampleTransformer(rate float64) (t transformer) {
+
+	t = func(r *log.Record, position int64) (keep bool) {
+
+		current := math.Floor(float64(position) * rate)
+		next := math.Floor(float64(position+1) * rate)
+
+		return next > current
+	}
+
+	return t
+}
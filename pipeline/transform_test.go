@@ -0,0 +1,101 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package pipeline
+
+import (
+	"testing"
+
+	"gitlab.com/dataptive/styx/log"
+)
+
+// Tests that each transform keeps and rewrites the expected records.
+func TestTransformer(t *testing.T) {
+
+	payloads := []string{
+		`{"level": "error", "user": {"id": 42, "roles": ["admin"]}}`,
+		`{"level": "info", "user": {"id": 43}}`,
+		`{"level": 2}`,
+		`not json`,
+	}
+
+	tests := []struct {
+		config   Config
+		expected []string
+	}{
+		{
+			config:   Config{Transform: TransformFilter, Path: "level", Value: "error"},
+			expected: []string{payloads[0]},
+		},
+		{
+			config:   Config{Transform: TransformFilter, Path: "level", Value: "2"},
+			expected: []string{payloads[2]},
+		},
+		{
+			config:   Config{Transform: TransformProject, Path: "user.id"},
+			expected: []string{"42", "43"},
+		},
+		{
+			config:   Config{Transform: TransformProject, Path: "user.roles.0"},
+			expected: []string{`"admin"`},
+		},
+		{
+			config:   Config{Transform: TransformMatch, Pattern: `^not`},
+			expected: []string{payloads[3]},
+		},
+		{
+			config:   Config{Transform: TransformSample, Rate: 0.5},
+			expected: []string{payloads[1], payloads[3]},
+		},
+	}
+
+	for _, test := range tests {
+
+		transform, err := newTransformer(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kept := []string{}
+
+		for i, payload := range payloads {
+
+			r := &log.Record{Payload: []byte(payload)}
+
+			if transform(r, int64(i)) {
+				kept = append(kept, string(r.Payload))
+			}
+		}
+
+		if len(kept) != len(test.expected) {
+			t.Fatalf("%s transform kept %q, expected %q", test.config.Transform, kept, test.expected)
+		}
+
+		for i := range kept {
+			if kept[i] != test.expected[i] {
+				t.Fatalf("%s transform kept %q, expected %q", test.config.Transform, kept, test.expected)
+			}
+		}
+	}
+
+	invalid := []Config{
+		{Transform: "unknown"},
+		{Transform: TransformFilter},
+		{Transform: TransformMatch, Pattern: "(("},
+		{Transform: TransformSample, Rate: 1.5},
+	}
+
+	for _, config := range invalid {
+
+		_, err := newTransformer(config)
+		if err == nil {
+			t.Fatalf("transform %+v should be invalid", config)
+		}
+	}
+}
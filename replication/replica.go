@@ -0,0 +1,388 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package replication
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"gitlab.com/dataptive/styx/client"
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/recio"
+)
+
+// replica replicates a single leader log.
+type replica struct {
+	name           string
+	config         Config
+	manager        *logman.LogManager
+	client         *client.Client
+	writer         *log.FaninWriter
+	position       int64
+	state          ReplicaState
+	leaderPosition int64
+	consumer       *client.Consumer
+	closed         bool
+	lock           sync.Mutex
+	stop           chan struct{}
+	done           chan struct{}
+}
+
+func newReplica(name string, config Config, manager *logman.LogManager, client *client.Client) (rp *replica) {
+
+	rp = &replica{
+		name:           name,
+		config:         config,
+		manager:        manager,
+		client:         client,
+		writer:         nil,
+		position:       0,
+		state:          StateBootstrapping,
+		leaderPosition: 0,
+		consumer:       nil,
+		closed:         false,
+		lock:           sync.Mutex{},
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	go rp.run()
+
+	return rp
+}
+
+func (rp *replica) close() {
+
+	rp.lock.Lock()
+
+	rp.closed = true
+	close(rp.stop)
+
+	// Unblock a pending read.
+	if rp.consumer != nil {
+		rp.consumer.Close()
+	}
+
+	rp.lock.Unlock()
+
+	<-rp.done
+}
+
+func (rp *replica) stat() (replicaInfo ReplicaInfo) {
+
+	rp.lock.Lock()
+	state := rp.state
+	leaderPosition := rp.leaderPosition
+	rp.lock.Unlock()
+
+	var position int64
+
+	ml, err := rp.manager.GetLog(rp.name)
+	if err == nil {
+		position = ml.Stat().EndPosition
+	}
+
+	lag := leaderPosition - position
+	if lag < 0 {
+		lag = 0
+	}
+
+	replicaInfo = ReplicaInfo{
+		Name:           rp.name,
+		State:          state,
+		LeaderPosition: leaderPosition,
+		Position:       position,
+		Lag:            lag,
+	}
+
+	return replicaInfo
+}
+
+func (rp *replica) setLeaderPosition(position int64) {
+
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	rp.leaderPosition = position
+}
+
+// advanceLeaderPosition updates the leader position with the position of
+// records received, which can be more recent than the listed one.
+func (rp *replica) advanceLeaderPosition(position int64) {
+
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	if position > rp.leaderPosition {
+		rp.leaderPosition = position
+	}
+}
+
+func (rp *replica) setState(state ReplicaState) {
+
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	rp.state = state
+}
+
+func (rp *replica) setConsumer(consumer *client.Consumer) (ok bool) {
+
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	if rp.closed {
+		return false
+	}
+
+	rp.consumer = consumer
+
+	return true
+}
+
+func (rp *replica) run() {
+
+	interval := time.Duration(rp.config.SyncInterval) * time.Second
+
+	for {
+		err := rp.replicate()
+
+		select {
+		case <-rp.stop:
+			close(rp.done)
+			return
+		default:
+		}
+
+		rp.setState(StateFailed)
+		logger.Warnf("replication: failed to replicate log %s (%s)", rp.name, err)
+
+		select {
+		case <-rp.stop:
+			close(rp.done)
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// replicate bootstraps the local log if needed and writes records read from
+// the leader until an error occurs.
+func (rp *replica) replicate() (err error) {
+
+	err = rp.openWriter()
+	if err != nil {
+		return err
+	}
+	defer rp.closeWriter()
+
+	logInfo, err := rp.client.GetLog(rp.name)
+	if err != nil {
+		return err
+	}
+
+	// Records not replicated yet were truncated or expired on the leader,
+	// or the local log is ahead of the leader. Start over from a fresh
+	// backup of the leader log.
+	if logInfo.StartPosition > rp.position || logInfo.EndPosition < rp.position {
+		return rp.diverge()
+	}
+
+	params := client.ConsumerParams{
+		Whence:   client.SeekOrigin,
+		Position: rp.position,
+		Count:    -1,
+		Follow:   true,
+	}
+
+	options := client.DefaultConsumerOptions
+	options.IOMode = recio.ModeManual
+
+	consumer, err := rp.client.NewConsumer(rp.name, params, options)
+	if err != nil {
+		return err
+	}
+	defer consumer.Close()
+
+	if !rp.setConsumer(consumer) {
+		return ErrClosed
+	}
+	defer rp.setConsumer(nil)
+
+	// Seeking past records removed by compaction on the leader moves the
+	// consumer to the next remaining record.
+	if consumer.Tell() > rp.position {
+		err = rp.skip(consumer.Tell())
+		if err != nil {
+			return err
+		}
+	}
+
+	rp.setState(StateFollowing)
+
+	record := &log.Record{}
+
+	for {
+		_, err = consumer.Read(record)
+
+		if err == recio.ErrMustFill {
+
+			// Flush records before waiting for the next ones.
+			err = rp.writer.Flush()
+			if err != nil {
+				return err
+			}
+
+			rp.advanceLeaderPosition(consumer.Tell())
+
+			err = consumer.Fill()
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// Records removed by compaction on the leader leave gaps in
+		// positions, which are skipped locally. Records sent again
+		// mean the logs diverged.
+		position := consumer.Tell() - 1
+
+		if position < rp.position {
+			return rp.diverge()
+		}
+
+		if position > rp.position {
+			err = rp.skip(position)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = rp.writer.Write(record)
+		if err != nil {
+			return err
+		}
+
+		rp.position += 1
+	}
+}
+
+// skip moves the local log end to position, leaving a gap for records removed
+// by compaction on the leader.
+func (rp *replica) skip(position int64) (err error) {
+
+	err = rp.writer.Skip(position)
+	if err != nil {
+		return err
+	}
+
+	rp.position = position
+
+	return nil
+}
+
+// diverge deletes the local log, so that it is bootstrapped again from a
+// backup of the leader log.
+func (rp *replica) diverge() (err error) {
+
+	logger.Warnf("replication: log %s diverged from leader, bootstrapping again", rp.name)
+
+	rp.closeWriter()
+
+	err = rp.manager.DeleteLog(rp.name)
+	if err != nil && err != logman.ErrNotExist {
+		return err
+	}
+
+	return ErrDiverged
+}
+
+// openWriter bootstraps the local log from a backup of the leader log when it
+// does not exist yet, and opens a writer on it.
+func (rp *replica) openWriter() (err error) {
+
+	ml, err := rp.manager.GetLog(rp.name)
+	if err == logman.ErrNotExist {
+		err = rp.bootstrap()
+		if err != nil {
+			return err
+		}
+
+		ml, err = rp.manager.GetLog(rp.name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	writer, err := ml.NewWriter(recio.ModeAuto)
+	if err != nil {
+		return err
+	}
+
+	rp.writer = writer
+	rp.position = ml.Stat().EndPosition
+
+	return nil
+}
+
+func (rp *replica) closeWriter() {
+
+	if rp.writer == nil {
+		return
+	}
+
+	// Buffered records are valid leader records. Closing waits for them to
+	// be synced, so that the next writer starts at the local log end.
+	err := rp.writer.Flush()
+	if err != nil {
+		logger.Debug(err)
+	}
+
+	err = rp.writer.Close()
+	if err != nil {
+		logger.Debug(err)
+	}
+
+	rp.writer = nil
+}
+
+func (rp *replica) bootstrap() (err error) {
+
+	logger.Debugf("replication: bootstrapping log %s", rp.name)
+
+	rp.setState(StateBootstrapping)
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		err := rp.client.BackupLog(rp.name, pw)
+		pw.CloseWithError(err)
+	}()
+
+	err = rp.manager.RestoreLog(rp.name, pr)
+
+	// Unblock the backup if the restore stopped reading early.
+	pr.Close()
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
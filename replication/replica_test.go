@@ -0,0 +1,271 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package replication_test
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
+	"gitlab.com/dataptive/styx/recio"
+	"gitlab.com/dataptive/styx/replication"
+	"gitlab.com/dataptive/styx/server"
+	"gitlab.com/dataptive/styx/server/config"
+)
+
+// Tests that a follower keeps the positions of the leader log when records
+// it did not replicate yet are removed by compaction on the leader.
+func TestReplica_CompactedLeader(t *testing.T) {
+
+	reporter, err := metrics.NewMetricsReporter(metrics.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaderDirectory := t.TempDir()
+	followerDirectory := t.TempDir()
+
+	leader, leaderServer := testReplica_StartLeader(t, leaderDirectory, reporter)
+
+	logConfig := log.DefaultConfig
+	logConfig.SegmentMaxCount = 10
+	logConfig.LogCompaction = true
+
+	ml, err := leader.CreateLog("test", logConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testReplica_Write(t, ml, 0, 5)
+
+	followerConfig := logman.DefaultConfig
+	followerConfig.DataDirectory = followerDirectory
+
+	follower, err := logman.NewLogManager(followerConfig, reporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer follower.Close()
+
+	replicator := testReplica_StartFollower(t, follower, leaderServer.URL)
+
+	testReplica_WaitSynced(t, leader, follower)
+
+	err = replicator.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write more records while the follower is stopped, and compact the
+	// closed segments of the leader, removing records not replicated yet.
+	testReplica_Write(t, ml, 5, 35)
+
+	leaderServer.Close()
+
+	err = leader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := log.Open(filepath.Join(leaderDirectory, "test"), log.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leader, leaderServer = testReplica_StartLeader(t, leaderDirectory, reporter)
+	defer leader.Close()
+	defer leaderServer.Close()
+
+	replicator = testReplica_StartFollower(t, follower, leaderServer.URL)
+	defer replicator.Close()
+
+	records := testReplica_WaitSynced(t, leader, follower)
+
+	_, found := records[9]
+	if !found || len(records) >= 35 {
+		t.Fatalf("leader log should have kept records without key, and compacted others, got %v", records)
+	}
+
+	// The follower should have skipped the gap, rather than bootstrapping
+	// again from a backup holding the compacted segments of the leader.
+	segments, err := log.ListSegments(filepath.Join(followerDirectory, "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, si := range segments {
+		if si.Generation != 0 {
+			t.Fatalf("follower log should not have been bootstrapped again, got segments %v", segments)
+		}
+	}
+}
+
+func testReplica_StartLeader(t *testing.T, path string, reporter metrics.Reporter) (lm *logman.LogManager, s *httptest.Server) {
+
+	lmConfig := logman.DefaultConfig
+	lmConfig.DataDirectory = path
+
+	lm, err := logman.NewLogManager(lmConfig, reporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConfig := config.Config{
+		HTTPReadBufferSize:  1 << 20,
+		HTTPWriteBufferSize: 1 << 20,
+		TCPReadBufferSize:   1 << 20,
+		TCPWriteBufferSize:  1 << 20,
+		TCPTimeout:          10,
+		LogManager:          lmConfig,
+	}
+
+	router := server.NewRouter(lm, nil, nil, reporter, nil, serverConfig)
+
+	s = httptest.NewServer(router)
+
+	return lm, s
+}
+
+func testReplica_StartFollower(t *testing.T, lm *logman.LogManager, leaderAddress string) (r *replication.Replicator) {
+
+	replicationConfig := replication.DefaultConfig
+	replicationConfig.LeaderAddress = leaderAddress
+	replicationConfig.SyncInterval = 1
+
+	r, err := replication.NewReplicator(replicationConfig, lm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+// testReplica_Write writes records to the leader log. Records from position
+// 10 have one of 3 keys, so that compaction removes most of them, leaving a
+// gap after the first 10 records.
+func testReplica_Write(t *testing.T, ml *logman.Log, from int, to int) {
+
+	fw, err := ml.NewWriter(recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := from; i < to; i++ {
+
+		record := &log.Record{
+			Key:     nil,
+			Payload: []byte(fmt.Sprintf("record-%d", i)),
+		}
+
+		if i >= 10 {
+			record.Key = []byte(fmt.Sprintf("key-%d", i%3))
+		}
+
+		_, err = fw.Write(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = fw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testReplica_WaitSynced waits for the follower log to hold the same records
+// at the same positions as the leader log, and returns them.
+func testReplica_WaitSynced(t *testing.T, leader *logman.LogManager, follower *logman.LogManager) (records map[int64]string) {
+
+	expected := testReplica_Read(t, leader)
+
+	var got map[int64]string
+
+	deadline := time.Now().Add(20 * time.Second)
+
+	for time.Now().Before(deadline) {
+
+		_, err := follower.GetLog("test")
+		if err == nil {
+			got = testReplica_Read(t, follower)
+
+			if reflect.DeepEqual(got, expected) {
+				return expected
+			}
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	t.Fatalf("follower should have records %v but got %v", expected, got)
+
+	return nil
+}
+
+// testReplica_Read returns the payloads of the records of a log by position.
+func testReplica_Read(t *testing.T, lm *logman.LogManager) (records map[int64]string) {
+
+	ml, err := lm.GetLog("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lr, err := ml.NewReader(false, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+
+	err = lr.Seek(0, log.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records = map[int64]string{}
+
+	record := &log.Record{}
+	for {
+		_, err = lr.Read(record)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		position, _ := lr.Tell()
+		records[position-1] = string(record.Payload)
+	}
+
+	return records
+}
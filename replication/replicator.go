@@ -0,0 +1,190 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package replication
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.com/dataptive/styx/client"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/logman"
+)
+
+var (
+	ErrDiverged = errors.New("replication: log diverged from leader")
+	ErrClosed   = errors.New("replication: closed")
+)
+
+type ReplicaState string
+
+const (
+	StateBootstrapping ReplicaState = "bootstrapping"
+	StateFollowing     ReplicaState = "following"
+	StateFailed        ReplicaState = "failed"
+)
+
+type ReplicaInfo struct {
+	Name           string
+	State          ReplicaState
+	LeaderPosition int64
+	Position       int64
+	Lag            int64
+}
+
+// Replicator makes the logs of a log manager follow the logs of a leader
+// Styx server. Logs missing locally are bootstrapped from a backup of the
+// leader log, then tailed over the Styx protocol, so that records are written
+// byte for byte with the same positions as on the leader.
+type Replicator struct {
+	config   Config
+	manager  *logman.LogManager
+	client   *client.Client
+	replicas map[string]*replica
+	lock     sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewReplicator(config Config, manager *logman.LogManager) (r *Replicator, err error) {
+
+	logger.Debugf("replication: starting replicator (leader_address=%s)", config.LeaderAddress)
+
+	tlsConfig, err := client.NewTLSConfig(config.LeaderCAFile, config.LeaderCertFile, config.LeaderKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	leaderClient := client.NewClient(config.LeaderAddress)
+	leaderClient.SetToken(config.LeaderToken)
+	leaderClient.SetTLSConfig(tlsConfig)
+
+	r = &Replicator{
+		config:   config,
+		manager:  manager,
+		client:   leaderClient,
+		replicas: make(map[string]*replica),
+		lock:     sync.Mutex{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go r.run()
+
+	return r, nil
+}
+
+func (r *Replicator) Close() (err error) {
+
+	logger.Debugf("replication: closing replicator")
+
+	close(r.stop)
+	<-r.done
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, rp := range r.replicas {
+		rp.close()
+	}
+
+	return nil
+}
+
+func (r *Replicator) LeaderAddress() (address string) {
+
+	return r.config.LeaderAddress
+}
+
+// Stat returns the replication progress of each log, ordered by name.
+func (r *Replicator) Stat() (replicaInfos []ReplicaInfo) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	replicaInfos = []ReplicaInfo{}
+	for _, rp := range r.replicas {
+		replicaInfos = append(replicaInfos, rp.stat())
+	}
+
+	sort.Slice(replicaInfos, func(i, j int) bool {
+		return replicaInfos[i].Name < replicaInfos[j].Name
+	})
+
+	return replicaInfos
+}
+
+func (r *Replicator) run() {
+
+	ticker := time.NewTicker(time.Duration(r.config.SyncInterval) * time.Second)
+
+	r.sync()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.sync()
+		case <-r.stop:
+			ticker.Stop()
+			close(r.done)
+			return
+		}
+	}
+}
+
+// sync starts replicating logs created on the leader, deletes logs deleted
+// on the leader, and refreshes leader positions.
+func (r *Replicator) sync() {
+
+	logs, err := r.client.ListLogs()
+	if err != nil {
+		logger.Warnf("replication: failed to list leader logs (%s)", err)
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	leaderLogs := make(map[string]bool)
+
+	for _, logInfo := range logs {
+
+		leaderLogs[logInfo.Name] = true
+
+		rp, exists := r.replicas[logInfo.Name]
+		if !exists {
+			logger.Debugf("replication: replicating log %s", logInfo.Name)
+
+			rp = newReplica(logInfo.Name, r.config, r.manager, r.client)
+			r.replicas[logInfo.Name] = rp
+		}
+
+		rp.setLeaderPosition(logInfo.EndPosition)
+	}
+
+	for name, rp := range r.replicas {
+
+		if leaderLogs[name] {
+			continue
+		}
+
+		logger.Debugf("replication: log %s was deleted on leader", name)
+
+		rp.close()
+		delete(r.replicas, name)
+
+		err = r.manager.DeleteLog(name)
+		if err != nil && err != logman.ErrNotExist {
+			logger.Warnf("replication: failed to delete log %s (%s)", name, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	authorizationHeaderName = "Authorization"
+	bearerPrefix            = "Bearer "
+
+	// Browsers can't set headers on WebSocket connections, tokens can
+	// be passed as a query param instead.
+	tokenParamName = "access_token"
+
+	// Routes that are not specific to a log require a token scoped to
+	// all logs.
+	allLogsPattern = "*"
+)
+
+var (
+	ErrMissingToken      = errors.New("auth: missing token")
+	ErrInvalidToken      = errors.New("auth: invalid token")
+	ErrInvalidPermission = errors.New("auth: invalid permission")
+	ErrInvalidPattern    = errors.New("auth: invalid log name pattern")
+)
+
+type Permission string
+
+const (
+	PermissionRead  Permission = "read"  // Read records and commit consumer groups positions.
+	PermissionWrite Permission = "write" // Write records and delete consumer groups.
+	PermissionAdmin Permission = "admin" // Create, update, delete, truncate, rename, copy and restore logs.
+)
+
+// Each permission includes the lower ones.
+var permissionLevels = map[Permission]int{
+	PermissionRead:  1,
+	PermissionWrite: 2,
+	PermissionAdmin: 3,
+}
+
+type Config struct {
+	Tokens []TokenConfig
+}
+
+type TokenConfig struct {
+	Token      string
+	Permission Permission
+	Logs       []string // Glob patterns of the log names the token applies to.
+}
+
+type contextKey struct{}
+
+// Token holds the permission granted to a request.
+type Token struct {
+	permission Permission
+	logs       []string
+}
+
+type Authenticator struct {
+	tokens []TokenConfig
+}
+
+// Validate checks that token permissions and log name patterns are valid.
+func (c Config) Validate() (err error) {
+
+	for _, tc := range c.Tokens {
+
+		_, valid := permissionLevels[tc.Permission]
+		if !valid {
+			return ErrInvalidPermission
+		}
+
+		for _, pattern := range tc.Logs {
+			_, err = path.Match(pattern, "")
+			if err != nil {
+				return ErrInvalidPattern
+			}
+		}
+	}
+
+	return nil
+}
+
+func NewAuthenticator(config Config) (a *Authenticator) {
+
+	a = &Authenticator{
+		tokens: config.Tokens,
+	}
+
+	return a
+}
+
+// Authenticate returns the token matching the bearer token of the request
+// Authorization header, or its access_token query param.
+func (a *Authenticator) Authenticate(r *http.Request) (t *Token, err error) {
+
+	secret := ""
+
+	header := r.Header.Get(authorizationHeaderName)
+	if strings.HasPrefix(header, bearerPrefix) {
+		secret = strings.TrimPrefix(header, bearerPrefix)
+	}
+
+	if secret == "" {
+		secret = r.URL.Query().Get(tokenParamName)
+	}
+
+	if secret == "" {
+		return nil, ErrMissingToken
+	}
+
+	// Compare all tokens in constant time to avoid leaking them through
+	// response timings.
+	var match *TokenConfig
+	for i, tc := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(tc.Token), []byte(secret)) == 1 {
+			match = &a.tokens[i]
+		}
+	}
+
+	if match == nil {
+		return nil, ErrInvalidToken
+	}
+
+	t = &Token{
+		permission: match.Permission,
+		logs:       match.Logs,
+	}
+
+	return t, nil
+}
+
+// Allows reports whether the token grants permission on the log name.
+func (t *Token) Allows(permission Permission, name string) (allowed bool) {
+
+	if permissionLevels[t.permission] < permissionLevels[permission] {
+		return false
+	}
+
+	for _, pattern := range t.logs {
+		matched, _ := path.Match(pattern, name)
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsAll reports whether the token grants permission on all logs.
+func (t *Token) AllowsAll(permission Permission) (allowed bool) {
+
+	if permissionLevels[t.permission] < permissionLevels[permission] {
+		return false
+	}
+
+	for _, pattern := range t.logs {
+		if pattern == allLogsPattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewContext(ctx context.Context, t *Token) (c context.Context) {
+
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the token of an authenticated request, or nil when
+// authentication is disabled.
+func FromContext(ctx context.Context) (t *Token) {
+
+	t, _ = ctx.Value(contextKey{}).(*Token)
+
+	return t
+}
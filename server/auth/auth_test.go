@@ -0,0 +1,189 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package auth
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+var testConfig = Config{
+	Tokens: []TokenConfig{
+		{Token: "reader", Permission: PermissionRead, Logs: []string{"*"}},
+		{Token: "writer", Permission: PermissionWrite, Logs: []string{"events-*", "metrics"}},
+		{Token: "admin", Permission: PermissionAdmin, Logs: []string{"*"}},
+	},
+}
+
+// Tests that tokens are found from the Authorization header or the
+// access_token query param.
+func TestAuthenticator_Authenticate(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		header     string
+		query      string
+		permission Permission
+		err        error
+	}{
+		{name: "bearer", header: "Bearer reader", permission: PermissionRead},
+		{name: "query param", query: "?access_token=writer", permission: PermissionWrite},
+		{name: "header first", header: "Bearer admin", query: "?access_token=reader", permission: PermissionAdmin},
+		{name: "missing", err: ErrMissingToken},
+		{name: "empty bearer", header: "Bearer ", err: ErrMissingToken},
+		{name: "not bearer", header: "Basic reader", err: ErrMissingToken},
+		{name: "lowercase bearer", header: "bearer reader", err: ErrMissingToken},
+		{name: "unknown", header: "Bearer unknown", err: ErrInvalidToken},
+		{name: "prefix", header: "Bearer read", err: ErrInvalidToken},
+		{name: "trailing space", header: "Bearer reader ", err: ErrInvalidToken},
+		{name: "unknown query param", query: "?access_token=unknown", err: ErrInvalidToken},
+	}
+
+	a := NewAuthenticator(testConfig)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			r := httptest.NewRequest("GET", "/logs"+test.query, nil)
+			if test.header != "" {
+				r.Header.Set(authorizationHeaderName, test.header)
+			}
+
+			token, err := a.Authenticate(r)
+			if err != test.err {
+				t.Fatalf("authenticate should have returned err = %v but got err = %v", test.err, err)
+			}
+
+			if err != nil {
+				if token != nil {
+					t.Fatalf("authenticate should not have returned a token")
+				}
+
+				return
+			}
+
+			if token.permission != test.permission {
+				t.Fatalf("token should have permission %s but got %s", test.permission, token.permission)
+			}
+		})
+	}
+}
+
+// Tests that tokens only allow their permission and the lower ones, on logs
+// matching their patterns.
+func TestToken_Allows(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		token      Token
+		permission Permission
+		log        string
+		allowed    bool
+	}{
+		{name: "read all", token: Token{PermissionRead, []string{"*"}}, permission: PermissionRead, log: "events", allowed: true},
+		{name: "read can't write", token: Token{PermissionRead, []string{"*"}}, permission: PermissionWrite, log: "events", allowed: false},
+		{name: "write can read", token: Token{PermissionWrite, []string{"*"}}, permission: PermissionRead, log: "events", allowed: true},
+		{name: "write can't admin", token: Token{PermissionWrite, []string{"*"}}, permission: PermissionAdmin, log: "events", allowed: false},
+		{name: "admin can write", token: Token{PermissionAdmin, []string{"*"}}, permission: PermissionWrite, log: "events", allowed: true},
+		{name: "admin can read", token: Token{PermissionAdmin, []string{"*"}}, permission: PermissionRead, log: "events", allowed: true},
+		{name: "unknown permission", token: Token{Permission("root"), []string{"*"}}, permission: PermissionRead, log: "events", allowed: false},
+		{name: "exact name", token: Token{PermissionRead, []string{"events"}}, permission: PermissionRead, log: "events", allowed: true},
+		{name: "other log", token: Token{PermissionAdmin, []string{"events"}}, permission: PermissionRead, log: "metrics", allowed: false},
+		{name: "name prefix", token: Token{PermissionRead, []string{"events"}}, permission: PermissionRead, log: "events-2021", allowed: false},
+		{name: "glob prefix", token: Token{PermissionRead, []string{"events-*"}}, permission: PermissionRead, log: "events-2021", allowed: true},
+		{name: "glob prefix other log", token: Token{PermissionRead, []string{"events-*"}}, permission: PermissionRead, log: "metrics-2021", allowed: false},
+		{name: "glob prefix only", token: Token{PermissionRead, []string{"events-*"}}, permission: PermissionRead, log: "events", allowed: false},
+		{name: "glob suffix", token: Token{PermissionRead, []string{"*-2021"}}, permission: PermissionRead, log: "events-2021", allowed: true},
+		{name: "single character", token: Token{PermissionRead, []string{"events-?"}}, permission: PermissionRead, log: "events-1", allowed: true},
+		{name: "character class", token: Token{PermissionRead, []string{"events-[ab]"}}, permission: PermissionRead, log: "events-c", allowed: false},
+		{name: "second pattern", token: Token{PermissionRead, []string{"events", "metrics"}}, permission: PermissionRead, log: "metrics", allowed: true},
+		{name: "no pattern", token: Token{PermissionAdmin, []string{}}, permission: PermissionRead, log: "events", allowed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			allowed := test.token.Allows(test.permission, test.log)
+			if allowed != test.allowed {
+				t.Fatalf("token %+v should have allowed = %v for %s on %s but got %v", test.token, test.allowed, test.permission, test.log, allowed)
+			}
+		})
+	}
+}
+
+// Tests that only tokens with the "*" pattern are allowed on all logs.
+func TestToken_AllowsAll(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		token      Token
+		permission Permission
+		allowed    bool
+	}{
+		{name: "star", token: Token{PermissionRead, []string{"*"}}, permission: PermissionRead, allowed: true},
+		{name: "star among others", token: Token{PermissionRead, []string{"events", "*"}}, permission: PermissionRead, allowed: true},
+		{name: "star lower permission", token: Token{PermissionRead, []string{"*"}}, permission: PermissionAdmin, allowed: false},
+		{name: "star higher permission", token: Token{PermissionAdmin, []string{"*"}}, permission: PermissionRead, allowed: true},
+		{name: "glob prefix", token: Token{PermissionAdmin, []string{"events-*"}}, permission: PermissionRead, allowed: false},
+		{name: "double star", token: Token{PermissionAdmin, []string{"**"}}, permission: PermissionRead, allowed: false},
+		{name: "no pattern", token: Token{PermissionAdmin, []string{}}, permission: PermissionRead, allowed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			allowed := test.token.AllowsAll(test.permission)
+			if allowed != test.allowed {
+				t.Fatalf("token %+v should have allowed all = %v for %s but got %v", test.token, test.allowed, test.permission, allowed)
+			}
+		})
+	}
+}
+
+// Tests that invalid permissions and patterns are rejected.
+func TestConfig_Validate(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		config Config
+		err    error
+	}{
+		{name: "valid", config: testConfig, err: nil},
+		{name: "invalid permission", config: Config{Tokens: []TokenConfig{{Token: "t", Permission: "root", Logs: []string{"*"}}}}, err: ErrInvalidPermission},
+		{name: "invalid pattern", config: Config{Tokens: []TokenConfig{{Token: "t", Permission: PermissionRead, Logs: []string{"events-["}}}}, err: ErrInvalidPattern},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			err := test.config.Validate()
+			if err != test.err {
+				t.Fatalf("validate should have returned err = %v but got err = %v", test.err, err)
+			}
+		})
+	}
+}
+
+// Tests that tokens are attached to request contexts.
+func TestContext(t *testing.T) {
+
+	ctx := context.Background()
+
+	if FromContext(ctx) != nil {
+		t.Fatalf("context without token should return nil")
+	}
+
+	token := &Token{PermissionRead, []string{"*"}}
+
+	if FromContext(NewContext(ctx, token)) != token {
+		t.Fatalf("context should return its token")
+	}
+}
@@ -13,6 +13,10 @@ import (
 	"gitlab.com/dataptive/styx/logman"
 	"gitlab.com/dataptive/styx/metrics"
 	"gitlab.com/dataptive/styx/metrics/statsd"
+	"gitlab.com/dataptive/styx/pipeline"
+	"gitlab.com/dataptive/styx/replication"
+	"gitlab.com/dataptive/styx/server/auth"
+	"gitlab.com/dataptive/styx/tracing"
 
 	"github.com/BurntSushi/toml"
 )
@@ -29,8 +33,15 @@ type TOMLConfig struct {
 	WSReadBufferSize       int                   `toml:"websocket_read_buffer_size"`
 	WSWriteBufferSize      int                   `toml:"websocket_write_buffer_size"`
 	TCPTimeout             int                   `toml:"tcp_timeout"`
+	TLSCertFile            string                `toml:"tls_cert_file"`
+	TLSKeyFile             string                `toml:"tls_key_file"`
+	TLSClientCAFile        string                `toml:"tls_client_ca_file"`
 	LogManager             TOMLLogManagerConfig  `toml:"log_manager"`
 	Metrics                TOMLMetricsConfig     `toml:"metrics"`
+	Replication            *TOMLReplicationConfig `toml:"replication"`
+	Auth                   *TOMLAuthConfig        `toml:"auth"`
+	Pipelines              []TOMLPipelineConfig   `toml:"pipelines"`
+	Tracing                *TOMLTracingConfig     `toml:"tracing"`
 }
 
 
@@ -38,6 +49,9 @@ type TOMLLogManagerConfig struct {
 	DataDirectory   string `toml:"data_directory"`
 	ReadBufferSize  int    `toml:"read_buffer_size"`
 	WriteBufferSize int    `toml:"write_buffer_size"`
+	RepairTail      bool   `toml:"repair_tail"`
+	ScrubInterval   int64  `toml:"scrub_interval"`
+	ScrubRate       int64  `toml:"scrub_rate"`
 }
 
 type TOMLMetricsConfig struct {
@@ -50,6 +64,41 @@ type TOMLStatsdConfig struct {
 	Prefix        string `toml:"prefix"`
 }
 
+type TOMLReplicationConfig struct {
+	LeaderAddress  string `toml:"leader_address"`
+	LeaderToken    string `toml:"leader_token"`
+	LeaderCAFile   string `toml:"leader_ca_file"`
+	LeaderCertFile string `toml:"leader_cert_file"`
+	LeaderKeyFile  string `toml:"leader_key_file"`
+	SyncInterval   int    `toml:"sync_interval"`
+}
+
+type TOMLAuthConfig struct {
+	Tokens []TOMLTokenConfig `toml:"tokens"`
+}
+
+type TOMLTokenConfig struct {
+	Token      string   `toml:"token"`
+	Permission string   `toml:"permission"`
+	Logs       []string `toml:"logs"`
+}
+
+type TOMLTracingConfig struct {
+	Endpoint    string `toml:"endpoint"`
+	ServiceName string `toml:"service_name"`
+}
+
+type TOMLPipelineConfig struct {
+	Name        string             `toml:"name"`
+	Source      string             `toml:"source"`
+	Destination string             `toml:"destination"`
+	Transform   pipeline.Transform `toml:"transform"`
+	Path        string             `toml:"path"`
+	Value       string             `toml:"value"`
+	Pattern     string             `toml:"pattern"`
+	Rate        float64            `toml:"rate"`
+}
+
 type Config struct {
 	PIDFile                string
 	BindAddress            string
@@ -62,8 +111,15 @@ type Config struct {
 	WSReadBufferSize       int
 	WSWriteBufferSize      int
 	TCPTimeout             int
+	TLSCertFile            string
+	TLSKeyFile             string
+	TLSClientCAFile        string
 	LogManager             logman.Config
 	Metrics                metrics.Config
+	Replication            *replication.Config
+	Auth                   *auth.Config
+	Pipelines              []pipeline.Config
+	Tracing                *tracing.Config
 }
 
 func Load(path string) (c Config, err error) {
@@ -86,10 +142,73 @@ func Load(path string) (c Config, err error) {
 	c.WSReadBufferSize = tc.WSReadBufferSize
 	c.WSWriteBufferSize = tc.WSWriteBufferSize
 	c.TCPTimeout = tc.TCPTimeout
+	c.TLSCertFile = tc.TLSCertFile
+	c.TLSKeyFile = tc.TLSKeyFile
+	c.TLSClientCAFile = tc.TLSClientCAFile
 	c.LogManager = logman.Config(tc.LogManager)
+
+	if c.LogManager.ScrubRate == 0 {
+		c.LogManager.ScrubRate = logman.DefaultConfig.ScrubRate
+	}
+
 	c.Metrics = metrics.Config{
 		Statsd: (*statsd.Config)(tc.Metrics.Statsd),
 	}
 
+	if tc.Replication != nil {
+		replicationConfig := replication.Config(*tc.Replication)
+
+		if replicationConfig.SyncInterval == 0 {
+			replicationConfig.SyncInterval = replication.DefaultConfig.SyncInterval
+		}
+
+		c.Replication = &replicationConfig
+	}
+
+	if tc.Auth != nil {
+		authConfig := auth.Config{}
+
+		for _, tt := range tc.Auth.Tokens {
+			authConfig.Tokens = append(authConfig.Tokens, auth.TokenConfig{
+				Token:      tt.Token,
+				Permission: auth.Permission(tt.Permission),
+				Logs:       tt.Logs,
+			})
+		}
+
+		err = authConfig.Validate()
+		if err != nil {
+			return c, err
+		}
+
+		c.Auth = &authConfig
+	}
+
+	for _, tp := range tc.Pipelines {
+
+		pipelineConfig := pipeline.Config(tp)
+
+		err = pipelineConfig.Validate()
+		if err != nil {
+			return c, err
+		}
+
+		c.Pipelines = append(c.Pipelines, pipelineConfig)
+	}
+
+	if tc.Tracing != nil {
+		tracingConfig := tracing.Config(*tc.Tracing)
+
+		if tracingConfig.Endpoint == "" {
+			tracingConfig.Endpoint = tracing.DefaultConfig.Endpoint
+		}
+
+		if tracingConfig.ServiceName == "" {
+			tracingConfig.ServiceName = tracing.DefaultConfig.ServiceName
+		}
+
+		c.Tracing = &tracingConfig
+	}
+
 	return c, nil
 }
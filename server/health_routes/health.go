@@ -0,0 +1,46 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package health_routes
+
+import (
+	"net/http"
+
+	"gitlab.com/dataptive/styx/api"
+)
+
+const (
+	healthStatusOK = "ok"
+)
+
+// LiveHandler succeeds as long as the server handles requests.
+func (hr *HealthRouter) LiveHandler(w http.ResponseWriter, r *http.Request) {
+
+	response := api.HealthResponse{
+		Status: healthStatusOK,
+	}
+
+	api.WriteResponse(w, http.StatusOK, response)
+}
+
+// ReadyHandler fails while logs are being opened or scanned after a crash,
+// and once the server is shutting down.
+func (hr *HealthRouter) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+
+	if hr.opening || !hr.manager.Ready() {
+		api.WriteError(w, http.StatusServiceUnavailable, api.ErrNotReady)
+		return
+	}
+
+	response := api.HealthResponse{
+		Status: healthStatusOK,
+	}
+
+	api.WriteResponse(w, http.StatusOK, response)
+}
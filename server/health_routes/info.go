@@ -0,0 +1,71 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package health_routes
+
+import (
+	"net/http"
+	"syscall"
+	"time"
+
+	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/logman"
+)
+
+func (hr *HealthRouter) InfoHandler(w http.ResponseWriter, r *http.Request) {
+
+	dataDirectory := hr.config.LogManager.DataDirectory
+
+	diskFree, err := diskFree(dataDirectory)
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	logStatuses := map[string]int64{
+		string(logman.StatusOK):       0,
+		string(logman.StatusCorrupt):  0,
+		string(logman.StatusTainted):  0,
+		string(logman.StatusScanning): 0,
+		string(logman.StatusUnknown):  0,
+	}
+
+	for _, ml := range hr.manager.ListLogs() {
+		logStatuses[string(ml.Status())]++
+	}
+
+	response := api.InfoResponse{
+		Version:       hr.version,
+		Uptime:        int64(time.Since(hr.startTime) / time.Second),
+		Ready:         hr.manager.Ready(),
+		DataDirectory: dataDirectory,
+		DiskFree:      diskFree,
+		LogStatuses:   logStatuses,
+	}
+
+	api.WriteResponse(w, http.StatusOK, response)
+}
+
+// diskFree returns the count of bytes available to the server on the
+// filesystem holding path.
+func diskFree(path string) (free int64, err error) {
+
+	stat := syscall.Statfs_t{}
+
+	err = syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+
+	free = int64(stat.Bavail) * int64(stat.Bsize)
+
+	return free, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package health_routes
+
+import (
+	"net/http"
+	"time"
+
+	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/server/config"
+
+	"github.com/gorilla/mux"
+)
+
+type HealthRouter struct {
+	router    *mux.Router
+	manager   *logman.LogManager
+	config    config.Config
+	version   string
+	startTime time.Time
+	opening   bool
+}
+
+func RegisterRoutes(router *mux.Router, logManager *logman.LogManager, config config.Config, version string) (hr *HealthRouter) {
+
+	hr = &HealthRouter{
+		router:    router,
+		manager:   logManager,
+		config:    config,
+		version:   version,
+		startTime: time.Now(),
+	}
+
+	router.HandleFunc("/live", hr.LiveHandler).
+		Methods(http.MethodGet)
+
+	router.HandleFunc("/ready", hr.ReadyHandler).
+		Methods(http.MethodGet)
+
+	return hr
+}
+
+// RegisterOpeningRoutes registers the health routes served while the server
+// opens its logs, before the log manager is available.
+func RegisterOpeningRoutes(router *mux.Router, config config.Config, version string) (hr *HealthRouter) {
+
+	hr = &HealthRouter{
+		router:    router,
+		manager:   nil,
+		config:    config,
+		version:   version,
+		startTime: time.Now(),
+		opening:   true,
+	}
+
+	router.HandleFunc("/live", hr.LiveHandler).
+		Methods(http.MethodGet)
+
+	router.HandleFunc("/ready", hr.ReadyHandler).
+		Methods(http.MethodGet)
+
+	return hr
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logs_routes
+
+import (
+	"net/http"
+
+	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/server/auth"
+
+	"github.com/gorilla/mux"
+)
+
+func (lr *LogsRouter) CopyHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	form := api.CopyLogForm{}
+
+	err := r.ParseForm()
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	err = lr.schemaDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	// The route only authorizes the source log.
+	token := auth.FromContext(r.Context())
+	if token != nil && !token.Allows(auth.PermissionAdmin, form.Name) {
+		api.WriteError(w, http.StatusForbidden, api.ErrForbidden)
+		return
+	}
+
+	ml, err := lr.manager.CopyLog(name, form.Name)
+	if err == logman.ErrNotExist {
+		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
+		logger.Debug(err)
+		return
+	}
+
+	if err == log.ErrExist {
+		api.WriteError(w, http.StatusBadRequest, api.ErrLogExist)
+		logger.Debug(err)
+		return
+	}
+
+	if err == logman.ErrInvalidName {
+		api.WriteError(w, http.StatusBadRequest, api.ErrLogInvalidName)
+		logger.Debug(err)
+		return
+	}
+
+	if err == logman.ErrUnavailable {
+		api.WriteError(w, http.StatusBadRequest, api.ErrLogNotAvailable)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	logInfo := ml.Stat()
+
+	api.WriteResponse(w, http.StatusOK, api.CopyLogResponse(logInfo))
+}
@@ -49,6 +49,13 @@ func (lr *LogsRouter) CreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err == log.ErrUnknownCompression {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
 	if err == logman.ErrInvalidName {
 		api.WriteError(w, http.StatusBadRequest, api.ErrLogInvalidName)
 		logger.Debug(err)
@@ -0,0 +1,180 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logs_routes
+
+import (
+	"net/http"
+
+	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/logman"
+
+	"github.com/gorilla/mux"
+)
+
+func (lr *LogsRouter) ListGroupsHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	managedLog, err := lr.manager.GetLog(name)
+	if err == logman.ErrNotExist {
+		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	response := api.ListGroupsResponse{}
+	for _, groupInfo := range managedLog.ListGroups() {
+		response = append(response, api.GroupInfo(groupInfo))
+	}
+
+	api.WriteResponse(w, http.StatusOK, response)
+}
+
+func (lr *LogsRouter) GetGroupHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+	group := vars["group"]
+
+	managedLog, err := lr.manager.GetLog(name)
+	if err == logman.ErrNotExist {
+		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	groupInfo, err := managedLog.GetGroup(group)
+	if err == logman.ErrGroupNotExist {
+		api.WriteError(w, http.StatusNotFound, api.ErrGroupNotFound)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	api.WriteResponse(w, http.StatusOK, api.GetGroupResponse(groupInfo))
+}
+
+func (lr *LogsRouter) CommitGroupHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+	group := vars["group"]
+
+	form := api.CommitGroupForm{}
+
+	err := r.ParseForm()
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	err = lr.schemaDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	managedLog, err := lr.manager.GetLog(name)
+	if err == logman.ErrNotExist {
+		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	groupInfo, err := managedLog.CommitGroup(group, form.Position)
+	if err == logman.ErrInvalidGroupName {
+		api.WriteError(w, http.StatusBadRequest, api.ErrGroupInvalidName)
+		logger.Debug(err)
+		return
+	}
+
+	if err == logman.ErrInvalidPosition {
+		api.WriteError(w, http.StatusBadRequest, api.ErrInvalidPosition)
+		logger.Debug(err)
+		return
+	}
+
+	if err == logman.ErrUnavailable {
+		api.WriteError(w, http.StatusBadRequest, api.ErrLogNotAvailable)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	api.WriteResponse(w, http.StatusOK, api.CommitGroupResponse(groupInfo))
+}
+
+func (lr *LogsRouter) DeleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+	group := vars["group"]
+
+	managedLog, err := lr.manager.GetLog(name)
+	if err == logman.ErrNotExist {
+		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	err = managedLog.DeleteGroup(group)
+	if err == logman.ErrGroupNotExist {
+		api.WriteError(w, http.StatusNotFound, api.ErrGroupNotFound)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	api.WriteResponse(w, http.StatusOK, nil)
+}
@@ -13,6 +13,7 @@ import (
 	"net/http"
 
 	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/server/auth"
 )
 
 func (lr *LogsRouter) ListHandler(w http.ResponseWriter, r *http.Request) {
@@ -21,9 +22,16 @@ func (lr *LogsRouter) ListHandler(w http.ResponseWriter, r *http.Request) {
 
 	managedLogs := lr.manager.ListLogs()
 
+	token := auth.FromContext(r.Context())
+
 	for _, ml := range managedLogs {
 
 		logInfo := ml.Stat()
+
+		// Only list logs the token is allowed to read.
+		if token != nil && !token.Allows(auth.PermissionRead, logInfo.Name) {
+			continue
+		}
 		entries = append(entries, api.LogInfo(logInfo))
 	}
 
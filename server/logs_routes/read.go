@@ -19,6 +19,7 @@ import (
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
 	"gitlab.com/dataptive/styx/recio"
+	"gitlab.com/dataptive/styx/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -76,7 +77,7 @@ func (lr *LogsRouter) ReadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = logReader.Seek(params.Position, params.Whence)
+	err = seekLogReader(logReader, managedLog, params.Group, params.Position, params.Whence)
 	if err != nil {
 		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
 		logger.Debug(err)
@@ -84,6 +85,9 @@ func (lr *LogsRouter) ReadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	position, _ := logReader.Tell()
+	w.Header().Set(api.PositionHeaderName, strconv.FormatInt(position, 10))
+
 	record := log.Record{}
 
 	_, err = logReader.Read(&record)
@@ -106,10 +110,18 @@ func (lr *LogsRouter) ReadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Length", strconv.Itoa(len(record)))
+	api.WriteRecordHeaders(w.Header(), &record)
+
+	// Let clients continue the trace of the record write.
+	sc, found := tracing.RecordContext(&record)
+	if found {
+		w.Header().Set(tracing.TraceparentHeaderName, sc.Traceparent())
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(record.Payload)))
 	w.WriteHeader(http.StatusOK)
 
-	_, err = w.Write(record)
+	_, err = w.Write(record.Payload)
 	if err != nil {
 		logger.Debug(err)
 		return
@@ -17,9 +17,11 @@ import (
 
 	"gitlab.com/dataptive/styx/api"
 	"gitlab.com/dataptive/styx/clock"
+	"gitlab.com/dataptive/styx/filter"
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
 	"gitlab.com/dataptive/styx/recio"
 
 	"github.com/gorilla/mux"
@@ -74,6 +76,14 @@ func (lr *LogsRouter) ReadBatchHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	recordFilter, err := params.Filter()
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
 	managedLog, err := lr.manager.GetLog(name)
 	if err == logman.ErrNotExist {
 		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
@@ -102,7 +112,7 @@ func (lr *LogsRouter) ReadBatchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = logReader.Seek(params.Position, params.Whence)
+	err = seekLogReader(logReader, managedLog, params.Group, params.Position, params.Whence)
 	if err != nil {
 		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
 		logger.Debug(err)
@@ -110,23 +120,38 @@ func (lr *LogsRouter) ReadBatchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	position, _ := logReader.Tell()
+	w.Header().Set(api.PositionHeaderName, strconv.FormatInt(position, 10))
+
 	w.Header().Set("Content-Type", api.RecordBinaryMediaType)
+	w.Header().Set("Trailer", api.NextPositionHeaderName)
 	w.WriteHeader(http.StatusOK)
 
-	err = readBatch(bufferedWriter, logReader, params.Count, params.Follow, timeout)
+	lr.reporter.ReportConsumers(metrics.ProtocolHTTP, 1)
+	defer lr.reporter.ReportConsumers(metrics.ProtocolHTTP, -1)
+
+	managedLog.TrackReader(logReader, metrics.ProtocolHTTP, r.RemoteAddr)
+	defer managedLog.UntrackReader(logReader)
+
+	err = readBatch(bufferedWriter, logReader, params.Count, params.Follow, timeout, recordFilter)
 	if err != nil {
 		logger.Debug(err)
 		logReader.Close()
 		return
 	}
 
+	// Records skipped by the filter are not sent, the trailer holds the
+	// position to resume reading from.
+	position, _ = logReader.Tell()
+	w.Header().Set(api.NextPositionHeaderName, strconv.FormatInt(position, 10))
+
 	err = logReader.Close()
 	if err != nil {
 		logger.Debug(err)
 	}
 }
 
-func readBatch(bw *recio.BufferedWriter, lr *log.LogReader, limit int64, follow bool, timeout int) (err error) {
+func readBatch(bw *recio.BufferedWriter, lr *log.LogReader, limit int64, follow bool, timeout int, recordFilter *filter.Filter) (err error) {
 
 	count := int64(0)
 	record := log.Record{}
@@ -176,6 +201,10 @@ func readBatch(bw *recio.BufferedWriter, lr *log.LogReader, limit int64, follow
 			return err
 		}
 
+		if !recordFilter.Match(&record) {
+			continue
+		}
+
 		_, err = bw.Write(&record)
 		if err != nil {
 			return err
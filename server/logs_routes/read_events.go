@@ -0,0 +1,296 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logs_routes
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/filter"
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
+	"gitlab.com/dataptive/styx/recio"
+
+	"github.com/gorilla/mux"
+)
+
+func (lr *LogsRouter) ReadEventsMatcher(r *http.Request, rm *mux.RouteMatch) (match bool) {
+
+	accept := r.Header.Get("Accept")
+	mediaType, _, _ := mime.ParseMediaType(accept)
+
+	match = mediaType == api.RecordEventsMediaType
+
+	return match
+}
+
+// ReadEventsHandler streams records as server-sent events, using record
+// positions as event ids so that reconnecting clients resume after the last
+// record they received.
+func (lr *LogsRouter) ReadEventsHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(ErrUnsupportedFlush)
+		return
+	}
+
+	params := api.ReadRecordsEventsParams{
+		Whence:   log.SeekOrigin,
+		Position: 0,
+		Count:    -1,
+		Follow:   false,
+	}
+	query := r.URL.Query()
+
+	err := lr.schemaDecoder.Decode(&params, query)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	err = params.Validate()
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	lastEventID := int64(-1)
+
+	rawLastEventID := r.Header.Get(api.LastEventIDHeaderName)
+	if rawLastEventID != "" {
+
+		lastEventID, err = strconv.ParseInt(rawLastEventID, 10, 64)
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, api.ErrUnknownError)
+			logger.Debug(err)
+			return
+		}
+	}
+
+	recordFilter, err := params.Filter()
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	managedLog, err := lr.manager.GetLog(name)
+	if err == logman.ErrNotExist {
+		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	// Reconnecting clients resume after the last record they received,
+	// or from the first available record when it was deleted since.
+	if lastEventID != -1 {
+
+		params.Whence = log.SeekOrigin
+		params.Position = lastEventID + 1
+
+		logInfo := managedLog.Stat()
+		if params.Position < logInfo.StartPosition {
+			params.Position = logInfo.StartPosition
+		}
+	}
+
+	logReader, err := managedLog.NewReader(params.Follow, recio.ModeManual)
+	if err == logman.ErrUnavailable {
+		api.WriteError(w, http.StatusBadRequest, api.ErrLogNotAvailable)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	err = seekLogReader(logReader, managedLog, params.Group, params.Position, params.Whence)
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		logReader.Close()
+		return
+	}
+
+	// Followed streams only end when the client goes away, close the
+	// reader to unblock it.
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-r.Context().Done():
+			logReader.Close()
+		case <-done:
+		}
+	}()
+
+	position, _ := logReader.Tell()
+	w.Header().Set(api.PositionHeaderName, strconv.FormatInt(position, 10))
+
+	w.Header().Set("Content-Type", api.RecordEventsMediaType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	bufferedWriter := bufio.NewWriterSize(w, lr.config.HTTPWriteBufferSize)
+
+	lr.reporter.ReportConsumers(metrics.ProtocolHTTP, 1)
+	defer lr.reporter.ReportConsumers(metrics.ProtocolHTTP, -1)
+
+	managedLog.TrackReader(logReader, metrics.ProtocolHTTP, r.RemoteAddr)
+	defer managedLog.UntrackReader(logReader)
+
+	err = readEvents(bufferedWriter, flusher, logReader, params.Count, recordFilter)
+	if err != nil {
+		logger.Debug(err)
+		logReader.Close()
+		return
+	}
+
+	err = logReader.Close()
+	if err != nil {
+		logger.Debug(err)
+	}
+}
+
+func readEvents(bw *bufio.Writer, f http.Flusher, lr *log.LogReader, limit int64, recordFilter *filter.Filter) (err error) {
+
+	count := int64(0)
+	record := &log.Record{}
+
+	for {
+		if count == limit {
+			break
+		}
+
+		_, err := lr.Read(record)
+		if err == io.EOF {
+			break
+		}
+
+		if err == recio.ErrMustFill {
+
+			// Send pending events before waiting for new
+			// records.
+			err = bw.Flush()
+			if err != nil {
+				return err
+			}
+
+			f.Flush()
+
+			err = lr.Fill()
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !recordFilter.Match(record) {
+			continue
+		}
+
+		position, _ := lr.Tell()
+
+		err = writeEvent(bw, position-1, record.Payload)
+		if err != nil {
+			return err
+		}
+
+		count++
+	}
+
+	err = bw.Flush()
+	if err != nil {
+		return err
+	}
+
+	f.Flush()
+
+	return nil
+}
+
+// writeEvent writes a record payload as an event with the record position as
+// id. Each payload line is sent in its own data field, since events can't
+// contain line endings.
+func writeEvent(bw *bufio.Writer, position int64, payload []byte) (err error) {
+
+	_, err = bw.WriteString("id: " + strconv.FormatInt(position, 10) + "\n")
+	if err != nil {
+		return err
+	}
+
+	start := 0
+	for i := 0; i <= len(payload); i++ {
+
+		if i < len(payload) && payload[i] != '\n' && payload[i] != '\r' {
+			continue
+		}
+
+		_, err = bw.WriteString("data: ")
+		if err != nil {
+			return err
+		}
+
+		_, err = bw.Write(payload[start:i])
+		if err != nil {
+			return err
+		}
+
+		err = bw.WriteByte('\n')
+		if err != nil {
+			return err
+		}
+
+		// CRLF counts as a single line ending.
+		if i+1 < len(payload) && payload[i] == '\r' && payload[i+1] == '\n' {
+			i++
+		}
+
+		start = i + 1
+	}
+
+	err = bw.WriteByte('\n')
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -17,9 +17,11 @@ import (
 	"time"
 
 	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/filter"
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
 	"gitlab.com/dataptive/styx/recio"
 	"gitlab.com/dataptive/styx/recio/recioutil"
 
@@ -100,6 +102,14 @@ func (lr *LogsRouter) ReadLinesHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	recordFilter, err := params.Filter()
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
 	managedLog, err := lr.manager.GetLog(name)
 	if err == logman.ErrNotExist {
 		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
@@ -129,7 +139,7 @@ func (lr *LogsRouter) ReadLinesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = logReader.Seek(params.Position, params.Whence)
+	err = seekLogReader(logReader, managedLog, params.Group, params.Position, params.Whence)
 	if err != nil {
 		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
 		logger.Debug(err)
@@ -137,24 +147,39 @@ func (lr *LogsRouter) ReadLinesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	position, _ := logReader.Tell()
+	w.Header().Set(api.PositionHeaderName, strconv.FormatInt(position, 10))
+
 	mediaType := mime.FormatMediaType(api.RecordLinesMediaType, typeParams)
 	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Trailer", api.NextPositionHeaderName)
 	w.WriteHeader(http.StatusOK)
 
-	err = readLines(lineWriter, bufferedWriter, logReader, params.Count, params.Follow, timeout)
+	lr.reporter.ReportConsumers(metrics.ProtocolHTTP, 1)
+	defer lr.reporter.ReportConsumers(metrics.ProtocolHTTP, -1)
+
+	managedLog.TrackReader(logReader, metrics.ProtocolHTTP, r.RemoteAddr)
+	defer managedLog.UntrackReader(logReader)
+
+	err = readLines(lineWriter, bufferedWriter, logReader, params.Count, params.Follow, timeout, recordFilter)
 	if err != nil {
 		logger.Debug(err)
 		logReader.Close()
 		return
 	}
 
+	// Records skipped by the filter are not sent, the trailer holds the
+	// position to resume reading from.
+	position, _ = logReader.Tell()
+	w.Header().Set(api.NextPositionHeaderName, strconv.FormatInt(position, 10))
+
 	err = logReader.Close()
 	if err != nil {
 		logger.Debug(err)
 	}
 }
 
-func readLines(lw *recioutil.LineWriter, bw *recio.BufferedWriter, lr *log.LogReader, limit int64, follow bool, timeout int) (err error) {
+func readLines(lw *recioutil.LineWriter, bw *recio.BufferedWriter, lr *log.LogReader, limit int64, follow bool, timeout int, recordFilter *filter.Filter) (err error) {
 
 	count := int64(0)
 	record := &log.Record{}
@@ -203,7 +228,13 @@ func readLines(lw *recioutil.LineWriter, bw *recio.BufferedWriter, lr *log.LogRe
 			return err
 		}
 
-		_, err = lw.Write((*recioutil.Line)(record))
+		if !recordFilter.Match(record) {
+			continue
+		}
+
+		line := recioutil.Line(record.Payload)
+
+		_, err = lw.Write(&line)
 		if err != nil {
 			return err
 		}
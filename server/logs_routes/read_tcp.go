@@ -16,9 +16,11 @@ import (
 
 	"gitlab.com/dataptive/styx/api"
 	"gitlab.com/dataptive/styx/api/tcp"
+	"gitlab.com/dataptive/styx/filter"
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
 	"gitlab.com/dataptive/styx/recio"
 
 	"github.com/gorilla/mux"
@@ -69,6 +71,14 @@ func (lr *LogsRouter) ReadTCPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordFilter, err := params.Filter()
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
 	managedLog, err := lr.manager.GetLog(name)
 	if err == logman.ErrNotExist {
 		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
@@ -95,7 +105,7 @@ func (lr *LogsRouter) ReadTCPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = logReader.Seek(params.Position, params.Whence)
+	err = seekLogReader(logReader, managedLog, params.Group, params.Position, params.Whence)
 	if err != nil {
 		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
 		logger.Debug(err)
@@ -103,6 +113,9 @@ func (lr *LogsRouter) ReadTCPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	position, _ := logReader.Tell()
+	w.Header().Set(api.PositionHeaderName, strconv.FormatInt(position, 10))
+
 	w.Header().Add(api.TimeoutHeaderName, strconv.Itoa(lr.config.TCPTimeout))
 	conn, err := UpgradeTCP(w)
 	if err != nil {
@@ -111,12 +124,7 @@ func (lr *LogsRouter) ReadTCPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = conn.SetReadBuffer(lr.config.TCPReadBufferSize)
-	if err != nil {
-		logger.Warn(err)
-	}
-
-	err = conn.SetWriteBuffer(lr.config.TCPWriteBufferSize)
+	err = setSocketBuffers(conn, lr.config.TCPReadBufferSize, lr.config.TCPWriteBufferSize)
 	if err != nil {
 		logger.Warn(err)
 	}
@@ -132,7 +140,29 @@ func (lr *LogsRouter) ReadTCPHandler(w http.ResponseWriter, r *http.Request) {
 		logReader.Close()
 	})
 
-	err = readTCP(tcpWriter, logReader, params.Count)
+	if params.Group != "" {
+		tcpWriter.HandleCommit(func(position int64) (err error) {
+			_, err = managedLog.CommitGroup(params.Group, position)
+			if err != nil {
+				logger.Debug(err)
+				return commitTCPError(err)
+			}
+
+			return nil
+		})
+	}
+
+	lr.reporter.ReportConsumers(metrics.ProtocolTCP, 1)
+	defer lr.reporter.ReportConsumers(metrics.ProtocolTCP, -1)
+
+	managedLog.TrackReader(logReader, metrics.ProtocolTCP, r.RemoteAddr)
+	defer managedLog.UntrackReader(logReader)
+
+	// Older clients fail on position messages, they are only sent to
+	// clients announcing support for them.
+	positions := hasCapability(r, api.PositionCapability)
+
+	err = readTCP(tcpWriter, logReader, params.Count, recordFilter, positions)
 	if err != nil {
 		logger.Debug(err)
 
@@ -164,9 +194,14 @@ func (lr *LogsRouter) ReadTCPHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func readTCP(w *tcp.TCPWriter, lr *log.LogReader, limit int64) (err error) {
+// readTCP writes records matching recordFilter. When records were skipped by
+// the filter or removed by compaction, and positions is set, the position of
+// the next record is sent before writing a record or flushing, so that the
+// remote reader position stays accurate.
+func readTCP(w *tcp.TCPWriter, lr *log.LogReader, limit int64, recordFilter *filter.Filter, positions bool) (err error) {
 
 	count := int64(0)
+	remotePosition, _ := lr.Tell()
 	record := log.Record{}
 
 	for {
@@ -181,6 +216,13 @@ func readTCP(w *tcp.TCPWriter, lr *log.LogReader, limit int64) (err error) {
 
 		if err == recio.ErrMustFill {
 
+			if positions {
+				remotePosition, err = syncPosition(w, lr, remotePosition, 0)
+				if err != nil {
+					return err
+				}
+			}
+
 			err = w.Flush()
 			if err != nil {
 				return err
@@ -198,14 +240,33 @@ func readTCP(w *tcp.TCPWriter, lr *log.LogReader, limit int64) (err error) {
 			return err
 		}
 
+		if !recordFilter.Match(&record) {
+			continue
+		}
+
+		if positions {
+			remotePosition, err = syncPosition(w, lr, remotePosition, -1)
+			if err != nil {
+				return err
+			}
+		}
+
 		_, err = w.Write(&record)
 		if err != nil {
 			return err
 		}
 
+		remotePosition += 1
 		count++
 	}
 
+	if positions {
+		_, err = syncPosition(w, lr, remotePosition, 0)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = w.Flush()
 	if err != nil {
 		return err
@@ -213,3 +274,38 @@ func readTCP(w *tcp.TCPWriter, lr *log.LogReader, limit int64) (err error) {
 
 	return nil
 }
+
+// syncPosition sends the reader position plus delta when it differs from the
+// remote reader position, and returns the new remote position.
+func syncPosition(w *tcp.TCPWriter, lr *log.LogReader, remotePosition int64, delta int64) (position int64, err error) {
+
+	position, _ = lr.Tell()
+	position += delta
+
+	if position == remotePosition {
+		return position, nil
+	}
+
+	_, err = w.WritePosition(position)
+	if err != nil {
+		return remotePosition, err
+	}
+
+	return position, nil
+}
+
+// commitTCPError returns the Styx protocol error sent back to the client for a
+// failed commit.
+func commitTCPError(err error) (er error) {
+
+	switch err {
+	case logman.ErrInvalidPosition:
+		return tcp.ErrInvalidPosition
+	case logman.ErrInvalidGroupName:
+		return tcp.ErrGroupInvalidName
+	case logman.ErrUnavailable:
+		return tcp.ErrLogNotAvailable
+	default:
+		return tcp.ErrUnknownError
+	}
+}
@@ -14,9 +14,11 @@ import (
 	"net/http"
 
 	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/filter"
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
 	"gitlab.com/dataptive/styx/recio"
 
 	"github.com/gorilla/mux"
@@ -54,6 +56,14 @@ func (lr *LogsRouter) ReadWSHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordFilter, err := params.Filter()
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
 	managedLog, err := lr.manager.GetLog(name)
 	if err == logman.ErrNotExist {
 		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
@@ -80,7 +90,7 @@ func (lr *LogsRouter) ReadWSHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = logReader.Seek(params.Position, params.Whence)
+	err = seekLogReader(logReader, managedLog, params.Group, params.Position, params.Whence)
 	if err != nil {
 		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
 		logger.Debug(err)
@@ -96,7 +106,13 @@ func (lr *LogsRouter) ReadWSHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = readWS(conn, logReader, params.Count)
+	lr.reporter.ReportConsumers(metrics.ProtocolWS, 1)
+	defer lr.reporter.ReportConsumers(metrics.ProtocolWS, -1)
+
+	managedLog.TrackReader(logReader, metrics.ProtocolWS, r.RemoteAddr)
+	defer managedLog.UntrackReader(logReader)
+
+	err = readWS(conn, logReader, params.Count, recordFilter)
 	if err != nil {
 		logger.Debug(err)
 
@@ -131,11 +147,16 @@ func (lr *LogsRouter) ReadWSHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func readWS(w *websocket.Conn, lr *log.LogReader, limit int64) (err error) {
+func readWS(w *websocket.Conn, lr *log.LogReader, limit int64, recordFilter *filter.Filter) (err error) {
 
 	count := int64(0)
 	record := log.Record{}
 
+	// Clients negotiating the records subprotocol receive encoded records
+	// along with their metadata, others only receive payloads.
+	encode := w.Subprotocol() == api.RecordsWSSubprotocol
+	buffer := []byte{}
+
 	for {
 		if count == limit {
 			break
@@ -160,10 +181,34 @@ func readWS(w *websocket.Conn, lr *log.LogReader, limit int64) (err error) {
 			return err
 		}
 
-		err = w.WriteMessage(websocket.BinaryMessage, []byte(record))
+		if !recordFilter.Match(&record) {
+			continue
+		}
+
+		message := record.Payload
+
+		if encode {
+			size := record.Size()
+			if size > len(buffer) {
+				buffer = make([]byte, size)
+			}
+
+			n, err := record.Encode(buffer)
+			if err != nil {
+				return err
+			}
+
+			message = buffer[:n]
+		}
+
+		err = w.WriteMessage(websocket.BinaryMessage, message)
 		if err != nil {
 			return err
 		}
+
+		// Only sent records count towards the limit, as with the other
+		// read routes.
+		count++
 	}
 
 	return nil
@@ -0,0 +1,173 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logs_routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/dataptive/styx/filter"
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/recio"
+
+	"github.com/gorilla/websocket"
+)
+
+// Tests that websocket reads stop after count records, only counting records
+// matching the filter.
+func TestReadWS_Count(t *testing.T) {
+
+	path := t.TempDir()
+	name := filepath.Join(path, "test")
+
+	l, err := log.Create(name, log.DefaultConfig, log.DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	testReadWS_Write(t, l, 10)
+
+	tests := []struct {
+		name     string
+		limit    int64
+		config   filter.Config
+		expected []string
+	}{
+		{
+			name:     "unlimited",
+			limit:    -1,
+			config:   filter.Config{},
+			expected: []string{"even-0", "odd-1", "even-2", "odd-3", "even-4", "odd-5", "even-6", "odd-7", "even-8", "odd-9"},
+		},
+		{
+			name:     "limited",
+			limit:    3,
+			config:   filter.Config{},
+			expected: []string{"even-0", "odd-1", "even-2"},
+		},
+		{
+			name:     "filtered",
+			limit:    3,
+			config:   filter.Config{Contains: "even"},
+			expected: []string{"even-0", "even-2", "even-4"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			recordFilter, err := filter.New(test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := testReadWS_Read(t, l, test.limit, recordFilter)
+
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("expected messages %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func testReadWS_Write(t *testing.T, l *log.Log, count int) {
+
+	lw, err := l.NewWriter(1<<20, recio.ModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < count; i++ {
+
+		payload := fmt.Sprintf("odd-%d", i)
+		if i%2 == 0 {
+			payload = fmt.Sprintf("even-%d", i)
+		}
+
+		_, err = lw.Write(&log.Record{Payload: []byte(payload)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = lw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testReadWS_Read serves records of the log with readWS, and returns the
+// payloads received by a websocket client until the connection is closed.
+func testReadWS_Read(t *testing.T, l *log.Log, limit int64, recordFilter *filter.Filter) (messages []string) {
+
+	upgrader := websocket.Upgrader{}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		lr, err := l.NewReader(1<<20, false, recio.ModeAuto)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer lr.Close()
+
+		err = readWS(conn, lr, limit, recordFilter)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer s.Close()
+
+	url := "ws" + strings.TrimPrefix(s.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	messages = []string{}
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		messages = append(messages, string(message))
+	}
+
+	return messages
+}
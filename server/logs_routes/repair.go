@@ -0,0 +1,74 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logs_routes
+
+import (
+	"net/http"
+
+	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/logman"
+
+	"github.com/gorilla/mux"
+)
+
+func (lr *LogsRouter) RepairHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	params := api.RepairLogParams{}
+	query := r.URL.Query()
+
+	err := lr.schemaDecoder.Decode(&params, query)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	tr, err := lr.manager.RepairLog(name, params.DryRun)
+	if err == logman.ErrNotExist {
+		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
+		logger.Debug(err)
+		return
+	}
+
+	if err == logman.ErrUnavailable {
+		api.WriteError(w, http.StatusBadRequest, api.ErrLogNotAvailable)
+		logger.Debug(err)
+		return
+	}
+
+	if err == log.ErrRepair || err == log.ErrCorrupt {
+		api.WriteError(w, http.StatusBadRequest, api.ErrLogNotRepairable)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	response := api.RepairLogResponse{
+		Torn:                tr.Needed(),
+		Repaired:            tr.Needed() && !params.DryRun,
+		Position:            tr.Position,
+		Offset:              tr.Offset,
+		TruncatedBytes:      tr.TruncatedBytes,
+		TruncatedIndexBytes: tr.TruncatedIndexBytes,
+	}
+
+	api.WriteResponse(w, http.StatusOK, response)
+}
@@ -14,7 +14,10 @@ import (
 
 	"gitlab.com/dataptive/styx/api"
 	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
+	"gitlab.com/dataptive/styx/server/auth"
 	"gitlab.com/dataptive/styx/server/config"
+	"gitlab.com/dataptive/styx/tracing"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/schema"
@@ -23,11 +26,13 @@ import (
 type LogsRouter struct {
 	router        *mux.Router
 	manager       *logman.LogManager
+	reporter      metrics.Reporter
+	tracer        *tracing.Tracer
 	config        config.Config
 	schemaDecoder *schema.Decoder
 }
 
-func RegisterRoutes(router *mux.Router, logManager *logman.LogManager, config config.Config) (lr *LogsRouter) {
+func RegisterRoutes(router *mux.Router, logManager *logman.LogManager, reporter metrics.Reporter, tracer *tracing.Tracer, config config.Config) (lr *LogsRouter) {
 
 	var decoder = schema.NewDecoder()
 	decoder.IgnoreUnknownKeys(true)
@@ -35,6 +40,8 @@ func RegisterRoutes(router *mux.Router, logManager *logman.LogManager, config co
 	lr = &LogsRouter{
 		router:        router,
 		manager:       logManager,
+		reporter:      reporter,
+		tracer:        tracer,
 		config:        config,
 		schemaDecoder: decoder,
 	}
@@ -42,76 +49,155 @@ func RegisterRoutes(router *mux.Router, logManager *logman.LogManager, config co
 	router.HandleFunc("", lr.ListHandler).
 		Methods(http.MethodGet)
 
-	router.HandleFunc("", lr.CreateHandler).
+	router.HandleFunc("", lr.authorized(auth.PermissionAdmin, lr.writable(lr.CreateHandler))).
 		Methods(http.MethodPost)
 
-	router.HandleFunc("/{name}", lr.GetHandler).
+	router.HandleFunc("/{name}", lr.authorized(auth.PermissionRead, lr.GetHandler)).
 		Methods(http.MethodGet)
 
-	router.HandleFunc("/{name}", lr.DeleteHandler).
+	router.HandleFunc("/{name}", lr.authorized(auth.PermissionAdmin, lr.writable(lr.UpdateHandler))).
+		Methods(http.MethodPatch)
+
+	router.HandleFunc("/{name}", lr.authorized(auth.PermissionAdmin, lr.writable(lr.DeleteHandler))).
 		Methods(http.MethodDelete)
 
-	router.HandleFunc("/{name}/truncate", lr.TruncateHandler).
+	router.HandleFunc("/{name}/truncate", lr.authorized(auth.PermissionAdmin, lr.writable(lr.TruncateHandler))).
+		Methods(http.MethodPost)
+
+	router.HandleFunc("/{name}/repair", lr.authorized(auth.PermissionAdmin, lr.writable(lr.RepairHandler))).
+		Methods(http.MethodPost)
+
+	router.HandleFunc("/{name}/rename", lr.authorized(auth.PermissionAdmin, lr.writable(lr.RenameHandler))).
 		Methods(http.MethodPost)
 
-	router.HandleFunc("/{name}/backup", lr.BackupHandler).
+	router.HandleFunc("/{name}/copy", lr.authorized(auth.PermissionAdmin, lr.writable(lr.CopyHandler))).
+		Methods(http.MethodPost)
+
+	router.HandleFunc("/{name}/backup", lr.authorized(auth.PermissionRead, lr.BackupHandler)).
 		Methods(http.MethodGet)
 
-	router.HandleFunc("/restore", lr.RestoreHandler).
+	router.HandleFunc("/restore", lr.authorized(auth.PermissionAdmin, lr.writable(lr.RestoreHandler))).
 		Methods(http.MethodPost)
 
-	router.HandleFunc("/{name}/records", lr.WriteWSHandler).
+	// Permissions are checked for each log of the batch by the handler.
+	router.HandleFunc("/batch", lr.writable(lr.WriteAtomicHandler)).
+		Methods(http.MethodPost)
+
+	router.HandleFunc("/{name}/groups", lr.authorized(auth.PermissionRead, lr.ListGroupsHandler)).
+		Methods(http.MethodGet)
+
+	router.HandleFunc("/{name}/groups/{group}", lr.authorized(auth.PermissionRead, lr.GetGroupHandler)).
+		Methods(http.MethodGet)
+
+	router.HandleFunc("/{name}/groups/{group}", lr.authorized(auth.PermissionRead, lr.CommitGroupHandler)).
+		Methods(http.MethodPut)
+
+	router.HandleFunc("/{name}/groups/{group}", lr.authorized(auth.PermissionWrite, lr.DeleteGroupHandler)).
+		Methods(http.MethodDelete)
+
+	router.HandleFunc("/{name}/readers", lr.authorized(auth.PermissionRead, lr.ListReadersHandler)).
+		Methods(http.MethodGet)
+
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionWrite, lr.writable(lr.WriteWSHandler))).
 		Methods(http.MethodGet).
 		Headers("Upgrade", "websocket").
 		Headers("X-HTTP-Method-Override", "POST")
 
-	router.HandleFunc("/{name}/records", lr.WriteWSHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionWrite, lr.writable(lr.WriteWSHandler))).
 		Methods(http.MethodPost).
 		Headers("Upgrade", "websocket")
 
-	router.HandleFunc("/{name}/records", lr.ReadWSHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionRead, lr.ReadWSHandler)).
 		Methods(http.MethodGet).
 		Headers("Upgrade", "websocket")
 
-	router.HandleFunc("/{name}/records", lr.WriteTCPHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionWrite, lr.writable(lr.WriteTCPHandler))).
 		Methods(http.MethodPost).
 		Headers("Connection", "upgrade").
 		Headers("Upgrade", api.StyxProtocolString)
 
-	router.HandleFunc("/{name}/records", lr.ReadTCPHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionRead, lr.ReadTCPHandler)).
 		Methods(http.MethodGet).
 		Headers("Connection", "upgrade").
 		Headers("Upgrade", api.StyxProtocolString)
 
-	router.HandleFunc("/{name}/records", lr.WriteLinesHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionWrite, lr.writable(lr.WriteLinesHandler))).
 		Methods(http.MethodPost).
 		MatcherFunc(lr.WriteLinesMatcher)
 
-	router.HandleFunc("/{name}/records", lr.ReadLinesHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionRead, lr.ReadEventsHandler)).
+		Methods(http.MethodGet).
+		MatcherFunc(lr.ReadEventsMatcher)
+
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionRead, lr.ReadLinesHandler)).
 		Methods(http.MethodGet).
 		MatcherFunc(lr.ReadLinesMatcher)
 
-	router.HandleFunc("/{name}/records", lr.WriteBatchHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionWrite, lr.writable(lr.WriteBatchHandler))).
 		Methods(http.MethodPost).
 		Headers("Content-Type", api.RecordBinaryMediaType)
 
-	router.HandleFunc("/{name}/records", lr.ReadBatchHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionRead, lr.ReadBatchHandler)).
 		Methods(http.MethodGet).
 		Headers("Accept", api.RecordBinaryMediaType)
 
-	router.HandleFunc("/{name}/records", lr.WriteHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionWrite, lr.writable(lr.WriteHandler))).
 		Methods(http.MethodPost).
 		Headers("Content-Type", "application/octet-stream")
 
-	router.HandleFunc("/{name}/records", lr.WriteHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionWrite, lr.writable(lr.WriteHandler))).
 		Methods(http.MethodPost)
 
-	router.HandleFunc("/{name}/records", lr.ReadHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionRead, lr.ReadHandler)).
 		Methods(http.MethodGet).
 		Headers("Accept", "application/octet-stream")
 
-	router.HandleFunc("/{name}/records", lr.ReadHandler).
+	router.HandleFunc("/{name}/records", lr.authorized(auth.PermissionRead, lr.ReadHandler)).
 		Methods(http.MethodGet)
 
 	return lr
 }
+
+// writable rejects requests modifying logs when the server is a replication
+// follower, since its logs are only written by the replicator.
+func (lr *LogsRouter) writable(handler http.HandlerFunc) (h http.HandlerFunc) {
+
+	if lr.config.Replication == nil {
+		return handler
+	}
+
+	h = func(w http.ResponseWriter, r *http.Request) {
+		api.WriteError(w, http.StatusForbidden, api.ErrReadOnly)
+	}
+
+	return h
+}
+
+// authorized rejects requests whose token does not grant permission on the
+// requested log. The log name comes from the route, or from the name param
+// for routes creating logs.
+func (lr *LogsRouter) authorized(permission auth.Permission, handler http.HandlerFunc) (h http.HandlerFunc) {
+
+	if lr.config.Auth == nil {
+		return handler
+	}
+
+	h = func(w http.ResponseWriter, r *http.Request) {
+
+		name, ok := mux.Vars(r)["name"]
+		if !ok {
+			name = r.FormValue("name")
+		}
+
+		token := auth.FromContext(r.Context())
+
+		if token == nil || !token.Allows(permission, name) {
+			api.WriteError(w, http.StatusForbidden, api.ErrForbidden)
+			return
+		}
+
+		handler(w, r)
+	}
+
+	return h
+}
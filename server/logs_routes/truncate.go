@@ -24,7 +24,31 @@ func (lr *LogsRouter) TruncateHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
 
-	err := lr.manager.TruncateLog(name)
+	params := api.TruncateLogParams{}
+	query := r.URL.Query()
+
+	err := lr.schemaDecoder.Decode(&params, query)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	err = params.Validate()
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	if params.BeforePosition == nil && params.BeforeTimestamp == nil {
+		err = lr.manager.TruncateLog(name)
+	} else {
+		err = lr.truncateBefore(name, params)
+	}
+
 	if err == logman.ErrNotExist {
 		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
 		logger.Debug(err)
@@ -37,11 +61,42 @@ func (lr *LogsRouter) TruncateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err == logman.ErrUnavailable {
+		api.WriteError(w, http.StatusBadRequest, api.ErrLogNotAvailable)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	managedLog, err := lr.manager.GetLog(name)
 	if err != nil {
 		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
 		logger.Debug(err)
 		return
 	}
 
-	api.WriteResponse(w, http.StatusOK, nil)
+	logInfo := managedLog.Stat()
+
+	api.WriteResponse(w, http.StatusOK, api.TruncateLogResponse(logInfo))
+}
+
+// truncateBefore deletes the segments of a log preceding the boundary set in
+// params.
+func (lr *LogsRouter) truncateBefore(name string, params api.TruncateLogParams) (err error) {
+
+	managedLog, err := lr.manager.GetLog(name)
+	if err != nil {
+		return err
+	}
+
+	if params.BeforeTimestamp != nil {
+		return managedLog.TruncateBeforeTimestamp(*params.BeforeTimestamp)
+	}
+
+	return managedLog.TruncateBefore(*params.BeforePosition)
 }
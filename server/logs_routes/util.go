@@ -16,6 +16,9 @@ import (
 	"strings"
 
 	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/api/tcp"
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/logman"
 
 	"github.com/gorilla/websocket"
 )
@@ -23,9 +26,49 @@ import (
 var (
 	ErrUnsupportedUpgrade    = errors.New("server: protocol doesn't support connection upgrade")
 	ErrDataSentBeforeUpgrade = errors.New("server: client sent data before upgrade completion")
+	ErrUnsupportedFlush      = errors.New("server: response writer doesn't support flushing")
 )
 
-func UpgradeTCP(w http.ResponseWriter) (c *net.TCPConn, err error) {
+// seekLogReader seeks the log reader, resolving positions relative to the
+// committed position of a consumer group.
+func seekLogReader(lr *log.LogReader, ml *logman.Log, group string, position int64, whence log.Whence) (err error) {
+
+	if whence == api.SeekCommitted {
+		committed, err := ml.CommittedPosition(group)
+		if err != nil {
+			return err
+		}
+
+		position += committed
+		whence = log.SeekOrigin
+	}
+
+	err = lr.Seek(position, whence)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// producer stamps the records written by a request with the producer id and
+// consecutive sequences.
+type producer struct {
+	id       string
+	sequence int64
+}
+
+func (p *producer) stamp(r *log.Record) {
+
+	if p.id == "" {
+		return
+	}
+
+	r.SetProducer(p.id, p.sequence)
+	p.sequence++
+}
+
+func UpgradeTCP(w http.ResponseWriter) (c tcp.Conn, err error) {
 
 	hj, ok := w.(http.Hijacker)
 	if !ok {
@@ -51,7 +94,46 @@ func UpgradeTCP(w http.ResponseWriter) (c *net.TCPConn, err error) {
 		return nil, err
 	}
 
-	return conn.(*net.TCPConn), nil
+	// Hijacked connections are either a *net.TCPConn or a *tls.Conn
+	// when the server uses TLS.
+	return conn.(tcp.Conn), nil
+}
+
+// setSocketBuffers sets the socket buffer sizes of plain TCP connections.
+// TLS connections keep the system default sizes.
+// hasCapability reports whether the client announced support for an optional
+// Styx protocol feature in the capabilities header.
+func hasCapability(r *http.Request, capability string) (has bool) {
+
+	for _, value := range r.Header.Values(api.CapabilitiesHeaderName) {
+		for _, c := range strings.Split(value, ",") {
+			if strings.TrimSpace(c) == capability {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func setSocketBuffers(conn tcp.Conn, readBufferSize int, writeBufferSize int) (err error) {
+
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+
+	err = tcpConn.SetReadBuffer(readBufferSize)
+	if err != nil {
+		return err
+	}
+
+	err = tcpConn.SetWriteBuffer(writeBufferSize)
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func UpgradeWebsocket(w http.ResponseWriter, r *http.Request, allowedOrigins []string, readBufferSize int, writeBufferSize int)  (conn *websocket.Conn, err error) {
@@ -68,6 +150,7 @@ func UpgradeWebsocket(w http.ResponseWriter, r *http.Request, allowedOrigins []s
 		},
 		ReadBufferSize: readBufferSize,
 		WriteBufferSize: writeBufferSize,
+		Subprotocols: []string{api.RecordsWSSubprotocol},
 	}
 
 	conn, err = upgrader.Upgrade(w, r, nil)
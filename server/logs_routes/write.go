@@ -19,6 +19,7 @@ import (
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
 	"gitlab.com/dataptive/styx/recio"
+	"gitlab.com/dataptive/styx/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -43,7 +44,51 @@ func (lr *LogsRouter) WriteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if recordSize == 0 {
+	params := api.WriteRecordParams{}
+	query := r.URL.Query()
+
+	err = lr.schemaDecoder.Decode(&params, query)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	record := log.Record{}
+
+	err = api.ReadRecordHeaders(r.Header, &record)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	id, sequence, err := api.ReadProducerHeaders(r.Header)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	if id != "" {
+		record.SetProducer(id, sequence)
+	}
+
+	// Producer headers may also be set as record headers.
+	_, _, err = record.Producer()
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	empty := recordSize == 0 && !record.HasMetadata()
+
+	if empty && params.ExpectedPosition == nil {
 		api.WriteResponse(w, http.StatusOK, api.WriteRecordResponse{})
 		return
 	}
@@ -74,10 +119,40 @@ func (lr *LogsRouter) WriteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if params.ExpectedPosition != nil {
+		logWriter.ExpectPosition(*params.ExpectedPosition)
+	}
+
+	// Empty conditional writes only check the log end position.
+	if empty {
+		_, err = logWriter.Acquire()
+		logWriter.Close()
+
+		if err == log.ErrPositionMismatch {
+			api.WriteError(w, http.StatusConflict, api.ErrPositionMismatch)
+			logger.Debug(err)
+			return
+		}
+
+		if err != nil {
+			api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+			logger.Debug(err)
+			return
+		}
+
+		api.WriteResponse(w, http.StatusOK, api.WriteRecordResponse{})
+		return
+	}
+
+	parent, _ := tracing.RequestContext(r.Header)
+	tracker := lr.tracer.NewSyncTracker(name, parent)
+	defer tracker.Close()
+
 	var progress log.SyncProgress
 
 	logWriter.HandleSync(func(syncProgress log.SyncProgress) {
 		progress = syncProgress
+		tracker.Synced(syncProgress)
 	})
 
 	payload, err := ioutil.ReadAll(r.Body)
@@ -87,16 +162,42 @@ func (lr *LogsRouter) WriteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	record := log.Record(payload)
+	record.Payload = payload
+
+	span := tracker.Start(&record)
+
+	// Records already written by the producer are acknowledged without
+	// being written again.
+	n, err := logWriter.Write(&record)
+
+	duplicate := err == log.ErrDuplicate
+	if duplicate {
+		tracker.Duplicate(span)
+		err = nil
+	}
+
+	if err == log.ErrPositionMismatch {
+		span.SetError(err)
+		span.End()
+		logWriter.Close()
+		api.WriteError(w, http.StatusConflict, api.ErrPositionMismatch)
+		logger.Debug(err)
+		return
+	}
 
-	_, err = logWriter.Write(&record)
 	if err != nil {
+		span.SetError(err)
+		span.End()
 		logWriter.Close()
 		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
 		logger.Debug(err)
 		return
 	}
 
+	if !duplicate {
+		tracker.Written(span, n)
+	}
+
 	err = logWriter.Flush()
 	if err != nil {
 		logWriter.Close()
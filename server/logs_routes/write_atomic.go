@@ -0,0 +1,125 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package logs_routes
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/log"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/recio"
+	"gitlab.com/dataptive/styx/server/auth"
+)
+
+// WriteAtomicHandler writes a batch of records to several logs, all or none.
+// Each record names its log in the Styx-Log header, which is not written.
+func (lr *LogsRouter) WriteAtomicHandler(w http.ResponseWriter, r *http.Request) {
+
+	bufferedReader := recio.NewBufferedReader(r.Body, lr.config.HTTPReadBufferSize, recio.ModeAuto)
+
+	batch, err := readAtomicBatch(bufferedReader)
+	if err == api.ErrMissingLog {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	token := auth.FromContext(r.Context())
+
+	for name := range batch {
+		if token != nil && !token.Allows(auth.PermissionWrite, name) {
+			api.WriteError(w, http.StatusForbidden, api.ErrForbidden)
+			return
+		}
+	}
+
+	positions, err := lr.manager.WriteBatch(batch)
+	if err == logman.ErrNotExist {
+		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
+		logger.Debug(err)
+		return
+	}
+
+	if err == logman.ErrUnavailable {
+		api.WriteError(w, http.StatusBadRequest, api.ErrLogNotAvailable)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	response := api.WriteAtomicResponse(positions)
+
+	api.WriteResponse(w, http.StatusOK, response)
+}
+
+// readAtomicBatch reads records and groups them by log, keeping their order.
+func readAtomicBatch(br *recio.BufferedReader) (batch map[string][]*log.Record, err error) {
+
+	batch = make(map[string][]*log.Record)
+
+	for {
+		record := &log.Record{}
+
+		_, err := br.Read(record)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		name := ""
+		headers := []log.Header{}
+
+		api.CanonicalRecordHeaders(record)
+
+		for _, h := range record.Headers {
+			if strings.EqualFold(h.Name, api.AtomicLogHeaderName) {
+				name = h.Value
+				continue
+			}
+
+			headers = append(headers, h)
+		}
+
+		if name == "" {
+			return nil, api.ErrMissingLog
+		}
+
+		// Decoded records share the reader buffer.
+		if record.Key != nil {
+			record.Key = append([]byte{}, record.Key...)
+		}
+
+		record.Payload = append([]byte{}, record.Payload...)
+		record.Headers = headers
+
+		batch[name] = append(batch[name], record)
+	}
+
+	return batch, nil
+}
@@ -10,14 +10,18 @@
 package logs_routes
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 	"net/http"
 
 	"gitlab.com/dataptive/styx/api"
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
 	"gitlab.com/dataptive/styx/recio"
+	"gitlab.com/dataptive/styx/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -27,6 +31,30 @@ func (lr *LogsRouter) WriteBatchHandler(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	name := vars["name"]
 
+	params := api.WriteRecordsBatchParams{}
+	query := r.URL.Query()
+
+	err := lr.schemaDecoder.Decode(&params, query)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	id, sequence, err := api.ReadProducerHeaders(r.Header)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	p := &producer{
+		id:       id,
+		sequence: sequence,
+	}
+
 	managedLog, err := lr.manager.GetLog(name)
 	if err == logman.ErrNotExist {
 		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
@@ -40,7 +68,22 @@ func (lr *LogsRouter) WriteBatchHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	bufferedReader := recio.NewBufferedReader(r.Body, lr.config.HTTPReadBufferSize, recio.ModeManual)
+	var body io.Reader = r.Body
+
+	// Conditional writes keep other writers out until completed. Read the
+	// whole body first, so that slow clients don't block them.
+	if params.ExpectedPosition != nil {
+		buffered, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+			logger.Debug(err)
+			return
+		}
+
+		body = bytes.NewReader(buffered)
+	}
+
+	bufferedReader := recio.NewBufferedReader(body, lr.config.HTTPReadBufferSize, recio.ModeManual)
 
 	logWriter, err := managedLog.NewWriter(recio.ModeAuto)
 	if err == logman.ErrUnavailable {
@@ -55,13 +98,48 @@ func (lr *LogsRouter) WriteBatchHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if params.ExpectedPosition != nil {
+		logWriter.ExpectPosition(*params.ExpectedPosition)
+
+		// Check the position before writing, empty bodies included.
+		_, err = logWriter.Acquire()
+		if err == log.ErrPositionMismatch {
+			logWriter.Close()
+			api.WriteError(w, http.StatusConflict, api.ErrPositionMismatch)
+			logger.Debug(err)
+			return
+		}
+
+		if err != nil {
+			logWriter.Close()
+			api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+			logger.Debug(err)
+			return
+		}
+	}
+
+	parent, _ := tracing.RequestContext(r.Header)
+	tracker := lr.tracer.NewSyncTracker(name, parent)
+	defer tracker.Close()
+
 	var progress = log.SyncProgress{}
 
 	logWriter.HandleSync(func(syncProgress log.SyncProgress) {
 		progress = syncProgress
+		tracker.Synced(syncProgress)
 	})
 
-	err = writeBatch(logWriter, bufferedReader)
+	lr.reporter.ReportProducers(metrics.ProtocolHTTP, 1)
+	defer lr.reporter.ReportProducers(metrics.ProtocolHTTP, -1)
+
+	err = writeBatch(logWriter, bufferedReader, p, tracker)
+	if err == log.ErrPositionMismatch {
+		logWriter.Close()
+		api.WriteError(w, http.StatusConflict, api.ErrPositionMismatch)
+		logger.Debug(err)
+		return
+	}
+
 	if err != nil {
 		logWriter.Close()
 		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
@@ -88,7 +166,7 @@ func (lr *LogsRouter) WriteBatchHandler(w http.ResponseWriter, r *http.Request)
 	api.WriteResponse(w, http.StatusOK, response)
 }
 
-func writeBatch(lw *log.FaninWriter, br *recio.BufferedReader) (err error) {
+func writeBatch(lw *log.FaninWriter, br *recio.BufferedReader, p *producer, st *tracing.SyncTracker) (err error) {
 
 	record := log.Record{}
 
@@ -117,10 +195,25 @@ func writeBatch(lw *log.FaninWriter, br *recio.BufferedReader) (err error) {
 			return err
 		}
 
-		_, err = lw.Write(&record)
+		api.CanonicalRecordHeaders(&record)
+
+		p.stamp(&record)
+
+		span := st.Start(&record)
+
+		n, err := lw.Write(&record)
+		if err == log.ErrDuplicate {
+			st.Duplicate(span)
+			continue
+		}
+
 		if err != nil {
+			span.SetError(err)
+			span.End()
 			return err
 		}
+
+		st.Written(span, n)
 	}
 
 	err = lw.Flush()
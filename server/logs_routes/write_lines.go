@@ -18,8 +18,10 @@ import (
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
 	"gitlab.com/dataptive/styx/recio"
 	"gitlab.com/dataptive/styx/recio/recioutil"
+	"gitlab.com/dataptive/styx/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -58,6 +60,19 @@ func (lr *LogsRouter) WriteLinesHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	id, sequence, err := api.ReadProducerHeaders(r.Header)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	p := &producer{
+		id:       id,
+		sequence: sequence,
+	}
+
 	managedLog, err := lr.manager.GetLog(name)
 	if err == logman.ErrNotExist {
 		api.WriteError(w, http.StatusNotFound, api.ErrLogNotFound)
@@ -87,13 +102,21 @@ func (lr *LogsRouter) WriteLinesHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	parent, _ := tracing.RequestContext(r.Header)
+	tracker := lr.tracer.NewSyncTracker(name, parent)
+	defer tracker.Close()
+
 	var progress = log.SyncProgress{}
 
 	logWriter.HandleSync(func(syncProgress log.SyncProgress) {
 		progress = syncProgress
+		tracker.Synced(syncProgress)
 	})
 
-	err = writeLines(logWriter, lineReader, bufferedReader)
+	lr.reporter.ReportProducers(metrics.ProtocolHTTP, 1)
+	defer lr.reporter.ReportProducers(metrics.ProtocolHTTP, -1)
+
+	err = writeLines(logWriter, lineReader, bufferedReader, p, tracker)
 	if err != nil {
 		logWriter.Close()
 		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
@@ -121,9 +144,10 @@ func (lr *LogsRouter) WriteLinesHandler(w http.ResponseWriter, r *http.Request)
 
 }
 
-func writeLines(lw *log.FaninWriter, lr *recioutil.LineReader, br *recio.BufferedReader) (err error) {
+func writeLines(lw *log.FaninWriter, lr *recioutil.LineReader, br *recio.BufferedReader, p *producer, st *tracing.SyncTracker) (err error) {
 
 	line := &recioutil.Line{}
+	record := &log.Record{}
 
 	for {
 		_, err := lr.Read(line)
@@ -150,10 +174,26 @@ func writeLines(lw *log.FaninWriter, lr *recioutil.LineReader, br *recio.Buffere
 			return err
 		}
 
-		_, err = lw.Write((*log.Record)(line))
+		// Headers set for the previous line must not leak to this one.
+		record.Headers = nil
+		record.Payload = []byte(*line)
+		p.stamp(record)
+
+		span := st.Start(record)
+
+		n, err := lw.Write(record)
+		if err == log.ErrDuplicate {
+			st.Duplicate(span)
+			continue
+		}
+
 		if err != nil {
+			span.SetError(err)
+			span.End()
 			return err
 		}
+
+		st.Written(span, n)
 	}
 
 	err = lw.Flush()
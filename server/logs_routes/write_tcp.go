@@ -19,7 +19,9 @@ import (
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
 	"gitlab.com/dataptive/styx/recio"
+	"gitlab.com/dataptive/styx/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -80,12 +82,7 @@ func (lr *LogsRouter) WriteTCPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = conn.SetReadBuffer(lr.config.TCPReadBufferSize)
-	if err != nil {
-		logger.Warn(err)
-	}
-
-	err = conn.SetWriteBuffer(lr.config.TCPWriteBufferSize)
+	err = setSocketBuffers(conn, lr.config.TCPReadBufferSize, lr.config.TCPWriteBufferSize)
 	if err != nil {
 		logger.Warn(err)
 	}
@@ -99,10 +96,16 @@ func (lr *LogsRouter) WriteTCPHandler(w http.ResponseWriter, r *http.Request) {
 		tr.Close()
 	})
 
+	parent, _ := tracing.RequestContext(r.Header)
+	tracker := lr.tracer.NewSyncTracker(name, parent)
+	defer tracker.Close()
+
 	errored := false
 
 	logWriter.HandleSync(func(progress log.SyncProgress) {
 
+		tracker.Synced(progress)
+
 		// If an error occurred during copy we
 		// wont try to send ack back to client.
 		if errored {
@@ -130,7 +133,10 @@ func (lr *LogsRouter) WriteTCPHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	})
 
-	err = writeTCP(logWriter, tr)
+	lr.reporter.ReportProducers(metrics.ProtocolTCP, 1)
+	defer lr.reporter.ReportProducers(metrics.ProtocolTCP, -1)
+
+	err = writeTCP(logWriter, tr, tracker)
 	if err != nil {
 
 		errored = true
@@ -164,7 +170,7 @@ func (lr *LogsRouter) WriteTCPHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func writeTCP(lw *log.FaninWriter, tr *tcp.TCPReader) (err error) {
+func writeTCP(lw *log.FaninWriter, tr *tcp.TCPReader, st *tracing.SyncTracker) (err error) {
 
 	record := log.Record{}
 
@@ -193,10 +199,23 @@ func writeTCP(lw *log.FaninWriter, tr *tcp.TCPReader) (err error) {
 			return err
 		}
 
-		_, err = lw.Write(&record)
+		api.CanonicalRecordHeaders(&record)
+
+		span := st.Start(&record)
+
+		n, err := lw.Write(&record)
+		if err == log.ErrDuplicate {
+			st.Duplicate(span)
+			continue
+		}
+
 		if err != nil {
+			span.SetError(err)
+			span.End()
 			return err
 		}
+
+		st.Written(span, n)
 	}
 
 	err = lw.Flush()
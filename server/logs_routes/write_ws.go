@@ -16,7 +16,9 @@ import (
 	"gitlab.com/dataptive/styx/log"
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
 	"gitlab.com/dataptive/styx/recio"
+	"gitlab.com/dataptive/styx/tracing"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
@@ -63,7 +65,16 @@ func (lr *LogsRouter) WriteWSHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = writeWS(logWriter, conn)
+	parent, _ := tracing.RequestContext(r.Header)
+	tracker := lr.tracer.NewSyncTracker(name, parent)
+	defer tracker.Close()
+
+	logWriter.HandleSync(tracker.Synced)
+
+	lr.reporter.ReportProducers(metrics.ProtocolWS, 1)
+	defer lr.reporter.ReportProducers(metrics.ProtocolWS, -1)
+
+	err = writeWS(logWriter, conn, tracker)
 	if err != nil {
 		logger.Debug(err)
 
@@ -95,10 +106,14 @@ func (lr *LogsRouter) WriteWSHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func writeWS(lw *log.FaninWriter, ws *websocket.Conn) (err error) {
+func writeWS(lw *log.FaninWriter, ws *websocket.Conn, st *tracing.SyncTracker) (err error) {
 
 	record := log.Record{}
 
+	// Clients negotiating the records subprotocol send encoded records
+	// along with their metadata, others only send payloads.
+	decode := ws.Subprotocol() == api.RecordsWSSubprotocol
+
 	for {
 		_, p, err := ws.ReadMessage()
 		if err != nil {
@@ -109,13 +124,37 @@ func writeWS(lw *log.FaninWriter, ws *websocket.Conn) (err error) {
 			return err
 		}
 
-		record = log.Record(p)
+		if decode {
+			n, err := record.Decode(p)
+			if err != nil {
+				return err
+			}
+
+			if n != len(p) {
+				return log.ErrCorrupt
+			}
+
+			api.CanonicalRecordHeaders(&record)
+		} else {
+			record = log.Record{Payload: p}
+		}
+
+		span := st.Start(&record)
+
+		n, err := lw.Write(&record)
+		if err == log.ErrDuplicate {
+			st.Duplicate(span)
+			continue
+		}
 
-		_, err = lw.Write(&record)
 		if err != nil {
+			span.SetError(err)
+			span.End()
 			return err
 		}
 
+		st.Written(span, n)
+
 		err = lw.Flush()
 		if err != nil {
 			return err
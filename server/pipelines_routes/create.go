@@ -0,0 +1,88 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package pipelines_routes
+
+import (
+	"net/http"
+
+	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/pipeline"
+)
+
+func (pr *PipelinesRouter) CreateHandler(w http.ResponseWriter, r *http.Request) {
+
+	form := api.CreatePipelineForm{}
+
+	err := r.ParseForm()
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	err = pr.schemaDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	config := pipeline.Config{
+		Name:        form.Name,
+		Source:      form.Source,
+		Destination: form.Destination,
+		Transform:   form.Transform,
+		Path:        form.Path,
+		Value:       form.Value,
+		Pattern:     form.Pattern,
+		Rate:        form.Rate,
+	}
+
+	pi, err := pr.manager.CreatePipeline(config)
+	if err == pipeline.ErrExist {
+		api.WriteError(w, http.StatusBadRequest, api.ErrPipelineExist)
+		logger.Debug(err)
+		return
+	}
+
+	if err == pipeline.ErrInvalidName {
+		api.WriteError(w, http.StatusBadRequest, api.ErrPipelineInvalidName)
+		logger.Debug(err)
+		return
+	}
+
+	if err == pipeline.ErrInvalidLog {
+		api.WriteError(w, http.StatusBadRequest, api.ErrLogInvalidName)
+		logger.Debug(err)
+		return
+	}
+
+	if err == pipeline.ErrSameLog ||
+		err == pipeline.ErrInvalidTransform ||
+		err == pipeline.ErrMissingPath ||
+		err == pipeline.ErrInvalidPattern ||
+		err == pipeline.ErrInvalidRate {
+
+		er := api.NewParamsError(err)
+		api.WriteError(w, http.StatusBadRequest, er)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	api.WriteResponse(w, http.StatusOK, api.CreatePipelineResponse(pipelineInfo(pi)))
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package pipelines_routes
+
+import (
+	"net/http"
+
+	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/logger"
+	"gitlab.com/dataptive/styx/pipeline"
+
+	"github.com/gorilla/mux"
+)
+
+func (pr *PipelinesRouter) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	err := pr.manager.DeletePipeline(name)
+	if err == pipeline.ErrNotExist {
+		api.WriteError(w, http.StatusNotFound, api.ErrPipelineNotFound)
+		logger.Debug(err)
+		return
+	}
+
+	if err == pipeline.ErrStatic {
+		api.WriteError(w, http.StatusBadRequest, api.ErrPipelineStatic)
+		logger.Debug(err)
+		return
+	}
+
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, api.ErrUnknownError)
+		logger.Debug(err)
+		return
+	}
+
+	api.WriteResponse(w, http.StatusOK, nil)
+}
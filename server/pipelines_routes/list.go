@@ -0,0 +1,27 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package pipelines_routes
+
+import (
+	"net/http"
+
+	"gitlab.com/dataptive/styx/api"
+)
+
+func (pr *PipelinesRouter) ListHandler(w http.ResponseWriter, r *http.Request) {
+
+	response := api.ListPipelinesResponse{}
+
+	for _, pi := range pr.manager.ListPipelines() {
+		response = append(response, pipelineInfo(pi))
+	}
+
+	api.WriteResponse(w, http.StatusOK, response)
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package pipelines_routes
+
+import (
+	"net/http"
+
+	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/pipeline"
+	"gitlab.com/dataptive/styx/server/auth"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+)
+
+type PipelinesRouter struct {
+	router        *mux.Router
+	manager       *pipeline.Manager
+	schemaDecoder *schema.Decoder
+}
+
+func RegisterRoutes(router *mux.Router, manager *pipeline.Manager) (pr *PipelinesRouter) {
+
+	var decoder = schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+
+	pr = &PipelinesRouter{
+		router:        router,
+		manager:       manager,
+		schemaDecoder: decoder,
+	}
+
+	router.HandleFunc("", pr.ListHandler).
+		Methods(http.MethodGet)
+
+	router.HandleFunc("", pr.administrable(pr.CreateHandler)).
+		Methods(http.MethodPost)
+
+	router.HandleFunc("/{name}", pr.GetHandler).
+		Methods(http.MethodGet)
+
+	router.HandleFunc("/{name}", pr.administrable(pr.DeleteHandler)).
+		Methods(http.MethodDelete)
+
+	return pr
+}
+
+// administrable restricts routes changing pipelines, which read and write
+// any log, to tokens with admin permission on all logs.
+func (pr *PipelinesRouter) administrable(handler http.HandlerFunc) (h http.HandlerFunc) {
+
+	h = func(w http.ResponseWriter, r *http.Request) {
+
+		token := auth.FromContext(r.Context())
+
+		if token != nil && !token.AllowsAll(auth.PermissionAdmin) {
+			api.WriteError(w, http.StatusForbidden, api.ErrForbidden)
+			return
+		}
+
+		handler(w, r)
+	}
+
+	return h
+}
+
+func pipelineInfo(pi pipeline.PipelineInfo) (info api.PipelineInfo) {
+
+	info = api.PipelineInfo{
+		Name:        pi.Name,
+		Source:      pi.Source,
+		Destination: pi.Destination,
+		Transform:   pi.Transform,
+		Path:        pi.Path,
+		Value:       pi.Value,
+		Pattern:     pi.Pattern,
+		Rate:        pi.Rate,
+		Static:      pi.Static,
+		State:       pi.State,
+		Position:    pi.Position,
+		Lag:         pi.Lag,
+	}
+
+	return info
+}
@@ -0,0 +1,36 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package replication_routes
+
+import (
+	"net/http"
+
+	"gitlab.com/dataptive/styx/replication"
+
+	"github.com/gorilla/mux"
+)
+
+type ReplicationRouter struct {
+	router     *mux.Router
+	replicator *replication.Replicator
+}
+
+func RegisterRoutes(router *mux.Router, replicator *replication.Replicator) (rr *ReplicationRouter) {
+
+	rr = &ReplicationRouter{
+		router:     router,
+		replicator: replicator,
+	}
+
+	router.HandleFunc("", rr.StatusHandler).
+		Methods(http.MethodGet)
+
+	return rr
+}
@@ -0,0 +1,36 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package replication_routes
+
+import (
+	"net/http"
+
+	"gitlab.com/dataptive/styx/api"
+)
+
+func (rr *ReplicationRouter) StatusHandler(w http.ResponseWriter, r *http.Request) {
+
+	response := api.ReplicationStatusResponse{
+		LeaderAddress: rr.replicator.LeaderAddress(),
+		Logs:          []api.ReplicaInfo{},
+	}
+
+	for _, replicaInfo := range rr.replicator.Stat() {
+		response.Logs = append(response.Logs, api.ReplicaInfo{
+			Name:           replicaInfo.Name,
+			State:          string(replicaInfo.State),
+			LeaderPosition: replicaInfo.LeaderPosition,
+			Position:       replicaInfo.Position,
+			Lag:            replicaInfo.Lag,
+		})
+	}
+
+	api.WriteResponse(w, http.StatusOK, response)
+}
@@ -10,24 +10,48 @@
 package server
 
 import (
+	"bufio"
+	"errors"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 
 	"gitlab.com/dataptive/styx/api"
+	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
+	"gitlab.com/dataptive/styx/metrics"
+	"gitlab.com/dataptive/styx/pipeline"
+	"gitlab.com/dataptive/styx/replication"
+	"gitlab.com/dataptive/styx/server/auth"
 	"gitlab.com/dataptive/styx/server/config"
+	"gitlab.com/dataptive/styx/server/health_routes"
 	"gitlab.com/dataptive/styx/server/logs_routes"
+	"gitlab.com/dataptive/styx/server/pipelines_routes"
+	"gitlab.com/dataptive/styx/server/replication_routes"
+	"gitlab.com/dataptive/styx/tracing"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
 )
 
+const (
+	healthPathPrefix = "/health/"
+)
+
+var (
+	ErrUnsupportedHijack = errors.New("server: response writer does not support hijacking")
+)
+
 type Router struct {
-	router http.Handler
-	config config.Config
+	router        http.Handler
+	config        config.Config
+	authenticator *auth.Authenticator
+	reporter      metrics.Reporter
 }
 
-func NewRouter(logManager *logman.LogManager, config config.Config) (r *Router) {
+func NewRouter(logManager *logman.LogManager, replicator *replication.Replicator, pipelineManager *pipeline.Manager, reporter metrics.Reporter, tracer *tracing.Tracer, config config.Config) (r *Router) {
 
 	router := mux.NewRouter()
 
@@ -35,32 +59,182 @@ func NewRouter(logManager *logman.LogManager, config config.Config) (r *Router)
 	router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
 
 	r = &Router{
-		router: router,
-		config: config,
+		router:   router,
+		config:   config,
+		reporter: reporter,
+	}
+
+	router.Use(r.countRequests)
+
+	logs_routes.RegisterRoutes(router.PathPrefix("/logs").Subrouter(), logManager, reporter, tracer, config)
+
+	if replicator != nil {
+		replicationRouter := router.PathPrefix("/replication").Subrouter()
+		replicationRouter.Use(r.authorizeAll)
+
+		replication_routes.RegisterRoutes(replicationRouter, replicator)
+	}
+
+	if pipelineManager != nil {
+		pipelinesRouter := router.PathPrefix("/pipelines").Subrouter()
+		pipelinesRouter.Use(r.authorizeAll)
+
+		pipelines_routes.RegisterRoutes(pipelinesRouter, pipelineManager)
 	}
 
-	logs_routes.RegisterRoutes(router.PathPrefix("/logs").Subrouter(), logManager, config)
+	healthRouter := health_routes.RegisterRoutes(router.PathPrefix("/health").Subrouter(), logManager, config, Version)
+
+	router.Handle("/info", r.authorizeAll(http.HandlerFunc(healthRouter.InfoHandler))).
+		Methods(http.MethodGet)
 
-	router.Handle("/metrics", promhttp.Handler())
+	router.Handle("/metrics", r.authorizeAll(promhttp.Handler()))
 
 	c := cors.New(cors.Options{
 		AllowedOrigins:   r.config.CORSAllowedOrigins,
 		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "DELETE", "PATCH"},
-		AllowedHeaders:   []string{},
+		AllowedHeaders:   []string{"Authorization"},
 		AllowCredentials: false,
 		MaxAge:           0,
 	})
 
 	router.Use(c.Handler)
 
+	if config.Auth != nil {
+		r.authenticator = auth.NewAuthenticator(*config.Auth)
+		router.Use(r.authenticate)
+	}
+
 	return r
 }
 
+// authenticate rejects requests without a valid token, and attaches the
+// token to the request context for routes to check its permissions.
+func (r *Router) authenticate(next http.Handler) (h http.Handler) {
+
+	h = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		// Health probes are sent by orchestrators, which usually
+		// don't hold tokens.
+		if strings.HasPrefix(req.URL.Path, healthPathPrefix) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		token, err := r.authenticator.Authenticate(req)
+		if err != nil {
+			api.WriteError(w, http.StatusUnauthorized, api.ErrUnauthorized)
+			logger.Debug(err)
+			return
+		}
+
+		ctx := auth.NewContext(req.Context(), token)
+
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+
+	return h
+}
+
+// authorizeAll restricts routes that are not specific to a log to tokens
+// allowed to read all logs.
+func (r *Router) authorizeAll(next http.Handler) (h http.Handler) {
+
+	h = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		token := auth.FromContext(req.Context())
+
+		if token != nil && !token.AllowsAll(auth.PermissionRead) {
+			api.WriteError(w, http.StatusForbidden, api.ErrForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+
+	return h
+}
+
+// countRequests reports requests by route, method and response status code.
+func (r *Router) countRequests(next http.Handler) (h http.Handler) {
+
+	h = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		sw := &statusWriter{
+			ResponseWriter: w,
+			status:         0,
+		}
+
+		next.ServeHTTP(sw, req)
+
+		route, err := mux.CurrentRoute(req).GetPathTemplate()
+		if err != nil {
+			route = req.URL.Path
+		}
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		r.reporter.ReportRequest(route, req.Method, status)
+	})
+
+	return h
+}
+
 func (r *Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
 	r.router.ServeHTTP(rw, req)
 }
 
+// openingRouter answers health probes while the server opens its logs, and
+// hands requests over to the router once logs are open.
+type openingRouter struct {
+	router http.Handler
+	next   http.Handler
+	lock   sync.RWMutex
+}
+
+func newOpeningRouter(config config.Config) (or *openingRouter) {
+
+	router := mux.NewRouter()
+
+	router.NotFoundHandler = http.HandlerFunc(notReadyHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
+
+	health_routes.RegisterOpeningRoutes(router.PathPrefix("/health").Subrouter(), config, Version)
+
+	or = &openingRouter{
+		router: router,
+		next:   nil,
+	}
+
+	return or
+}
+
+// open sends all further requests to next.
+func (or *openingRouter) open(next http.Handler) {
+
+	or.lock.Lock()
+	defer or.lock.Unlock()
+
+	or.next = next
+}
+
+func (or *openingRouter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+
+	or.lock.RLock()
+	next := or.next
+	or.lock.RUnlock()
+
+	if next == nil {
+		or.router.ServeHTTP(rw, req)
+		return
+	}
+
+	next.ServeHTTP(rw, req)
+}
+
 // TODO: Panic handler?
 
 func notFoundHandler(w http.ResponseWriter, r *http.Request) {
@@ -68,7 +242,63 @@ func notFoundHandler(w http.ResponseWriter, r *http.Request) {
 	api.WriteError(w, http.StatusNotFound, api.ErrNotFound)
 }
 
+func notReadyHandler(w http.ResponseWriter, r *http.Request) {
+
+	api.WriteError(w, http.StatusServiceUnavailable, api.ErrNotReady)
+}
+
 func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
 
 	api.WriteError(w, http.StatusMethodNotAllowed, api.ErrMethodNotAllowed)
 }
+
+// statusWriter records the response status code, while still allowing
+// routes to stream responses and upgrade connections.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+
+	if sw.status == 0 {
+		sw.status = status
+	}
+
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(p []byte) (n int, err error) {
+
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *statusWriter) Flush() {
+
+	flusher, ok := sw.ResponseWriter.(http.Flusher)
+	if !ok {
+		return
+	}
+
+	flusher.Flush()
+}
+
+func (sw *statusWriter) Hijack() (conn net.Conn, bufrw *bufio.ReadWriter, err error) {
+
+	hijacker, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrUnsupportedHijack
+	}
+
+	// Websocket upgrades write their response on the hijacked
+	// connection.
+	if sw.status == 0 {
+		sw.status = http.StatusSwitchingProtocols
+	}
+
+	return hijacker.Hijack()
+}
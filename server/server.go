@@ -11,8 +11,12 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -23,11 +27,20 @@ import (
 	"gitlab.com/dataptive/styx/logger"
 	"gitlab.com/dataptive/styx/logman"
 	"gitlab.com/dataptive/styx/metrics"
+	"gitlab.com/dataptive/styx/pipeline"
+	"gitlab.com/dataptive/styx/replication"
 	"gitlab.com/dataptive/styx/server/config"
+	"gitlab.com/dataptive/styx/tracing"
 )
 
 var (
+	// Version is set when building releases, with
+	// -ldflags "-X gitlab.com/dataptive/styx/server.Version=<version>".
+	Version = "dev"
+
 	ErrShutdownTimedOut = errors.New("server: shutdown timeout exceeded")
+	ErrInvalidClientCA  = errors.New("server: no certificate found in client CA file")
+	ErrFollowerPipeline = errors.New("server: pipelines can't run on replication followers")
 )
 
 type Server struct {
@@ -51,6 +64,17 @@ func (s *Server) Run() (err error) {
 
 	logger.Info("Starting Styx server")
 
+	var tlsConfig *tls.Config
+
+	useTLS := s.config.TLSCertFile != ""
+
+	if useTLS {
+		tlsConfig, err = s.tlsConfig()
+		if err != nil {
+			return err
+		}
+	}
+
 	err = s.acquireExecutionLock()
 	if err != nil {
 		if err != lockfile.ErrOrphaned {
@@ -72,18 +96,68 @@ func (s *Server) Run() (err error) {
 		return err
 	}
 
-	logManager, err := logman.NewLogManager(s.config.LogManager, metricsReporter)
-	if err != nil {
-		return err
+	var tracer *tracing.Tracer
+
+	if s.config.Tracing != nil {
+		logger.Infof("Exporting traces to %s", s.config.Tracing.Endpoint)
+
+		tracer, err = tracing.NewTracer(*s.config.Tracing)
+		if err != nil {
+			return err
+		}
 	}
 
-	router := NewRouter(logManager, s.config)
+	// Logs of replication followers are only written by the replicator.
+	if s.config.Replication != nil && len(s.config.Pipelines) > 0 {
+		return ErrFollowerPipeline
+	}
+
+	// Listen before opening logs, which can take a while, so that health
+	// probes are answered meanwhile.
+	openingRouter := newOpeningRouter(s.config)
 
 	server := &http.Server{
 		Addr:    s.config.BindAddress,
-		Handler: router,
+		Handler: openingRouter,
+	}
+
+	if useTLS {
+		server.TLSConfig = tlsConfig
+
+		// Disable HTTP/2, whose connections can't be hijacked for
+		// Websocket and Styx protocol upgrades.
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
 	}
 
+	listener, err := net.Listen("tcp", s.config.BindAddress)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Listening on %s", s.config.BindAddress)
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		if useTLS {
+			serveErr <- server.ServeTLS(listener, s.config.TLSCertFile, s.config.TLSKeyFile)
+		} else {
+			serveErr <- server.Serve(listener)
+		}
+	}()
+
+	logManager, replicator, pipelineManager, err := s.open(metricsReporter)
+	if err != nil {
+		server.Close()
+		return err
+	}
+
+	router := NewRouter(logManager, replicator, pipelineManager, metricsReporter, tracer, s.config)
+
+	openingRouter.open(router)
+
+	logger.Info("Logs opened")
+
 	done := make(chan struct{})
 
 	go func() {
@@ -99,6 +173,22 @@ func (s *Server) Run() (err error) {
 		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
 
+		// Stop replication before closing the logs it writes to.
+		if replicator != nil {
+			err = replicator.Close()
+			if err != nil {
+				logger.Fatal(err)
+			}
+		}
+
+		// Stop pipelines before closing the logs they read and write.
+		if pipelineManager != nil {
+			err = pipelineManager.Close()
+			if err != nil {
+				logger.Fatal(err)
+			}
+		}
+
 		// Close log manager first to ensure all log operations will unlock.
 		err = logManager.Close()
 		if err != nil {
@@ -120,6 +210,11 @@ func (s *Server) Run() (err error) {
 			logger.Fatal(err)
 		}
 
+		err = tracer.Close()
+		if err != nil {
+			logger.Fatal(err)
+		}
+
 		// Release and clear PID file
 		err = s.releaseExecutionLock()
 		if err != nil {
@@ -134,9 +229,7 @@ func (s *Server) Run() (err error) {
 		done <- struct{}{}
 	}()
 
-	logger.Infof("Listening on %s", s.config.BindAddress)
-
-	err = server.ListenAndServe()
+	err = <-serveErr
 	if err != nil && err != http.ErrServerClosed {
 		logger.Error(err)
 		return err
@@ -147,6 +240,63 @@ func (s *Server) Run() (err error) {
 	return nil
 }
 
+// open opens the logs, and starts replication and pipelines.
+func (s *Server) open(reporter metrics.Reporter) (logManager *logman.LogManager, replicator *replication.Replicator, pipelineManager *pipeline.Manager, err error) {
+
+	logManager, err = logman.NewLogManager(s.config.LogManager, reporter)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if s.config.Replication != nil {
+		logger.Infof("Replicating logs from %s", s.config.Replication.LeaderAddress)
+
+		replicator, err = replication.NewReplicator(*s.config.Replication, logManager)
+		if err != nil {
+			// Release the lock files and goroutines of opened logs.
+			logManager.Close()
+			return nil, nil, nil, err
+		}
+	}
+
+	if s.config.Replication == nil {
+		pipelineManager, err = pipeline.NewManager(s.config.Pipelines, s.config.LogManager.DataDirectory, logManager)
+		if err != nil {
+			logManager.Close()
+			return nil, nil, nil, err
+		}
+	}
+
+	return logManager, replicator, pipelineManager, nil
+}
+
+// tlsConfig returns the server TLS config, requiring client certificates
+// signed by the client CA when one is configured.
+func (s *Server) tlsConfig() (config *tls.Config, err error) {
+
+	config = &tls.Config{}
+
+	if s.config.TLSClientCAFile == "" {
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(s.config.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, ErrInvalidClientCA
+	}
+
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return config, nil
+}
+
 func (s *Server) acquireExecutionLock() (err error) {
 
 	err = s.pidFile.Acquire()
@@ -0,0 +1,172 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"gitlab.com/dataptive/styx/log"
+)
+
+const (
+	// TraceparentHeaderName is the W3C trace context header, used both
+	// as an HTTP header and as a record header.
+	TraceparentHeaderName = "traceparent"
+
+	traceparentVersion = "00"
+	flagSampled        = 0x01
+)
+
+var (
+	ErrInvalidTraceparent = errors.New("tracing: invalid traceparent")
+)
+
+// SpanContext identifies a span across processes.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// ParseTraceparent parses a W3C traceparent value, such as
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func ParseTraceparent(value string) (sc SpanContext, err error) {
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, ErrInvalidTraceparent
+	}
+
+	version := parts[0]
+
+	// Later versions may append fields, which are ignored.
+	if len(version) != 2 || version == "ff" || (version == traceparentVersion && len(parts) != 4) {
+		return sc, ErrInvalidTraceparent
+	}
+
+	_, err = hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	if err != nil || len(parts[1]) != 2*len(sc.TraceID) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	_, err = hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	if err != nil || len(parts[2]) != 2*len(sc.SpanID) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	flags := [1]byte{}
+
+	_, err = hex.Decode(flags[:], []byte(parts[3]))
+	if err != nil || len(parts[3]) != 2 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	return sc, nil
+}
+
+// Traceparent formats the span context as a W3C traceparent value.
+func (sc SpanContext) Traceparent() (value string) {
+
+	value = traceparentVersion +
+		"-" + hex.EncodeToString(sc.TraceID[:]) +
+		"-" + hex.EncodeToString(sc.SpanID[:]) +
+		"-" + hex.EncodeToString([]byte{sc.Flags})
+
+	return value
+}
+
+// IsValid reports whether both trace and span ids are set.
+func (sc SpanContext) IsValid() (valid bool) {
+
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+func (sc SpanContext) IsSampled() (sampled bool) {
+
+	return sc.Flags&flagSampled != 0
+}
+
+// RequestContext returns the span context found in the traceparent header of
+// an HTTP request. Invalid values are ignored, as the W3C specification
+// requires.
+func RequestContext(header http.Header) (sc SpanContext, found bool) {
+
+	value := header.Get(TraceparentHeaderName)
+	if value == "" {
+		return SpanContext{}, false
+	}
+
+	sc, err := ParseTraceparent(value)
+	if err != nil {
+		return SpanContext{}, false
+	}
+
+	return sc, true
+}
+
+// RecordContext returns the span context found in the traceparent header of
+// the record.
+func RecordContext(r *log.Record) (sc SpanContext, found bool) {
+
+	for _, h := range r.Headers {
+
+		if !strings.EqualFold(h.Name, TraceparentHeaderName) {
+			continue
+		}
+
+		sc, err := ParseTraceparent(h.Value)
+		if err != nil {
+			return SpanContext{}, false
+		}
+
+		return sc, true
+	}
+
+	return SpanContext{}, false
+}
+
+// SetRecordContext sets the traceparent header of the record, replacing
+// existing ones. Headers are copied so that the record can share them with
+// other records.
+func SetRecordContext(r *log.Record, sc SpanContext) {
+
+	headers := make([]log.Header, 0, len(r.Headers)+1)
+
+	for _, h := range r.Headers {
+		if strings.EqualFold(h.Name, TraceparentHeaderName) {
+			continue
+		}
+
+		headers = append(headers, h)
+	}
+
+	headers = append(headers, log.Header{Name: TraceparentHeaderName, Value: sc.Traceparent()})
+
+	r.Headers = headers
+}
+
+func newSpanID() (id [8]byte) {
+
+	// Ids only need to be unique, a failing random source leaves a zero
+	// id which makes the span invalid.
+	rand.Read(id[:])
+
+	return id
+}
@@ -0,0 +1,381 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitlab.com/dataptive/styx/logger"
+)
+
+const (
+	exportInterval  = time.Second
+	exportBatchSize = 512
+	exportTimeout   = 10 * time.Second
+	queueSize       = 4096
+
+	spanKindServer = 2
+
+	statusCodeOK    = 1
+	statusCodeError = 2
+)
+
+var (
+	DefaultConfig = Config{
+		Endpoint:    "http://localhost:4318/v1/traces",
+		ServiceName: "styx",
+	}
+
+	ErrInvalidEndpoint = errors.New("tracing: invalid endpoint")
+)
+
+type Config struct {
+	Endpoint    string // OTLP/HTTP traces endpoint.
+	ServiceName string
+}
+
+// Tracer exports spans to an OTLP/HTTP endpoint, using the JSON encoding.
+// Spans are exported in batches, and dropped when the endpoint can't keep up.
+// A nil tracer is valid and traces nothing.
+type Tracer struct {
+	config Config
+	client *http.Client
+	queue  chan *Span
+	done   chan struct{}
+	closed bool
+	lock   sync.Mutex
+}
+
+func NewTracer(config Config) (t *Tracer, err error) {
+
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, ErrInvalidEndpoint
+	}
+
+	t = &Tracer{
+		config: config,
+		client: &http.Client{Timeout: exportTimeout},
+		queue:  make(chan *Span, queueSize),
+		done:   make(chan struct{}),
+		closed: false,
+		lock:   sync.Mutex{},
+	}
+
+	go t.exporter()
+
+	return t, nil
+}
+
+// Close exports pending spans and stops the tracer.
+func (t *Tracer) Close() (err error) {
+
+	if t == nil {
+		return nil
+	}
+
+	t.lock.Lock()
+
+	if t.closed {
+		t.lock.Unlock()
+		return nil
+	}
+
+	t.closed = true
+	close(t.queue)
+
+	t.lock.Unlock()
+
+	<-t.done
+
+	return nil
+}
+
+// StartSpan starts a span child of parent. Spans are only started for valid
+// parents, so that only requests traced by clients are traced.
+func (t *Tracer) StartSpan(name string, parent SpanContext) (s *Span) {
+
+	if t == nil || !parent.IsValid() {
+		return nil
+	}
+
+	context := SpanContext{
+		TraceID: parent.TraceID,
+		SpanID:  newSpanID(),
+		Flags:   parent.Flags,
+	}
+
+	s = &Span{
+		tracer:     t,
+		context:    context,
+		parentID:   parent.SpanID,
+		name:       name,
+		start:      time.Now(),
+		end:        time.Time{},
+		attributes: []attribute{},
+		status:     status{},
+	}
+
+	return s
+}
+
+func (t *Tracer) enqueue(s *Span) {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.closed {
+		return
+	}
+
+	select {
+	case t.queue <- s:
+	default:
+		logger.Debug("tracing: queue full, dropping span")
+	}
+}
+
+func (t *Tracer) exporter() {
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := []*Span{}
+
+	for {
+		select {
+		case s, more := <-t.queue:
+			if !more {
+				t.export(batch)
+				close(t.done)
+				return
+			}
+
+			batch = append(batch, s)
+
+			if len(batch) < exportBatchSize {
+				continue
+			}
+
+		case <-ticker.C:
+		}
+
+		t.export(batch)
+		batch = batch[:0]
+	}
+}
+
+func (t *Tracer) export(batch []*Span) {
+
+	if len(batch) == 0 {
+		return
+	}
+
+	spans := []spanData{}
+	for _, s := range batch {
+		spans = append(spans, s.data())
+	}
+
+	request := exportRequest{
+		ResourceSpans: []resourceSpans{{
+			Resource: resource{
+				Attributes: []attribute{stringAttribute("service.name", t.config.ServiceName)},
+			},
+			ScopeSpans: []scopeSpans{{
+				Scope: scope{Name: "styx"},
+				Spans: spans,
+			}},
+		}},
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+
+	resp, err := t.client.Post(t.config.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Warnf("tracing: failed to export %d spans (%s)", len(batch), err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logger.Warnf("tracing: failed to export %d spans (status %d)", len(batch), resp.StatusCode)
+	}
+}
+
+// Span is an operation being traced. Methods of a nil span do nothing.
+type Span struct {
+	tracer     *Tracer
+	context    SpanContext
+	parentID   [8]byte
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes []attribute
+	status     status
+}
+
+func (s *Span) Context() (sc SpanContext) {
+
+	if s == nil {
+		return sc
+	}
+
+	return s.context
+}
+
+func (s *Span) SetAttribute(key string, value string) {
+
+	if s == nil {
+		return
+	}
+
+	s.attributes = append(s.attributes, stringAttribute(key, value))
+}
+
+func (s *Span) SetIntAttribute(key string, value int64) {
+
+	if s == nil {
+		return
+	}
+
+	s.attributes = append(s.attributes, intAttribute(key, value))
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(err error) {
+
+	if s == nil {
+		return
+	}
+
+	s.status = status{Code: statusCodeError, Message: err.Error()}
+}
+
+// End ends the span, which is exported when sampled by the client.
+func (s *Span) End() {
+
+	if s == nil {
+		return
+	}
+
+	s.end = time.Now()
+
+	if s.status.Code == 0 {
+		s.status.Code = statusCodeOK
+	}
+
+	if !s.context.IsSampled() {
+		return
+	}
+
+	s.tracer.enqueue(s)
+}
+
+func (s *Span) data() (sd spanData) {
+
+	sd = spanData{
+		TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+		ParentSpanID:      hex.EncodeToString(s.parentID[:]),
+		Name:              s.name,
+		Kind:              spanKindServer,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes:        s.attributes,
+		Status:            s.status,
+	}
+
+	return sd
+}
+
+// Types below follow the JSON encoding of the OTLP trace export request.
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanData `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type spanData struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes"`
+	Status            status      `json:"status"`
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+type attributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func stringAttribute(key string, value string) (a attribute) {
+
+	a = attribute{
+		Key:   key,
+		Value: attributeValue{StringValue: &value},
+	}
+
+	return a
+}
+
+func intAttribute(key string, value int64) (a attribute) {
+
+	formatted := strconv.FormatInt(value, 10)
+
+	a = attribute{
+		Key:   key,
+		Value: attributeValue{IntValue: &formatted},
+	}
+
+	return a
+}
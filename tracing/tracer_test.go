@@ -0,0 +1,151 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package tracing
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"gitlab.com/dataptive/styx/log"
+)
+
+// Tests that traceparent values are parsed and formatted back.
+func TestParseTraceparent(t *testing.T) {
+
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := ParseTraceparent(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !sc.IsSampled() {
+		t.Fatalf("span context should be sampled")
+	}
+
+	if sc.Traceparent() != value {
+		t.Fatalf("formatted %s, expected %s", sc.Traceparent(), value)
+	}
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01",
+	}
+
+	for _, value := range invalid {
+
+		_, err := ParseTraceparent(value)
+		if err != ErrInvalidTraceparent {
+			t.Fatalf("traceparent %q should be invalid", value)
+		}
+	}
+}
+
+// Tests that write spans are exported to the collector once records are
+// synced, and that records are stamped with the span context.
+func TestSyncTracker(t *testing.T) {
+
+	exported := []spanData{}
+	lock := sync.Mutex{}
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		request := exportRequest{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		lock.Lock()
+		for _, rs := range request.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				exported = append(exported, ss.Spans...)
+			}
+		}
+		lock.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	tracer, err := NewTracer(Config{Endpoint: collector.URL, ServiceName: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsampled, err := ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := tracer.NewSyncTracker("test", parent)
+
+	records := []log.Record{{}, {}, {}}
+	SetRecordContext(&records[2], unsampled)
+
+	for i := range records {
+		span := tracker.Start(&records[i])
+		tracker.Written(span, 1)
+	}
+
+	// Only the first record is synced, the others fail on close.
+	tracker.Synced(log.SyncProgress{Position: 1, Count: 1})
+	tracker.Close()
+
+	err = tracer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exported) != 2 {
+		t.Fatalf("exported %d spans, expected 2", len(exported))
+	}
+
+	traceID := hex.EncodeToString(parent.TraceID[:])
+	parentID := hex.EncodeToString(parent.SpanID[:])
+
+	for i, span := range exported {
+
+		if span.TraceID != traceID || span.ParentSpanID != parentID {
+			t.Fatalf("span %d is not a child of the request", i)
+		}
+
+		sc, found := RecordContext(&records[i])
+		if !found || hex.EncodeToString(sc.SpanID[:]) != span.SpanID {
+			t.Fatalf("record %d was not stamped with its span context", i)
+		}
+	}
+
+	if exported[0].Status.Code != statusCodeOK || exported[1].Status.Code != statusCodeError {
+		t.Fatalf("unexpected span statuses %+v, %+v", exported[0].Status, exported[1].Status)
+	}
+
+	sc, _ := RecordContext(&records[2])
+	if sc.TraceID != unsampled.TraceID || sc.SpanID == unsampled.SpanID {
+		t.Fatalf("record trace context should take precedence over the request one")
+	}
+}
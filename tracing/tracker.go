@@ -0,0 +1,163 @@
+// Copyright 2021 Dataptive SAS.
+//
+// Use of this software is governed by the Business Source License included in
+// the LICENSE file.
+//
+// As of the Change Date specified in that file, in accordance with the
+// Business Source License, use of this software will be governed by the
+// Apache License, Version 2.0, as published by the Apache Foundation.
+
+package tracing
+
+import (
+	"errors"
+	"sync"
+
+	"gitlab.com/dataptive/styx/log"
+)
+
+const (
+	writeSpanName = "styx.write"
+)
+
+var (
+	errNotSynced = errors.New("tracing: record not synced")
+)
+
+type pendingSpan struct {
+	count int64
+	span  *Span
+}
+
+// SyncTracker traces the records written by a log writer, with spans
+// covering their write until they are synced to disk. Records are traced when
+// they hold a traceparent header, or when the request writing them does.
+// Methods of a nil tracker do nothing.
+type SyncTracker struct {
+	tracer  *Tracer
+	name    string
+	parent  SpanContext
+	written int64
+	pending []pendingSpan
+	lock    sync.Mutex
+}
+
+// NewSyncTracker returns a tracker for records written to the name log,
+// which is nil when tracing is disabled.
+func (t *Tracer) NewSyncTracker(name string, parent SpanContext) (st *SyncTracker) {
+
+	if t == nil {
+		return nil
+	}
+
+	st = &SyncTracker{
+		tracer:  t,
+		name:    name,
+		parent:  parent,
+		written: 0,
+		pending: []pendingSpan{},
+		lock:    sync.Mutex{},
+	}
+
+	return st
+}
+
+// Start starts the span of a record about to be written, and replaces its
+// traceparent header with the span context so that consumers continue the
+// trace from the write.
+func (st *SyncTracker) Start(r *log.Record) (s *Span) {
+
+	if st == nil {
+		return nil
+	}
+
+	parent, found := RecordContext(r)
+	if !found {
+		parent = st.parent
+	}
+
+	s = st.tracer.StartSpan(writeSpanName, parent)
+	if s == nil {
+		return nil
+	}
+
+	s.SetAttribute("styx.log", st.name)
+	s.SetIntAttribute("styx.record.size", int64(len(r.Payload)))
+
+	SetRecordContext(r, s.Context())
+
+	return s
+}
+
+// Written must be called after each record write with the count of records
+// written by the writer, so that spans are ended when records are synced.
+func (st *SyncTracker) Written(s *Span, n int) {
+
+	if st == nil {
+		return
+	}
+
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	st.written++
+
+	if s == nil {
+		return
+	}
+
+	st.pending = append(st.pending, pendingSpan{count: st.written, span: s})
+}
+
+// Duplicate must be called instead of Written when a record is dropped as a
+// producer duplicate, which is never synced.
+func (st *SyncTracker) Duplicate(s *Span) {
+
+	if st == nil {
+		return
+	}
+
+	s.SetAttribute("styx.duplicate", "true")
+	s.End()
+}
+
+// Synced ends the spans of records synced to disk.
+func (st *SyncTracker) Synced(progress log.SyncProgress) {
+
+	if st == nil {
+		return
+	}
+
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	i := 0
+	for ; i < len(st.pending); i++ {
+
+		if st.pending[i].count > progress.Count {
+			break
+		}
+
+		st.pending[i].span.End()
+	}
+
+	st.pending = st.pending[i:]
+}
+
+// Close ends the spans of records that were never synced as failed.
+func (st *SyncTracker) Close() {
+
+	if st == nil {
+		return
+	}
+
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	for _, pending := range st.pending {
+		pending.span.SetError(errNotSynced)
+		pending.span.End()
+	}
+
+	st.pending = nil
+}